@@ -5,12 +5,22 @@ This package is a simple pipeline to align WGS data to a reference genome.
 The steps included are:
 
  * collect sample information (paired/single-end etc.)
- * generate indices for a reference (BWA, faidx + fasta dict)
+ * downsample reads to a target coverage (optional)
+ * generate indices for a reference (BWA, faidx + fasta dict), or reuse them from the reference cache
  * runs BWA alignment
  * processes alignment files
  * runs GATK indel correction
  * calls variants using mpileup and bcftools
- * creates a pseudogenome for each sample (modified reference sequence for each sample based on identified SNPs)
+ * optionally filters the calls (QUAL, depth, mapping quality, strand bias and SNP density) and writes a VCF with the FILTER reasons
+ * creates a pseudogenome for each sample (modified reference sequence for each sample based on the SNPs that passed the filters)
+ * optionally writes each pseudogenome as FASTQ with the quality of each call, and a histogram of the base qualities
+ * masks reference regions (e.g. repeats and prophages) in the pseudogenomes
+ * optionally counts and masks mixed sites, flagging samples that look mixed in the run summary
+ * optionally creates a consensus for each sample with the indels applied, plus a chain file to lift features over from the reference
+ * annotates the SNPs with the genes they hit and their effect (if there is a GFF3 or GenBank annotation)
+ * optionally builds a whole genome alignment and a core SNP alignment from the pseudogenomes (for phylogenetics)
+ * optionally builds a maximum likelihood tree from the SNP alignment with IQ-TREE or RAxML-NG (with ascertainment bias correction)
+ * draws the tree as an SVG figure, with the support values and coloured columns from the sample sheet
 
 */
 
@@ -27,13 +37,28 @@ import (
 	"os/exec"
 	"path"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/annotate"
+	"github.com/will-rowe/gopherSeq/consensus"
+	"github.com/will-rowe/gopherSeq/distance"
+	"github.com/will-rowe/gopherSeq/downsample"
 	"github.com/will-rowe/gopherSeq/envtest"
+	"github.com/will-rowe/gopherSeq/filter"
+	"github.com/will-rowe/gopherSeq/genbank"
+	"github.com/will-rowe/gopherSeq/liftover"
+	"github.com/will-rowe/gopherSeq/metadata"
+	"github.com/will-rowe/gopherSeq/newick"
+	"github.com/will-rowe/gopherSeq/nj"
+	"github.com/will-rowe/gopherSeq/reference"
+	"github.com/will-rowe/gopherSeq/render"
+	"github.com/will-rowe/gopherSeq/snpalign"
+	"github.com/will-rowe/gopherSeq/vcf"
 )
 
 ///////////////
@@ -45,6 +70,9 @@ type sample_information struct {
 	compressed      bool
 	paired          bool
 	path_to_bam     string
+	path_to_vcf     string
+	stats           *consensus.Stats
+	filter_stats    *filter.Stats
 }
 
 type sample_list map[string]*sample_information
@@ -61,15 +89,58 @@ var (
 	logger   *log.Logger
 	wg       sync.WaitGroup
 	threads  string
+
+	// the indexed reference in the reference cache
+	reference_fasta string
+
+	// the options used to filter the calls and build the pseudogenomes
+	filter_options    *filter.Options
+	consensus_options *consensus.Options
+
+	// the annotator for the SNPs (nil if there is no annotation)
+	annotator *annotate.Annotator
+
+	// the sample sheet used to colour the tree figure (nil if there isn't one)
+	sample_sheet *metadata.Table
 )
 
 // set up command line arguments
 var args struct {
-	Input      []string `arg:"positional,help:input fastq files (can be .gz)"`
-	Reference  string   `arg:"required,-r,help:specify a reference sequence (in fasta format)"`
-	Output_dir string   `arg:"-o,help:specify output directory"`
-	Threads    int      `arg:"-t,help:number of processors to use [default: maximum]"`
-	Keep       bool     `arg:"-k,help:keep temporary files [default: false]"`
+	Input        []string `arg:"positional,help:input fastq files (can be .gz)"`
+	Reference    string   `arg:"required,-r,help:specify a reference sequence (in fasta or GenBank format)"`
+	Output_dir   string   `arg:"-o,help:specify output directory"`
+	Threads      int      `arg:"-t,help:number of processors to use [default: maximum]"`
+	Keep         bool     `arg:"-k,help:keep temporary files [default: false]"`
+	Coverage     float64  `arg:"-c,help:downsample reads to this fold-coverage of the reference [default: off]"`
+	Seed         int64    `arg:"help:random seed used when downsampling [default: 42]"`
+	Cache_dir    string   `arg:"help:reference cache directory [default: $gopherSeq_cache or ~/.gopherSeq_cache]"`
+	Annotation   string   `arg:"-g,help:GFF3 annotation of the reference (used to annotate the SNPs) [default: the features of a GenBank reference]"`
+	Filter       bool     `arg:"help:filter the calls before building the pseudogenomes - this masks more sites than vcf2fa -d 5 so the pseudogenomes change [default: false]"`
+	Min_depth    int      `arg:"-d,help:minimum depth for a base to be called (and for a site to pass the filters) [default: 5]"`
+	Max_depth    int      `arg:"help:maximum depth for a site to pass the filters (with --filter) [default: off]"`
+	Min_qual     float64  `arg:"-q,help:minimum QUAL for a SNP to pass the filters (with --filter) [default: 20]"`
+	Min_mq       int      `arg:"help:minimum RMS mapping quality for a site to pass the filters (with --filter) [default: 20]"`
+	Strand_ratio float64  `arg:"help:minimum fraction of the ALT reads on each strand for a SNP to pass the filters (with --filter) [default: off]"`
+	Max_sp       int      `arg:"help:maximum phred-scaled strand bias P-value (SP) for a SNP to pass the filters (with --filter) [default: 40]"`
+	Snp_window   int      `arg:"help:window size for the SNP density filter (with --filter) [default: 10]"`
+	Max_snps     int      `arg:"help:maximum number of SNPs in the window (with --filter) [default: 3]"`
+	Indel_gap    int      `arg:"help:fail SNPs within this many bases of an indel (with --filter) [default: 3]"`
+	Min_af       float64  `arg:"-a,help:minimum fraction of reads supporting a base for it to be called in the pseudogenome [default: off]"`
+	Mask         string   `arg:"-m,help:character used for masked positions in the pseudogenome [default: N]"`
+	Mixed_af     float64  `arg:"help:count sites where the minor allele fraction is at least this (e.g. 0.2) as mixed and mask them [default: off]"`
+	Iupac        bool     `arg:"help:give mixed sites an IUPAC code instead of masking them [default: false]"`
+	Max_mixed    int      `arg:"help:flag samples with more mixed sites than this as possibly mixed (needs --mixed_af) [default: 10]"`
+	Mask_bed     string   `arg:"help:BED file of reference regions to mask in the pseudogenomes (e.g. repeats and prophages)"`
+	Mask_types   string   `arg:"help:feature types from the annotation to mask in the pseudogenomes - separated by commas (e.g. repeat_region)"`
+	Fastq        bool     `arg:"help:also write each pseudogenome as FASTQ with the quality of each call and a histogram of the base qualities [default: false]"`
+	Consensus    bool     `arg:"help:also write each sample's consensus with the called indels applied (and a chain file from the reference) [default: false]"`
+	Snpalign     bool     `arg:"help:build a whole genome alignment and a core SNP alignment from the pseudogenomes - and a neighbour-joining tree unless --phylogeny is used [default: false]"`
+	Core         float64  `arg:"help:drop sites from the SNP alignment where more than this percentage of the samples are N or a gap [default: 100]"`
+	Phylogeny    string   `arg:"help:build a maximum likelihood tree from the SNP alignment with iqtree - iqtree2 or raxml-ng (turns on --snpalign)"`
+	Tree_model   string   `arg:"help:substitution model for the maximum likelihood tree (the ascertainment bias correction is added) [default: GTR+G]"`
+	Asc_bias     string   `arg:"help:ascertainment bias correction for the tree - fconst (from the constant sites of the pseudogenomes) or lewis [default: fconst]"`
+	Metadata     string   `arg:"help:sample sheet (TSV or CSV with a header line - the first column is the sample name) to colour the tips of the tree figure by (turns on --snpalign)"`
+	Columns      string   `arg:"help:columns of the sample sheet to draw next to the tree - separated by commas [default: every column]"`
 }
 
 ///////////////
@@ -93,6 +164,14 @@ func printInfo() {
 func argCheck() {
 	var my_writer io.Writer = os.Stdout
 	args.Output_dir = "./gopherSeq-align-" + string(stamp)
+	args.Seed = downsample.DefaultSeed
+	filter_defaults := filter.DefaultOptions()
+	args.Min_depth, args.Max_depth, args.Min_qual, args.Min_mq, args.Strand_ratio = filter_defaults.Min_depth, filter_defaults.Max_depth, filter_defaults.Min_qual, filter_defaults.Min_mq, filter_defaults.Strand_ratio
+	args.Max_sp, args.Snp_window, args.Max_snps, args.Indel_gap = filter_defaults.Max_sp, filter_defaults.Snp_window, filter_defaults.Max_snps, filter_defaults.Indel_gap
+	args.Mask = "N"
+	args.Max_mixed = 10
+	args.Core = snpalign.DefaultOptions().Max_missing
+	args.Tree_model, args.Asc_bias = "GTR+G", "fconst"
 
 	// parse the ARGs
 	arg.MustParse(&args)
@@ -110,6 +189,62 @@ func argCheck() {
 		}
 	}
 
+	// set up the filter and pseudogenome options (without --filter every filter is off, so the pseudogenomes are the same as vcf2fa -d 5)
+	filter_options = &filter.Options{}
+	if args.Filter {
+		filter_options.Min_qual, filter_options.Min_depth, filter_options.Max_depth, filter_options.Min_mq, filter_options.Strand_ratio = args.Min_qual, args.Min_depth, args.Max_depth, args.Min_mq, args.Strand_ratio
+		filter_options.Max_sp, filter_options.Snp_window, filter_options.Max_snps, filter_options.Indel_gap = args.Max_sp, args.Snp_window, args.Max_snps, args.Indel_gap
+	}
+	mask, err := consensus.MaskCharacter(args.Mask)
+	if err != nil {
+		fmt.Fprintf(my_writer, "%v\n", err)
+		os.Exit(1)
+	}
+	consensus_options = consensus.DefaultOptions()
+	consensus_options.Min_depth, consensus_options.Min_af, consensus_options.Mask = args.Min_depth, args.Min_af, mask
+	consensus_options.Mixed_af, consensus_options.Mixed_iupac = args.Mixed_af, args.Iupac
+
+	if args.Core < 0 || args.Core > 100 {
+		fmt.Fprintf(my_writer, "--core must be a percentage (0-100)\n")
+		os.Exit(1)
+	}
+	if len(args.Phylogeny) != 0 {
+		if !envtest.IsTreeProgram(args.Phylogeny) {
+			fmt.Fprintf(my_writer, "--phylogeny must be iqtree, iqtree2 or raxml-ng\n")
+			os.Exit(1)
+		}
+		if args.Asc_bias != "fconst" && args.Asc_bias != "lewis" {
+			fmt.Fprintf(my_writer, "--asc_bias must be fconst or lewis\n")
+			os.Exit(1)
+		}
+		args.Snpalign = true
+	}
+
+	// check the mask exists
+	if len(args.Mask_bed) != 0 {
+		if _, err := os.Stat(args.Mask_bed); err != nil {
+			fmt.Fprintf(my_writer, "can't access file: %v\n", args.Mask_bed)
+			os.Exit(1)
+		}
+	}
+
+	// check the sample sheet can be read (it is only used for the tree figure, so a tree is needed)
+	if len(args.Metadata) != 0 {
+		args.Snpalign = true
+		if sample_sheet, err = metadata.Read(args.Metadata); err != nil {
+			fmt.Fprintf(my_writer, "could not read sample sheet: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// check the annotation exists
+	if len(args.Annotation) != 0 {
+		if _, err := os.Stat(args.Annotation); err != nil {
+			fmt.Fprintf(my_writer, "can't access file: %v\n", args.Annotation)
+			os.Exit(1)
+		}
+	}
+
 	// check the input files exist
 	for _, input_file := range args.Input {
 		if _, err := os.Stat(input_file); err != nil {
@@ -149,10 +284,38 @@ func argCheck() {
 		fmt.Fprintf(my_writer, "can't make bcfs dir in output directory - already exists?\n")
 		os.Exit(1)
 	}
+	if err := os.Mkdir(args.Output_dir+"/vcfs", 0700); err != nil {
+		fmt.Fprintf(my_writer, "can't make vcfs dir in output directory - already exists?\n")
+		os.Exit(1)
+	}
 	if err := os.Mkdir(args.Output_dir+"/pseudogenomes", 0700); err != nil {
 		fmt.Fprintf(my_writer, "can't make pseudogenomes dir in output directory - already exists?\n")
 		os.Exit(1)
 	}
+	if args.Consensus {
+		if err := os.Mkdir(args.Output_dir+"/consensus", 0700); err != nil {
+			fmt.Fprintf(my_writer, "can't make consensus dir in output directory - already exists?\n")
+			os.Exit(1)
+		}
+	}
+	if args.Snpalign {
+		if err := os.Mkdir(args.Output_dir+"/snpalign", 0700); err != nil {
+			fmt.Fprintf(my_writer, "can't make snpalign dir in output directory - already exists?\n")
+			os.Exit(1)
+		}
+
+		// a tree is always built from the SNP alignment (with --phylogeny, or a neighbour-joining tree without it)
+		if err := os.Mkdir(args.Output_dir+"/phylogeny", 0700); err != nil {
+			fmt.Fprintf(my_writer, "can't make phylogeny dir in output directory - already exists?\n")
+			os.Exit(1)
+		}
+	}
+	if len(args.Annotation) != 0 || genbank.IsGenBank(args.Reference) {
+		if err := os.Mkdir(args.Output_dir+"/annotation", 0700); err != nil {
+			fmt.Fprintf(my_writer, "can't make annotation dir in output directory - already exists?\n")
+			os.Exit(1)
+		}
+	}
 
 	// set number of threads to use
 	if args.Threads <= 0 || args.Threads > runtime.NumCPU() {
@@ -160,6 +323,16 @@ func argCheck() {
 	} else {
 		threads = strconv.Itoa(args.Threads)
 	}
+
+	// find the reference cache
+	if len(args.Cache_dir) == 0 {
+		cache_dir, err := reference.CacheDir()
+		if err != nil {
+			fmt.Fprintf(my_writer, "can't find the reference cache: %v\n", err)
+			os.Exit(1)
+		}
+		args.Cache_dir = cache_dir
+	}
 }
 
 /*
@@ -208,7 +381,7 @@ func getSampleInfo(input_file string, compressed bool) {
 
 	// save sample information or append if sample basename already exists
 	if _, ok := samples[sample]; ok != true {
-		samples[sample] = &sample_information{path_to_reads, "", compressed, paired, ""}
+		samples[sample] = &sample_information{path_to_reads, "", compressed, paired, "", "", nil, nil}
 	} else {
 		samples[sample].path_to_reads_2 = path_to_reads
 	}
@@ -228,35 +401,123 @@ func getLogging() {
 }
 
 /*
-  function to generate indices from reference
+  function to downsample the reads for each sample to the target coverage
 */
-func createIndex() {
-	reference := args.Output_dir + "/tmp/reference.fa"
-	index_cmd := "cp " + args.Reference + " " + reference + " && samtools faidx " + reference + " && samtools dict " + reference + " > " + args.Output_dir + "/tmp/reference.dict"
-	if err := exec.Command("bash", "-c", index_cmd).Run(); err != nil {
-		fmt.Println(index_cmd)
-		logger.Printf(" * couldn't create the faidx index! Check the reference sequence\n")
+func downsampleReads() {
+	genome_size, err := downsample.GenomeSize(reference_fasta)
+	if err != nil {
+		logger.Printf(" * couldn't get the genome size from the reference: %v", err)
 		os.Exit(1)
 	}
-	if err := exec.Command("bwa", "index", reference).Run(); err != nil {
-		logger.Printf(" * couldn't create the BWA index! Check the reference sequence\n")
+	out_dir := args.Output_dir + "/tmp/downsampled"
+	if err := os.Mkdir(out_dir, 0700); err != nil {
+		logger.Printf(" * couldn't make directory for downsampled reads: %v", err)
 		os.Exit(1)
 	}
+	logger.Printf(" * genome size --> %d", genome_size)
+	for sample, info := range samples {
+		reads := []string{info.path_to_reads_1}
+		if info.paired == true {
+			reads = append(reads, info.path_to_reads_2)
+		}
+		downsampled, coverage, err := downsample.Sample(reads, out_dir, genome_size, args.Coverage, args.Seed)
+		if err != nil {
+			logger.Printf(" * failed to downsample %s: %v", sample, err)
+			os.Exit(1)
+		}
+		if coverage <= args.Coverage {
+			logger.Printf("\t%s is at %.1fx - no downsampling needed", sample, coverage)
+			continue
+		}
+		logger.Printf("\t%s downsampled from %.1fx to %.1fx", sample, coverage, args.Coverage)
+		info.path_to_reads_1 = downsampled[0]
+		if info.paired == true {
+			info.path_to_reads_2 = downsampled[1]
+		}
+	}
+}
 
+/*
+  function to get the indices for the reference (built once and reused from the reference cache)
+*/
+func prepareReference() {
+	fasta, built, err := reference.Prepare(args.Reference, args.Cache_dir)
+	if err != nil {
+		logger.Printf(" * %v", err)
+		os.Exit(1)
+	}
+	if built {
+		logger.Printf(" * built indices and added them to the reference cache --> %s", fasta)
+	} else {
+		logger.Printf(" * using indices from the reference cache --> %s", fasta)
+	}
+	if annotation := reference.Annotation(fasta); len(annotation) != 0 {
+		logger.Printf(" * GFF3 annotation converted from the GenBank reference --> %s", annotation)
+	}
+	reference_fasta = fasta
+}
+
+/*
+  function to load the annotation used for the SNPs (a GFF3 supplied by the user, or the one converted from a GenBank reference)
+*/
+func loadAnnotation() {
+	if len(args.Annotation) == 0 {
+		args.Annotation = reference.Annotation(reference_fasta)
+	}
+	if len(args.Annotation) == 0 {
+		logger.Printf(" * no annotation for the reference - SNPs won't be annotated")
+		return
+	}
+	loaded, err := annotate.Load(reference_fasta, args.Annotation)
+	if err != nil {
+		logger.Printf(" * %v", err)
+		os.Exit(1)
+	}
+	logger.Printf(" * annotation --> %s", args.Annotation)
+	annotator = loaded
+}
+
+/*
+  function to get the reference regions to mask in the pseudogenomes (from a BED file and/or feature types in the annotation)
+*/
+func loadMask() {
+	if len(args.Mask_bed) == 0 && len(args.Mask_types) == 0 {
+		return
+	}
+	regions, err := consensus.LoadRegions(args.Mask_bed, args.Annotation, args.Mask_types)
+	if err != nil {
+		logger.Printf(" * could not get the regions to mask: %v", err)
+		os.Exit(1)
+	}
+	logger.Printf(" * masking %d bases of the reference in the pseudogenomes", regions.Length())
+	consensus_options.Regions = regions
+}
+
+/*
+  function to annotate the SNPs for a sample
+*/
+func runAnnotation(sample string, worker int) {
+	logger.Printf("\t[ worker %d: * annotating SNPs for %s ]", worker, sample)
+	prefix := args.Output_dir + "/annotation/" + sample
+	annotated, err := annotator.AnnotateFile(samples[sample].path_to_vcf, prefix+".annotated.vcf", prefix+".annotation.tsv")
+	if err != nil {
+		logger.Printf("failed to annotate SNPs: %v", err)
+		os.Exit(1)
+	}
+	logger.Printf("\t[ worker %d: * annotated %d variant sites for %s ]", worker, annotated, sample)
 }
 
 /*
   function to run BWA
 */
 func runBWA() {
-	reference := args.Output_dir + "/tmp/reference.fa"
-
 	// loop through samples, running one alignment at a time
 	for sample, info := range samples {
 		logger.Printf(" * aligning reads from %s", sample)
 		outfile := args.Output_dir + "/tmp/alignment_file." + sample + ".sorted.bam"
 		BWAcmd := []string{}
-		BWAcmd = append(BWAcmd, "bwa mem -t ", threads, " -R '@RG\tID:foo\tSM:bar\tLB:library1' ", reference)
+		// the read group sample (SM) becomes the sample name in the VCF and the annotation
+		BWAcmd = append(BWAcmd, "bwa mem -t ", threads, " -R '@RG\tID:"+sample+"\tSM:"+sample+"\tLB:library1' ", reference_fasta)
 
 		// customise BWA command based on sample type
 		if info.paired == true {
@@ -295,7 +556,7 @@ func runGATK(sample string) {
 
 	// create targets
 	logger.Printf("\t* creating targets for %s", bam_nodup)
-	RTC := "java -Xmx2g -Xss512k -jar $gopherSeq_bin/GenomeAnalysisTK.jar -T RealignerTargetCreator -nt " + threads + " -nct 1 -R " + args.Output_dir + "/tmp/reference.fa -I " + bam_nodup + " -o " + args.Output_dir + "/tmp/realigner.intervals"
+	RTC := "java -Xmx2g -Xss512k -jar $gopherSeq_bin/GenomeAnalysisTK.jar -T RealignerTargetCreator -nt " + threads + " -nct 1 -R " + reference_fasta + " -I " + bam_nodup + " -o " + args.Output_dir + "/tmp/realigner.intervals"
 	if err := exec.Command("bash", "-c", RTC).Run(); err != nil {
 		logger.Printf("failed to execute create targets: %s", RTC)
 		logger.Printf("error: %s", err)
@@ -305,7 +566,7 @@ func runGATK(sample string) {
 	// realign indels
 	logger.Printf("\t* realigning indels for %s", bam_nodup)
 	outfile := args.Output_dir + "/bams/alignment_file." + sample + ".sorted.nodup.indels_corrected.bam"
-	IR := "java -Xmx2g -Xss512k -jar $gopherSeq_bin/GenomeAnalysisTK.jar -T IndelRealigner -nct 1 -R " + args.Output_dir + "/tmp/reference.fa -I " + bam_nodup + " -targetIntervals " + args.Output_dir + "/tmp/realigner.intervals -o " + outfile
+	IR := "java -Xmx2g -Xss512k -jar $gopherSeq_bin/GenomeAnalysisTK.jar -T IndelRealigner -nct 1 -R " + reference_fasta + " -I " + bam_nodup + " -targetIntervals " + args.Output_dir + "/tmp/realigner.intervals -o " + outfile
 	if err := exec.Command("bash", "-c", IR).Run(); err != nil {
 		logger.Printf("failed to execute indel realignment: %s", IR)
 		logger.Printf("error: %s", err)
@@ -332,7 +593,7 @@ func runSNPcall(sample string, worker int) {
         f - the faidx-indexed reference file in the FASTA forma
         */
 	logger.Printf("\t[ worker %d: * running mpileup on %s ]", worker, sample)
-	MPILEUP := "samtools mpileup -d 1000 -guB -t DP,DV,DP4,SP -f " + args.Output_dir + "/tmp/reference.fa " + info.path_to_bam + " > " + args.Output_dir + "/tmp/" + sample + ".tmp.bcf"
+	MPILEUP := "samtools mpileup -d 1000 -guB -t DP,DV,DP4,SP -f " + reference_fasta + " " + info.path_to_bam + " > " + args.Output_dir + "/tmp/" + sample + ".tmp.bcf"
 	if err := exec.Command("bash", "-c", MPILEUP).Run(); err != nil {
 		logger.Printf("failed to run mpileup: %s", MPILEUP)
 		logger.Printf("error: %s", err)
@@ -349,15 +610,232 @@ func runSNPcall(sample string, worker int) {
 		os.Exit(1)
 	}
 
+	// convert the calls to VCF for the downstream stages
+	vcf_file := args.Output_dir + "/tmp/" + sample + ".vcf"
+	VIEW := "bcftools view " + outfile + " -O v -o " + vcf_file
+	if err := exec.Command("bash", "-c", VIEW).Run(); err != nil {
+		logger.Printf("failed to convert calls to VCF: %s", VIEW)
+		logger.Printf("error: %s", err)
+		os.Exit(1)
+	}
+	stats, err := vcf.Summarise(vcf_file)
+	if err != nil {
+		logger.Printf("failed to read VCF: %v", err)
+		os.Exit(1)
+	}
+	logger.Printf("\t[ worker %d: * %s has %d sites (mean depth %.1f), %d SNPs and %d indels ]", worker, sample, stats.Sites, stats.MeanDepth(), stats.SNPs, stats.Indels)
+
+	// filter the calls (every filter is off without --filter, so this just counts the SNPs)
+	logger.Printf("\t[ worker %d: * filtering calls for %s ]", worker, sample)
+	filtered := args.Output_dir + "/vcfs/" + sample + ".filtered.vcf"
+	filter_stats, err := filter_options.FilterFile(vcf_file, filtered)
+	if err != nil {
+		logger.Printf("failed to filter calls: %v", err)
+		os.Exit(1)
+	}
+	logger.Printf("\t[ worker %d: * %s has %d of %d SNPs passing the filters (failed: %s) ]", worker, sample, filter_stats.SNPs_passed, filter_stats.SNPs, filter_stats.Summary())
+	info.path_to_vcf = filtered
+	info.filter_stats = filter_stats
+
 	// create pseudogenome
 	logger.Printf("\t[ worker %d: * creating pseudogenome for %s ]", worker, sample)
 	pseudogenome := args.Output_dir + "/pseudogenomes/" + sample + ".pseudogenome.fa"
-	PSEUDO := "bcftools view " + outfile + " | $gopherSeq_bin/vcfutils.pl vcf2fa -d 5 > " + pseudogenome
-	if err := exec.Command("bash", "-c", PSEUDO).Run(); err != nil {
-		logger.Printf("failed to generate pseudogenome: %s", PSEUDO)
-		logger.Printf("error: %s", err)
+	fastq := ""
+	if args.Fastq {
+		fastq = args.Output_dir + "/pseudogenomes/" + sample + ".pseudogenome.fq"
+	}
+	pseudogenome_stats, err := consensus_options.BuildFiles(info.path_to_vcf, pseudogenome, fastq)
+	if err != nil {
+		logger.Printf("failed to generate pseudogenome: %v", err)
 		os.Exit(1)
 	}
+	if args.Fastq {
+		histogram, err := os.Create(args.Output_dir + "/pseudogenomes/" + sample + ".quality_histogram.tsv")
+		if err != nil {
+			logger.Printf("failed to write quality histogram: %v", err)
+			os.Exit(1)
+		}
+		if err := pseudogenome_stats.WriteHistogram(histogram); err != nil {
+			logger.Printf("failed to write quality histogram: %v", err)
+			os.Exit(1)
+		}
+		histogram.Close()
+	}
+	logger.Printf("\t[ worker %d: * %s pseudogenome has %d bases (%d called, %d masked, %d mixed sites) ]", worker, sample, pseudogenome_stats.Length, pseudogenome_stats.Called, pseudogenome_stats.Masked, pseudogenome_stats.Mixed)
+	info.stats = pseudogenome_stats
+
+	// create the consensus (with the indels applied) and the chain file to map between it and the reference
+	if args.Consensus {
+		logger.Printf("\t[ worker %d: * creating consensus for %s ]", worker, sample)
+		indel_options := *consensus_options
+		indel_options.Indels = true
+		consensus_stats, err := indel_options.BuildFile(info.path_to_vcf, args.Output_dir+"/consensus/"+sample+".consensus.fa")
+		if err != nil {
+			logger.Printf("failed to generate consensus: %v", err)
+			os.Exit(1)
+		}
+		if err := liftover.WriteFile(args.Output_dir+"/consensus/"+sample+".chain", consensus_stats.Chains); err != nil {
+			logger.Printf("failed to write chain file: %v", err)
+			os.Exit(1)
+		}
+		logger.Printf("\t[ worker %d: * %s consensus has %d bases (%d indels applied) ]", worker, sample, consensus_stats.Length, consensus_stats.Indels)
+	}
+}
+
+/*
+  function to write the run summary (one line per sample), flagging samples with a lot of mixed sites
+*/
+func writeSummary() {
+	fh, err := os.Create(args.Output_dir + "/summary.tsv")
+	if err != nil {
+		logger.Printf("could not write summary: %v", err)
+		os.Exit(1)
+	}
+	defer fh.Close()
+	var names []string
+	for sample := range samples {
+		names = append(names, sample)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(fh, "sample\tsnps\tsnps_passed\tpseudogenome_length\tcalled\tmasked\tregion_masked\tmean_quality\tmixed_sites\tpossibly_mixed\n")
+	for _, sample := range names {
+		stats, filter_stats := samples[sample].stats, samples[sample].filter_stats
+		if stats == nil || filter_stats == nil {
+			continue
+		}
+		possibly_mixed := "no"
+		if args.Mixed_af > 0 && stats.Mixed > args.Max_mixed {
+			possibly_mixed = "yes"
+			logger.Printf(" * %s has %d mixed sites - possibly a mixed sample", sample, stats.Mixed)
+		}
+		fmt.Fprintf(fh, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%.1f\t%d\t%s\n", sample, filter_stats.SNPs, filter_stats.SNPs_passed, stats.Length, stats.Called, stats.Masked, stats.Region_masked, stats.MeanQuality(), stats.Mixed, possibly_mixed)
+	}
+	logger.Printf(" * run summary --> %s", args.Output_dir+"/summary.tsv")
+}
+
+/*
+  function to build the whole genome and core SNP alignments from the pseudogenomes (and the reference)
+*/
+func runSnpalign() *snpalign.Stats {
+	var pseudogenomes []string
+	for sample, info := range samples {
+		if info.stats != nil {
+			pseudogenomes = append(pseudogenomes, args.Output_dir+"/pseudogenomes/"+sample+".pseudogenome.fa")
+		}
+	}
+	sort.Strings(pseudogenomes)
+	options := snpalign.DefaultOptions()
+	options.Max_missing, options.Reference = args.Core, reference_fasta
+	prefix := args.Output_dir + "/snpalign/core"
+	stats, err := options.BuildFiles(pseudogenomes, prefix)
+	if err != nil {
+		logger.Printf("could not build SNP alignment: %v", err)
+		os.Exit(1)
+	}
+	logger.Printf(" * whole genome alignment --> %s (%d sequences of %d bases)", prefix+".full.aln", stats.Samples, stats.Length)
+	logger.Printf(" * SNP alignment --> %s (%d of %d variable sites)", prefix+".snps.aln", stats.Sites, stats.Variable)
+	return stats
+}
+
+/*
+  function to build a maximum likelihood tree from the SNP alignment with IQ-TREE or RAxML-NG
+
+  the SNP alignment only has variable sites, so the tree needs an ascertainment bias correction - either from the number of constant sites with each base in the pseudogenomes (fconst: -fconst for IQ-TREE or ASC_STAM for RAxML-NG), or the Lewis correction (+ASC or ASC_LEWIS)
+*/
+func runPhylogeny(stats *snpalign.Stats) {
+	alignment := args.Output_dir + "/snpalign/core.snps.aln"
+	tree := args.Output_dir + "/phylogeny/core.tree"
+	constant := stats.Constant
+	if stats.Sites < 4 {
+		logger.Printf("not enough SNPs to build a tree (%d)", stats.Sites)
+		os.Exit(1)
+	}
+	var TREEcmd, output string
+	switch args.Phylogeny {
+	case "raxml-ng":
+		prefix := args.Output_dir + "/phylogeny/raxml"
+		model := args.Tree_model + "+ASC_LEWIS"
+		if args.Asc_bias == "fconst" {
+			model = fmt.Sprintf("%s+ASC_STAM{%d/%d/%d/%d}", args.Tree_model, constant[0], constant[1], constant[2], constant[3])
+		}
+		TREEcmd = "raxml-ng --msa " + alignment + " --model '" + model + "' --threads " + threads + " --seed 42 --prefix " + prefix
+		output = prefix + ".raxml.bestTree"
+	default:
+		prefix := args.Output_dir + "/phylogeny/iqtree"
+		model := args.Tree_model + "+ASC"
+		if args.Asc_bias == "fconst" {
+			model = fmt.Sprintf("%s -fconst %d,%d,%d,%d", args.Tree_model, constant[0], constant[1], constant[2], constant[3])
+		}
+		TREEcmd = args.Phylogeny + " -s " + alignment + " -m " + model + " -nt " + threads + " -seed 42 -pre " + prefix
+		output = prefix + ".treefile"
+	}
+	logger.Printf(" * running %s", TREEcmd)
+	if err := exec.Command("bash", "-c", TREEcmd+" > "+args.Output_dir+"/phylogeny/"+args.Phylogeny+".stdout 2>&1").Run(); err != nil {
+		logger.Printf("failed to execute %s: %s (see %s)", args.Phylogeny, err, args.Output_dir+"/phylogeny/"+args.Phylogeny+".stdout")
+		os.Exit(1)
+	}
+	if err := os.Rename(output, tree); err != nil {
+		logger.Printf("could not collect the tree from %s: %v", args.Phylogeny, err)
+		os.Exit(1)
+	}
+	logger.Printf(" * maximum likelihood tree --> %s", tree)
+}
+
+/*
+  function to build a neighbour-joining tree from the SNP distances of the SNP alignment, for when there is no maximum likelihood tree
+*/
+func runNJ() {
+	alignment := args.Output_dir + "/snpalign/core.snps.aln"
+	tree := args.Output_dir + "/phylogeny/core.nj.tree"
+	sequences, err := distance.ReadAlignment(alignment)
+	if err != nil {
+		logger.Printf("could not read the SNP alignment: %v", err)
+		os.Exit(1)
+	}
+	numberThreads, _ := strconv.Atoi(threads)
+	matrix, err := distance.Calculate(sequences, false, numberThreads)
+	if err != nil {
+		logger.Printf("could not calculate the SNP distances: %v", err)
+		os.Exit(1)
+	}
+	root, err := nj.Join(matrix)
+	if err != nil {
+		logger.Printf("could not build the neighbour-joining tree: %v", err)
+		os.Exit(1)
+	}
+	if err := newick.WriteFile(tree, root); err != nil {
+		logger.Printf("could not write the neighbour-joining tree: %v", err)
+		os.Exit(1)
+	}
+	logger.Printf(" * neighbour-joining tree --> %s", tree)
+}
+
+/*
+  function to draw a tree as an SVG figure (rooted at its midpoint), with the columns of the sample sheet
+*/
+func drawTree(tree string, units string) {
+	figure := args.Output_dir + "/phylogeny/core.svg"
+	root, err := newick.Read(tree)
+	if err != nil {
+		logger.Printf("could not read the tree: %v", err)
+		return
+	}
+	root = newick.MidpointRoot(root)
+	newick.Ladderize(root, false)
+	options := &render.Options{Support: true, Units: units, Title: path.Base(args.Output_dir)}
+	if sample_sheet != nil {
+		options.Metadata = sample_sheet
+		if len(args.Columns) != 0 {
+			options.Columns = strings.Split(args.Columns, ",")
+		} else {
+			options.Columns = sample_sheet.Others("")
+		}
+	}
+	if err := render.WriteFile(figure, root, options); err != nil {
+		logger.Printf("could not draw the tree: %v", err)
+		return
+	}
+	logger.Printf(" * tree figure --> %s", figure)
 }
 
 /*
@@ -414,6 +892,9 @@ func worker(tasks chan string, worker int) {
 
 		// run the task
 		runSNPcall(sample, worker) // variant call
+		if annotator != nil {
+			runAnnotation(sample, worker) // SNP annotation
+		}
 
 		// notify task completion
 		logger.Printf("\t[ worker %d: completed task ]", worker)
@@ -472,6 +953,16 @@ func Main() {
 		logger.Printf("program check failed!\n")
 		os.Exit(1)
 	}
+	if len(args.Phylogeny) != 0 {
+		passed, messages = envtest.Test4tree_prog(args.Phylogeny)
+		for _, message := range messages {
+			logger.Printf("%v", message)
+		}
+		if passed == false {
+			logger.Printf("program check failed!\n")
+			os.Exit(1)
+		}
+	}
 
 	// print some messages
 	logger.Printf("checking for input arguments . . .")
@@ -490,10 +981,22 @@ func Main() {
 	logger.Printf(" * number of threads to be used --> %s", threads)
 	logger.Printf(" * keeping temporary files --> %t", args.Keep)
 	logger.Printf(" * output directory --> %s", args.Output_dir)
+	logger.Printf(" * reference cache --> %s", args.Cache_dir)
+	if args.Coverage > 0 {
+		logger.Printf(" * downsampling to coverage --> %.1fx (seed %d)", args.Coverage, args.Seed)
+	}
 
-	// create BWA index
-	logger.Printf("building BWA index . . .")
-	createIndex()
+	// get the reference indices
+	logger.Printf("preparing reference (faidx, fasta dict, BWA index) . . .")
+	prepareReference()
+	loadAnnotation()
+	loadMask()
+
+	// downsample the reads
+	if args.Coverage > 0 {
+		logger.Printf("downsampling reads . . .")
+		downsampleReads()
+	}
 
 	// run BWA
 	logger.Printf("--- started read alignment ---")
@@ -509,6 +1012,21 @@ func Main() {
 
 	logger.Printf("running samtools + bcftools . . .")
 	runGoroutines()
+	writeSummary()
+	if args.Snpalign {
+		logger.Printf("--- started SNP alignment ---")
+		stats := runSnpalign()
+
+		// draw the maximum likelihood tree, or a neighbour-joining tree if there isn't one
+		logger.Printf("--- started phylogeny ---")
+		if len(args.Phylogeny) != 0 {
+			runPhylogeny(stats)
+			drawTree(args.Output_dir+"/phylogeny/core.tree", "substitutions per site")
+		} else {
+			runNJ()
+			drawTree(args.Output_dir+"/phylogeny/core.nj.tree", "SNPs")
+		}
+	}
 
 	// clean up
 	logger.Printf("--- finished ---")
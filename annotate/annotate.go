@@ -0,0 +1,112 @@
+/*
+
+This package annotates called variants against a GFF3 annotation of the reference.
+
+Each variant is given:
+
+ * the gene it hits (ID, locus tag, name and product), or intergenic
+ * for coding variants, the codon position, reference/alternate codon and amino acid
+ * the effect (synonymous, non-synonymous, stop-gained, stop-lost, start-lost, frameshift etc.)
+
+The annotation is added to the VCF as an INFO field (GSANN) and written as a TSV (one row per sample carrying the allele).
+
+*/
+
+package annotate
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/genbank"
+	"github.com/will-rowe/gopherSeq/gff"
+	"github.com/will-rowe/gopherSeq/reference"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up command line arguments
+var args struct {
+	Input      string `arg:"positional,required,help:variant calls to annotate (in VCF format - can be .gz)"`
+	Reference  string `arg:"required,-r,help:reference sequence the variants were called against (in fasta or GenBank format)"`
+	Annotation string `arg:"-g,help:annotation of the reference (in GFF3 format) [default: the features of a GenBank reference]"`
+	Output     string `arg:"-o,help:output prefix for the .annotated.vcf and .annotation.tsv files [default: the input file name]"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tannotates called variants with the genes they hit and their effect\n\nusage:\n\tgopherSeq annotate [options] -r REFERENCE INPUT\n\nhelp:\n\tgopherSeq annotate --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to create an annotator for a reference (the annotation is taken from a GenBank reference if no GFF3 is given)
+*/
+func Load(reference_file string, annotation_file string) (*Annotator, error) {
+	sequences, err := reference.Sequences(reference_file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read reference: %v", err)
+	}
+	var annotation *gff.Annotation
+	switch {
+	case len(annotation_file) != 0:
+		annotation, err = gff.Read(annotation_file)
+	case genbank.IsGenBank(reference_file):
+		var records []*genbank.Record
+		if records, err = genbank.Read(reference_file); err == nil {
+			annotation, err = genbank.Annotation(records)
+		}
+	default:
+		return nil, fmt.Errorf("no annotation supplied for a fasta reference")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read annotation: %v", err)
+	}
+	return New(annotation, sequences), nil
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	arg.MustParse(&args)
+	if len(args.Output) == 0 {
+		args.Output = strings.TrimSuffix(args.Input, ".gz")
+		args.Output = strings.TrimSuffix(args.Output, filepath.Ext(args.Output))
+	}
+
+	// annotate the variants
+	annotator, err := Load(args.Reference, args.Annotation)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	out_vcf, out_tsv := args.Output+".annotated.vcf", args.Output+".annotation.tsv"
+	annotated, err := annotator.AnnotateFile(args.Input, out_vcf, out_tsv)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(" * annotated %d variant sites\n * VCF --> %v\n * TSV --> %v\n", annotated, out_vcf, out_tsv)
+}
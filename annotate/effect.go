@@ -0,0 +1,445 @@
+package annotate
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/will-rowe/gopherSeq/dna"
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/gff"
+	"github.com/will-rowe/gopherSeq/vcf"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// InfoID is the INFO field added to annotated VCF files
+const InfoID = "GSANN"
+
+// InfoDescription describes the GSANN INFO field
+const InfoDescription = "gopherSeq functional annotation: 'allele|effect|feature_type|gene_id|locus_tag|gene_name|product|codon_position|ref_codon|alt_codon|ref_aa|alt_aa|aa_position'"
+
+// TSVHeader is the header line of the annotation TSV
+const TSVHeader = "sample\tchrom\tpos\tref\talt\tqual\tfilter\teffect\tfeature_type\tgene_id\tlocus_tag\tgene_name\tproduct\tcodon_position\tref_codon\talt_codon\tref_aa\talt_aa\taa_position"
+
+// the feature types that are only used for their children
+var skip_types = map[string]bool{"region": true, "exon": true, "CDS": true}
+
+///////////////
+// STRUCTS
+//////////////
+// Annotator annotates variants using a reference sequence and its GFF3 annotation (it is safe to share between goroutines)
+type Annotator struct {
+	annotation *gff.Annotation
+	sequences  map[string][]byte
+	cds        map[*gff.Feature]*coding_sequence
+	lock       sync.Mutex
+}
+
+// Effect is the annotation of one allele against one feature
+type Effect struct {
+	Allele         string
+	Effect         string
+	Feature_type   string
+	Gene_id        string
+	Locus_tag      string
+	Gene_name      string
+	Product        string
+	Codon_position int
+	Ref_codon      string
+	Alt_codon      string
+	Ref_aa         string
+	Alt_aa         string
+	Aa_position    int
+}
+
+// coding_sequence is a spliced CDS in the coding orientation
+type coding_sequence struct {
+	lines  []*gff.Feature
+	strand byte
+	phase  int
+	seq    []byte
+	pseudo bool
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to create a new annotator
+*/
+func New(annotation *gff.Annotation, sequences []*fasta.Sequence) *Annotator {
+	annotator := &Annotator{annotation: annotation, sequences: make(map[string][]byte), cds: make(map[*gff.Feature]*coding_sequence)}
+	for _, sequence := range sequences {
+		annotator.sequences[sequence.Name] = sequence.Seq
+	}
+	return annotator
+}
+
+/*
+  function to get the reference base at a position (positions past the end of a circular sequence wrap around)
+*/
+func (annotator *Annotator) base(seqid string, position int) byte {
+	seq := annotator.sequences[seqid]
+	if len(seq) == 0 {
+		return 'N'
+	}
+	base := seq[(position-1)%len(seq)]
+	if base >= 'a' && base <= 'z' {
+		base = base - 'a' + 'A'
+	}
+	return base
+}
+
+/*
+  function to get the spliced sequence of a CDS (a CDS split over several lines shares its ID)
+*/
+func (annotator *Annotator) codingSequence(feature *gff.Feature) *coding_sequence {
+	lines := []*gff.Feature{feature}
+	if id := feature.ID(); len(id) != 0 {
+		lines = annotator.annotation.ByID(id)
+	}
+	annotator.lock.Lock()
+	defer annotator.lock.Unlock()
+	if cds, ok := annotator.cds[lines[0]]; ok {
+		return cds
+	}
+
+	// order the lines from the 5' end of the CDS
+	ordered := make([]*gff.Feature, len(lines))
+	copy(ordered, lines)
+	sort.Sort(by_position(ordered))
+	if feature.Strand == '-' {
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+	cds := &coding_sequence{lines: ordered, strand: feature.Strand, phase: ordered[0].Phase}
+	if cds.phase < 0 {
+		cds.phase = 0
+	}
+	if pseudo, ok := feature.Attribute("pseudo"); ok && pseudo == "true" {
+		cds.pseudo = true
+	}
+	for _, line := range ordered {
+		if line.Strand == '-' {
+			for position := line.End; position >= line.Start; position-- {
+				cds.seq = append(cds.seq, dna.ReverseComplement([]byte{annotator.base(line.Seqid, position)})[0])
+			}
+		} else {
+			for position := line.Start; position <= line.End; position++ {
+				cds.seq = append(cds.seq, annotator.base(line.Seqid, position))
+			}
+		}
+	}
+	annotator.cds[lines[0]] = cds
+	return cds
+}
+
+// by_position sorts features by start position
+type by_position []*gff.Feature
+
+func (features by_position) Len() int           { return len(features) }
+func (features by_position) Swap(i, j int)      { features[i], features[j] = features[j], features[i] }
+func (features by_position) Less(i, j int) bool { return features[i].Start < features[j].Start }
+
+/*
+  function to find the offset of a position in a spliced CDS (-1 if not in the CDS)
+*/
+func (cds *coding_sequence) offset(position int, length int) int {
+	consumed := 0
+	for _, line := range cds.lines {
+		for _, candidate := range []int{position, position + length} {
+			if candidate >= line.Start && candidate <= line.End {
+				if cds.strand == '-' {
+					return consumed + line.End - candidate
+				}
+				return consumed + candidate - line.Start
+			}
+		}
+		consumed += line.Length()
+	}
+	return -1
+}
+
+/*
+  function to fill in the gene details for an effect
+*/
+func geneDetails(effect *Effect, feature *gff.Feature) {
+	effect.Feature_type = feature.Type
+	gene := feature.Ancestor("gene")
+	if gene == nil {
+		gene = feature
+	}
+	effect.Gene_id = gene.ID()
+	effect.Locus_tag, _ = gene.Attribute("locus_tag")
+	if name, ok := gene.Attribute("gene"); ok {
+		effect.Gene_name = name
+	} else {
+		effect.Gene_name, _ = gene.Attribute("Name")
+	}
+	if product, ok := feature.Attribute("product"); ok {
+		effect.Product = product
+	} else {
+		effect.Product, _ = gene.Attribute("product")
+	}
+}
+
+/*
+  function to work out the effect of an allele on a CDS
+*/
+func (annotator *Annotator) codingEffect(effect *Effect, feature *gff.Feature, position int, ref string, alt string) {
+	cds := annotator.codingSequence(feature)
+	if len(ref) != len(alt) {
+		if (len(alt)-len(ref))%3 != 0 {
+			effect.Effect = "frameshift"
+		} else {
+			effect.Effect = "inframe-indel"
+		}
+		return
+	}
+	if len(ref) != 1 {
+		effect.Effect = "complex"
+		return
+	}
+	if cds.pseudo {
+		effect.Effect = "pseudogene"
+		return
+	}
+
+	// find the codon
+	offset := cds.offset(position, len(annotator.sequences[feature.Seqid])) - cds.phase
+	if offset < 0 {
+		effect.Effect = "incomplete-codon"
+		return
+	}
+	codon_index := offset / 3
+	codon_start := cds.phase + codon_index*3
+	if codon_start+3 > len(cds.seq) {
+		effect.Effect = "incomplete-codon"
+		return
+	}
+	effect.Codon_position = offset%3 + 1
+	effect.Aa_position = codon_index + 1
+	ref_codon := []byte(string(cds.seq[codon_start : codon_start+3]))
+	alt_codon := []byte(string(ref_codon))
+	alt_base := strings.ToUpper(alt)[0]
+	if cds.strand == '-' {
+		alt_base = dna.ReverseComplement([]byte{alt_base})[0]
+	}
+	alt_codon[offset%3] = alt_base
+	effect.Ref_codon, effect.Alt_codon = string(ref_codon), string(alt_codon)
+
+	// translate the codons (the first codon of a complete CDS is a start codon)
+	ref_aa, alt_aa := dna.TranslateCodon(effect.Ref_codon), dna.TranslateCodon(effect.Alt_codon)
+	if codon_index == 0 && cds.phase == 0 {
+		if dna.IsStartCodon(effect.Ref_codon) {
+			ref_aa = 'M'
+		}
+		if dna.IsStartCodon(effect.Alt_codon) {
+			alt_aa = 'M'
+		}
+	}
+	effect.Ref_aa, effect.Alt_aa = string(ref_aa), string(alt_aa)
+	switch {
+	case ref_aa == alt_aa:
+		effect.Effect = "synonymous"
+	case codon_index == 0 && cds.phase == 0 && ref_aa == 'M':
+		effect.Effect = "start-lost"
+	case alt_aa == '*':
+		effect.Effect = "stop-gained"
+	case ref_aa == '*':
+		effect.Effect = "stop-lost"
+	default:
+		effect.Effect = "non-synonymous"
+	}
+}
+
+/*
+  function to annotate one allele of a variant (returns one effect per overlapping feature, or a single intergenic effect)
+*/
+func (annotator *Annotator) Annotate(seqid string, position int, ref string, alt string) []*Effect {
+	var effects []*Effect
+	end := position + len(ref) - 1
+	features := annotator.annotation.Overlapping(seqid, position, end)
+
+	// coding sequences first
+	coding_genes := make(map[*gff.Feature]bool)
+	seen_cds := make(map[*gff.Feature]bool)
+	for _, feature := range features {
+		if feature.Type != "CDS" {
+			continue
+		}
+		cds := annotator.codingSequence(feature)
+		if seen_cds[cds.lines[0]] {
+			continue
+		}
+		seen_cds[cds.lines[0]] = true
+		effect := &Effect{Allele: alt}
+		geneDetails(effect, feature)
+		annotator.codingEffect(effect, feature, position, ref, alt)
+		effects = append(effects, effect)
+		if gene := feature.Ancestor("gene"); gene != nil {
+			coding_genes[gene] = true
+		}
+	}
+
+	// then any other features that belong to a gene (e.g. rRNA, tRNA) or genes without a CDS
+	for _, feature := range features {
+		if skip_types[feature.Type] || feature.Type == "repeat_region" {
+			continue
+		}
+		gene := feature.Ancestor("gene")
+		if gene == nil || coding_genes[gene] {
+			continue
+		}
+		if feature.Type == "gene" && len(feature.Children) != 0 {
+			continue
+		}
+		coding_genes[gene] = true
+		effect := &Effect{Allele: alt, Effect: "non-coding"}
+		geneDetails(effect, feature)
+		effects = append(effects, effect)
+	}
+	if len(effects) == 0 {
+		effects = append(effects, &Effect{Allele: alt, Effect: "intergenic"})
+	}
+	return effects
+}
+
+/*
+  function to escape a value for a VCF INFO field
+*/
+func escapeInfo(value string) string {
+	return strings.NewReplacer("%", "%25", " ", "%20", ";", "%3B", "=", "%3D", ",", "%2C", "|", "%7C", "\t", "%09").Replace(value)
+}
+
+/*
+  function to format an integer field (empty when not set)
+*/
+func optional(value int) string {
+	if value == 0 {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+/*
+  function to format an effect for the GSANN INFO field
+*/
+func (effect *Effect) Info() string {
+	fields := []string{effect.Allele, effect.Effect, effect.Feature_type, effect.Gene_id, effect.Locus_tag, effect.Gene_name, effect.Product, optional(effect.Codon_position), effect.Ref_codon, effect.Alt_codon, effect.Ref_aa, effect.Alt_aa, optional(effect.Aa_position)}
+	for i, field := range fields {
+		fields[i] = escapeInfo(field)
+	}
+	return strings.Join(fields, "|")
+}
+
+/*
+  function to format an effect as TSV columns
+*/
+func (effect *Effect) TSV() string {
+	return strings.Join([]string{effect.Effect, effect.Feature_type, effect.Gene_id, effect.Locus_tag, effect.Gene_name, effect.Product, optional(effect.Codon_position), effect.Ref_codon, effect.Alt_codon, effect.Ref_aa, effect.Alt_aa, optional(effect.Aa_position)}, "\t")
+}
+
+/*
+  function to annotate a VCF, writing the variant sites with the GSANN INFO field and a TSV of the effects
+
+  only sites where a sample carries an alternate allele are annotated (the all-sites VCF from bcftools call can be used as it is)
+
+  sites that failed a filter are annotated too - the TSV has their FILTER column so they can be told apart from the SNPs that passed
+*/
+func (annotator *Annotator) AnnotateVCF(reader *vcf.Reader, writer *vcf.Writer, tsv_writer io.Writer) (int, error) {
+	annotated := 0
+	if _, err := fmt.Fprintln(tsv_writer, TSVHeader); err != nil {
+		return 0, err
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return annotated, nil
+		}
+		if err != nil {
+			return annotated, err
+		}
+		if !record.IsVariant() {
+			continue
+		}
+
+		// annotate each called allele
+		var info []string
+		for i, alt := range record.Alt {
+			if strings.HasPrefix(alt, "<") {
+				continue
+			}
+			var carriers []string
+			for sample, name := range reader.Header.Samples {
+				if record.Carries(sample, i+1) {
+					carriers = append(carriers, name)
+				}
+			}
+			if len(reader.Header.Samples) == 0 {
+				carriers = []string{"."}
+			}
+			if len(carriers) == 0 {
+				continue
+			}
+			for _, effect := range annotator.Annotate(record.Chrom, record.Pos, record.Ref, alt) {
+				info = append(info, effect.Info())
+				for _, sample := range carriers {
+					if _, err := fmt.Fprintf(tsv_writer, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", sample, record.Chrom, record.Pos, record.Ref, alt, record.QualString(), record.FilterString(), effect.TSV()); err != nil {
+						return annotated, err
+					}
+				}
+			}
+		}
+		if len(info) == 0 {
+			continue
+		}
+		record.SetInfo(InfoID, strings.Join(info, ","))
+		if err := writer.Write(record); err != nil {
+			return annotated, err
+		}
+		annotated++
+	}
+}
+
+/*
+  function to annotate a VCF file (plain, gzip or BGZF), writing an annotated VCF and a TSV of the effects
+*/
+func (annotator *Annotator) AnnotateFile(vcf_file string, out_vcf string, out_tsv string) (int, error) {
+	reader, err := vcf.Open(vcf_file)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	reader.Header.AddInfo(InfoID, ".", "String", InfoDescription)
+	writer, err := vcf.Create(out_vcf, reader.Header)
+	if err != nil {
+		return 0, err
+	}
+	tsv_fh, err := os.Create(out_tsv)
+	if err != nil {
+		writer.Close()
+		return 0, err
+	}
+	defer tsv_fh.Close()
+	tsv_writer := bufio.NewWriter(tsv_fh)
+	annotated, err := annotator.AnnotateVCF(reader, writer, tsv_writer)
+	if err != nil {
+		writer.Close()
+		return annotated, fmt.Errorf("could not annotate %v: %v", vcf_file, err)
+	}
+	if err := writer.Close(); err != nil {
+		return annotated, err
+	}
+	return annotated, tsv_writer.Flush()
+}
@@ -0,0 +1,120 @@
+package annotate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/gff"
+	"github.com/will-rowe/gopherSeq/vcf"
+)
+
+// a 60 bp reference with a gene on each strand: ATG AAA GGG TAA at 11-22 (+) and ATG TTT CCC TGA at 31-42 (-)
+const test_reference = "CCCCCCCCCC" + "ATGAAAGGGTAA" + "CCCCCCCC" + "TCAGGGAAACAT" + "CCCCCCCCCCCCCCCCCC"
+
+const test_gff = `##gff-version 3
+##sequence-region chr 1 60
+chr	test	gene	11	22	.	+	.	ID=gene1;Name=plus;locus_tag=T001
+chr	test	CDS	11	22	.	+	0	ID=cds1;Parent=gene1;product=plus protein
+chr	test	gene	31	42	.	-	.	ID=gene2;Name=minus;locus_tag=T002
+chr	test	CDS	31	42	.	-	0	ID=cds2;Parent=gene2;product=minus protein
+`
+
+/*
+  function to make an annotator for the test reference
+*/
+func testAnnotator(t *testing.T) *Annotator {
+	annotation, err := gff.Parse(strings.NewReader(test_gff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(annotation, []*fasta.Sequence{{Name: "chr", Seq: []byte(test_reference)}})
+}
+
+func TestAnnotateEffects(t *testing.T) {
+	annotator := testAnnotator(t)
+	tests := []struct {
+		position  int
+		ref, alt  string
+		effect    string
+		locus_tag string
+		codon     string
+		aa        string
+	}{
+		{5, "C", "T", "intergenic", "", "", ""},
+		{14, "A", "T", "stop-gained", "T001", "AAA>TAA", "K>*"},
+		{16, "A", "G", "synonymous", "T001", "AAA>AAG", "K>K"},
+		{17, "G", "A", "non-synonymous", "T001", "GGG>AGG", "G>R"},
+		{12, "T", "C", "start-lost", "T001", "ATG>ACG", "M>T"},
+		{20, "T", "C", "stop-lost", "T001", "TAA>CAA", "*>Q"},
+		{14, "A", "AT", "frameshift", "T001", "", ""},
+		{37, "A", "G", "synonymous", "T002", "TTT>TTC", "F>F"},
+		{38, "A", "C", "non-synonymous", "T002", "TTT>TGT", "F>C"},
+	}
+	for _, test := range tests {
+		effects := annotator.Annotate("chr", test.position, test.ref, test.alt)
+		if len(effects) != 1 {
+			t.Errorf("%d %s>%s: expected one effect, found %d", test.position, test.ref, test.alt, len(effects))
+			continue
+		}
+		effect := effects[0]
+		codon, aa := "", ""
+		if len(effect.Ref_codon) != 0 {
+			codon, aa = effect.Ref_codon+">"+effect.Alt_codon, effect.Ref_aa+">"+effect.Alt_aa
+		}
+		if effect.Effect != test.effect || effect.Locus_tag != test.locus_tag || codon != test.codon || aa != test.aa {
+			t.Errorf("%d %s>%s: found %v %v %v %v, expected %v %v %v %v", test.position, test.ref, test.alt, effect.Effect, effect.Locus_tag, codon, aa, test.effect, test.locus_tag, test.codon, test.aa)
+		}
+	}
+
+	// the codon and amino acid positions count from the start of the CDS on its own strand
+	effect := annotator.Annotate("chr", 38, "A", "C")[0]
+	if effect.Codon_position != 2 || effect.Aa_position != 2 || effect.Product != "minus protein" {
+		t.Errorf("minus strand SNP is codon position %d of amino acid %d (%v)", effect.Codon_position, effect.Aa_position, effect.Product)
+	}
+}
+
+func TestAnnotateVCF(t *testing.T) {
+	annotator := testAnnotator(t)
+	text := "##fileformat=VCFv4.2\n" +
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tsample\n" +
+		"chr\t5\t.\tC\t.\t50\tPASS\tDP=10\tGT\t0/0\n" +
+		"chr\t14\t.\tA\tT\t60\tPASS\tDP=10\tGT\t1/1\n" +
+		"chr\t38\t.\tA\tC\t60\tSnpCluster\tDP=10\tGT\t1/1\n"
+	reader, err := vcf.NewReader(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Header.AddInfo(InfoID, ".", "String", InfoDescription)
+	var out_vcf, out_tsv bytes.Buffer
+	writer, err := vcf.NewWriter(&out_vcf, reader.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotated, err := annotator.AnnotateVCF(reader, writer, &out_tsv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if annotated != 2 {
+		t.Errorf("annotated %d sites, expected the 2 variant sites", annotated)
+	}
+	lines := strings.Split(strings.TrimSpace(out_tsv.String()), "\n")
+	if len(lines) != 3 || lines[0] != TSVHeader {
+		t.Fatalf("expected a header and 2 lines in the TSV, found:\n%v", out_tsv.String())
+	}
+	if !strings.HasPrefix(lines[1], "sample\tchr\t14\tA\tT\t60\tPASS\tstop-gained\t") {
+		t.Errorf("unexpected TSV line: %v", lines[1])
+	}
+
+	// sites that failed a filter are annotated, with the reason in the TSV
+	if !strings.HasPrefix(lines[2], "sample\tchr\t38\tA\tC\t60\tSnpCluster\tnon-synonymous\t") {
+		t.Errorf("unexpected TSV line for a failed site: %v", lines[2])
+	}
+	if !strings.Contains(out_vcf.String(), InfoID+"=T|stop-gained|CDS|gene1|T001|plus|plus%20protein|1|AAA|TAA|K|*|2") {
+		t.Errorf("the GSANN field is missing from the VCF:\n%v", out_vcf.String())
+	}
+}
@@ -0,0 +1,178 @@
+/*
+
+This package reads and writes BED files.
+
+Only the first six columns (chrom, start, end, name, score and strand) are used. Coordinates are kept as they are in the file (0-based, half-open).
+
+*/
+
+package bed
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Region is a single line of a BED file
+type Region struct {
+	Chrom  string
+	Start  int
+	End    int
+	Name   string
+	Score  string
+	Strand byte
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the length of a region
+*/
+func (region *Region) Length() int {
+	return region.End - region.Start
+}
+
+/*
+  function to get a label for a region (its name, or chrom:start-end using 1-based coordinates)
+*/
+func (region *Region) Label() string {
+	if len(region.Name) != 0 {
+		return region.Name
+	}
+	return fmt.Sprintf("%s:%d-%d", region.Chrom, region.Start+1, region.End)
+}
+
+/*
+  function to parse a BED line
+*/
+func parseRegion(line string) (*Region, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 3 {
+		fields = strings.Fields(line)
+	}
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("expected at least 3 columns, found %d", len(fields))
+	}
+	region := &Region{Chrom: fields[0], Strand: '.'}
+	var err error
+	if region.Start, err = strconv.Atoi(fields[1]); err != nil || region.Start < 0 {
+		return nil, fmt.Errorf("bad start: %v", fields[1])
+	}
+	if region.End, err = strconv.Atoi(fields[2]); err != nil || region.End < region.Start {
+		return nil, fmt.Errorf("bad end: %v", fields[2])
+	}
+	if len(fields) > 3 && fields[3] != "." {
+		region.Name = fields[3]
+	}
+	if len(fields) > 4 {
+		region.Score = fields[4]
+	}
+	if len(fields) > 5 {
+		switch fields[5] {
+		case "+", "-", ".":
+			region.Strand = fields[5][0]
+		default:
+			return nil, fmt.Errorf("bad strand: %v", fields[5])
+		}
+	}
+	return region, nil
+}
+
+/*
+  function to read the regions from a BED file (header, track and comment lines are skipped)
+*/
+func Parse(reader io.Reader) ([]*Region, error) {
+	var regions []*Region
+	scanner := bufio.NewScanner(reader)
+	line_number := 0
+	for scanner.Scan() {
+		line_number++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "track") || strings.HasPrefix(line, "browser") {
+			continue
+		}
+		region, err := parseRegion(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line_number, err)
+		}
+		regions = append(regions, region)
+	}
+	return regions, scanner.Err()
+}
+
+/*
+  function to read a (possibly gzipped) BED file
+*/
+func Read(file_name string) ([]*Region, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var reader io.Reader = fh
+	if strings.HasSuffix(file_name, ".gz") {
+		gz, err := gzip.NewReader(fh)
+		if err != nil {
+			return nil, fmt.Errorf("can't decompress %v: %v", file_name, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	regions, err := Parse(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file_name, err)
+	}
+	return regions, nil
+}
+
+/*
+  function to write a region as a BED line (six columns)
+*/
+func WriteRegion(writer io.Writer, region *Region) error {
+	name, score, strand := region.Name, region.Score, region.Strand
+	if len(name) == 0 {
+		name = "."
+	}
+	if len(score) == 0 {
+		score = "0"
+	}
+	if strand == 0 {
+		strand = '.'
+	}
+	_, err := fmt.Fprintf(writer, "%s\t%d\t%d\t%s\t%s\t%c\n", region.Chrom, region.Start, region.End, name, score, strand)
+	return err
+}
+
+/*
+  function to write regions to a BED file
+*/
+func WriteFile(file_name string, regions []*Region) error {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(fh)
+	for _, region := range regions {
+		if err := WriteRegion(writer, region); err != nil {
+			fh.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
@@ -0,0 +1,147 @@
+/*
+
+This package groups samples into putative transmission clusters from a SNP distance matrix (from gopherSeq distance).
+
+Samples are clustered with single-linkage at one or more SNP thresholds - two samples are in the same cluster if there is a chain of samples between them, each within the threshold of the next. Each sample is given a SNP address, its cluster at each threshold from the largest to the smallest (e.g. 1.4.12 for 25,10,5), so that samples sharing the start of their address are in the same cluster at those thresholds.
+
+With --history, the clusters (and the distances) are kept in a cluster history file between runs. New samples join the existing clusters, which keep their names - a new sample that links existing clusters merges them (keeping the oldest name). The clusters that are new, or have grown or merged since the previous run, are written to <prefix>.changes.tsv.
+
+Two files are written:
+
+ * <prefix>.membership.tsv - the cluster of each sample at each threshold, and its SNP address
+ * <prefix>.sizes.tsv - the size and samples of each cluster
+
+*/
+
+package cluster
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/distance"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up command line arguments
+var args struct {
+	Input      string `arg:"positional,required,help:SNP distance matrix (from gopherSeq distance - TSV or CSV)"`
+	Thresholds string `arg:"-t,help:SNP thresholds to cluster at - separated by commas"`
+	Output     string `arg:"-o,help:prefix for the output files [default: ./clusters]"`
+	History    string `arg:"help:cluster history file (JSON) - keeps the cluster names between runs and is updated with the new samples"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tclusters samples at SNP thresholds and gives each one a SNP address\n\nusage:\n\tgopherSeq cluster [options] DISTANCE_MATRIX\n\nhelp:\n\tgopherSeq cluster --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to write a table to a file
+*/
+func writeFile(file_name string, write func(io.Writer) error) error {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	writer := bufio.NewWriter(fh)
+	if err := write(writer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	args.Thresholds, args.Output = "25,10,5", "./clusters"
+	arg.MustParse(&args)
+	thresholds, err := ParseThresholds(args.Thresholds)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// cluster the samples
+	matrix, err := distance.ReadMatrix(args.Input)
+	if err != nil {
+		fmt.Printf("could not read distance matrix: %v\n", err)
+		os.Exit(1)
+	}
+	var clustering *Clustering
+	var history *History
+	var changes []*Change
+	if len(args.History) == 0 {
+		clustering = SingleLinkage(matrix, thresholds)
+	} else {
+		if history, err = LoadHistory(args.History); err != nil {
+			fmt.Printf("could not load cluster history: %v\n", err)
+			os.Exit(1)
+		}
+		previous := len(history.Samples)
+		if clustering, changes, err = history.Update(matrix, thresholds, time.Now().Format(time.RFC3339)); err != nil {
+			fmt.Printf("could not update cluster history: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf(" * cluster history --> %d previous samples and %d new samples\n", previous, len(history.Samples)-previous)
+	}
+
+	// write the clusters
+	if err := writeFile(args.Output+".membership.tsv", clustering.WriteMembership); err != nil {
+		fmt.Printf("could not write cluster membership: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeFile(args.Output+".sizes.tsv", clustering.WriteSizes); err != nil {
+		fmt.Printf("could not write cluster sizes: %v\n", err)
+		os.Exit(1)
+	}
+	if history != nil {
+		if err := writeFile(args.Output+".changes.tsv", func(writer io.Writer) error { return WriteChanges(writer, changes) }); err != nil {
+			fmt.Printf("could not write cluster changes: %v\n", err)
+			os.Exit(1)
+		}
+		if err := history.Save(args.History); err != nil {
+			fmt.Printf("could not save cluster history: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	clusters, singletons := clustering.Counts()
+	for t, threshold := range thresholds {
+		fmt.Printf(" * %d SNPs --> %d clusters and %d singletons\n", threshold, clusters[t], singletons[t])
+	}
+	fmt.Printf(" * cluster membership and SNP addresses --> %v.membership.tsv\n", args.Output)
+	fmt.Printf(" * cluster sizes --> %v.sizes.tsv\n", args.Output)
+	if history != nil {
+		statuses := make(map[string]int)
+		for _, change := range changes {
+			statuses[change.Status]++
+		}
+		fmt.Printf(" * cluster changes --> %v.changes.tsv (%d new, %d grew, %d merged, %d split)\n", args.Output, statuses["new"], statuses["grew"], statuses["merged"], statuses["split"])
+		fmt.Printf(" * cluster history --> %v\n", args.History)
+	}
+}
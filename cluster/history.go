@@ -0,0 +1,281 @@
+package cluster
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/distance"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// History is the cluster history kept between runs, so that clusters keep their names as new samples are added
+type History struct {
+	Thresholds []int      `json:"thresholds"`
+	Samples    []string   `json:"samples"`
+	Distances  [][]int    `json:"distances"`
+	Clusters   [][]int    `json:"clusters"`
+	Next       []int      `json:"next_cluster"`
+	Runs       []*RunInfo `json:"runs"`
+}
+
+// RunInfo records a run that updated the cluster history
+type RunInfo struct {
+	Date        string `json:"date"`
+	Samples     int    `json:"samples"`
+	New_samples int    `json:"new_samples"`
+}
+
+// Change is a cluster that is new, or has grown, merged or split since the previous run
+type Change struct {
+	Threshold     int
+	Cluster       int
+	Status        string
+	Size          int
+	Previous_size int
+	New_samples   []string
+	Merged        []int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to load a cluster history (an empty history if the file doesn't exist yet)
+*/
+func LoadHistory(file_name string) (*History, error) {
+	history := &History{}
+	fh, err := os.Open(file_name)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	if err := json.NewDecoder(fh).Decode(history); err != nil {
+		return nil, fmt.Errorf("%v: %v", file_name, err)
+	}
+	if len(history.Clusters) != len(history.Thresholds) || len(history.Next) != len(history.Thresholds) || len(history.Distances) != len(history.Samples) {
+		return nil, fmt.Errorf("%v: not a gopherSeq cluster history", file_name)
+	}
+	for t := range history.Clusters {
+		if len(history.Clusters[t]) != len(history.Samples) {
+			return nil, fmt.Errorf("%v: not a gopherSeq cluster history", file_name)
+		}
+	}
+	return history, nil
+}
+
+/*
+  function to save the cluster history (written to a temporary file first, so a failed run doesn't lose the previous history)
+*/
+func (history *History) Save(file_name string) error {
+	tmp_file := file_name + ".tmp"
+	fh, err := os.Create(tmp_file)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(fh).Encode(history); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp_file, file_name)
+}
+
+/*
+  function to combine the samples in the history with those in a new distance matrix
+
+  the new distances are used where there are any, so every new sample needs a distance to every sample in the history (i.e. the new matrix should be made from the pseudogenomes of all the samples so far)
+*/
+func (history *History) combine(matrix *distance.Matrix) (*distance.Matrix, error) {
+	index := make(map[string]int)
+	for i, name := range matrix.Names {
+		index[name] = i
+	}
+	combined := &distance.Matrix{Names: append([]string(nil), history.Samples...)}
+	seen := make(map[string]bool)
+	for _, name := range history.Samples {
+		seen[name] = true
+	}
+	for _, name := range matrix.Names {
+		if !seen[name] {
+			combined.Names = append(combined.Names, name)
+		}
+	}
+	n := len(combined.Names)
+	combined.Distances = make([][]int, n)
+	for i := range combined.Distances {
+		combined.Distances[i] = make([]int, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			a, a_ok := index[combined.Names[i]]
+			b, b_ok := index[combined.Names[j]]
+			switch {
+			case a_ok && b_ok:
+				combined.Distances[i][j] = matrix.Distances[a][b]
+			case j < len(history.Samples):
+				combined.Distances[i][j] = history.Distances[i][j]
+			default:
+				return nil, fmt.Errorf("there is no distance between %v and %v - the distance matrix must include the samples in the cluster history", combined.Names[i], combined.Names[j])
+			}
+			combined.Distances[j][i] = combined.Distances[i][j]
+		}
+	}
+	return combined, nil
+}
+
+/*
+  function to cluster the samples in a new distance matrix along with those in the history, keeping the cluster names from the previous run
+
+  at each threshold, a cluster keeps the name of the (oldest) previous cluster in it - a cluster that links previous clusters has merged them, and a new cluster with no previous samples gets the next name. The history is updated with the new samples, distances and clusters
+*/
+func (history *History) Update(matrix *distance.Matrix, thresholds []int, date string) (*Clustering, []*Change, error) {
+	if len(history.Samples) == 0 && len(history.Thresholds) == 0 {
+		history.Thresholds = thresholds
+		history.Clusters = make([][]int, len(thresholds))
+		history.Next = make([]int, len(thresholds))
+		for t := range history.Next {
+			history.Next[t] = 1
+		}
+	}
+	if joinInts(history.Thresholds, ",") != joinInts(thresholds, ",") {
+		return nil, nil, fmt.Errorf("the cluster history uses the SNP thresholds %v, not %v", joinInts(history.Thresholds, ","), joinInts(thresholds, ","))
+	}
+	combined, err := history.combine(matrix)
+	if err != nil {
+		return nil, nil, err
+	}
+	previous := len(history.Samples)
+	clustering := &Clustering{Names: combined.Names, Thresholds: thresholds}
+	var changes []*Change
+	for t, threshold := range thresholds {
+		previous_clusters := history.Clusters[t]
+		previous_sizes := make(map[int]int)
+		for _, cluster := range previous_clusters {
+			previous_sizes[cluster]++
+		}
+		clusters := make([]int, len(combined.Names))
+		claimed := make(map[int]bool)
+		for _, group := range linkage(combined, threshold) {
+			// get the previous clusters of the samples in this cluster
+			var names []int
+			var new_samples []string
+			for _, sample := range group {
+				if sample < previous {
+					names = append(names, previous_clusters[sample])
+				} else {
+					new_samples = append(new_samples, combined.Names[sample])
+				}
+			}
+			names = uniqueInts(names)
+
+			// keep the oldest name that hasn't been taken by a larger part of a split cluster
+			change := &Change{Threshold: threshold, Size: len(group), New_samples: new_samples}
+			for _, name := range names {
+				if !claimed[name] {
+					change.Cluster = name
+					break
+				}
+			}
+			switch {
+			case len(names) == 0:
+				change.Status = "new"
+			case change.Cluster == 0:
+				change.Status = "split"
+			case len(names) > 1:
+				change.Status = "merged"
+			case len(group) > previous_sizes[change.Cluster]:
+				change.Status = "grew"
+			}
+			if change.Cluster == 0 {
+				change.Cluster = history.Next[t]
+				history.Next[t]++
+			} else {
+				claimed[change.Cluster] = true
+				change.Previous_size = previous_sizes[change.Cluster]
+			}
+			for _, name := range names {
+				if name != change.Cluster {
+					change.Merged = append(change.Merged, name)
+				}
+			}
+			if change.Status == "split" {
+				change.Merged = nil
+			}
+			for _, sample := range group {
+				clusters[sample] = change.Cluster
+			}
+			if len(change.Status) != 0 {
+				changes = append(changes, change)
+			}
+		}
+		clustering.Clusters = append(clustering.Clusters, clusters)
+	}
+
+	// update the history
+	history.Samples, history.Distances, history.Clusters = combined.Names, combined.Distances, clustering.Clusters
+	history.Runs = append(history.Runs, &RunInfo{Date: date, Samples: len(combined.Names), New_samples: len(combined.Names) - previous})
+	return clustering, changes, nil
+}
+
+/*
+  function to sort a list of cluster names and remove duplicates
+*/
+func uniqueInts(values []int) []int {
+	sort.Ints(values)
+	var unique []int
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+/*
+  function to join a list of numbers with a separator
+*/
+func joinInts(values []int, separator string) string {
+	var fields []string
+	for _, value := range values {
+		fields = append(fields, strconv.Itoa(value))
+	}
+	return strings.Join(fields, separator)
+}
+
+/*
+  function to write the clusters that are new, or have grown, merged or split since the previous run
+*/
+func WriteChanges(writer io.Writer, changes []*Change) error {
+	if _, err := fmt.Fprintf(writer, "threshold\tcluster\tstatus\tsize\tprevious_size\tmerged_from\tnew_samples\n"); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		merged := joinInts(change.Merged, ",")
+		if len(merged) == 0 {
+			merged = "-"
+		}
+		new_samples := strings.Join(change.New_samples, ",")
+		if len(new_samples) == 0 {
+			new_samples = "-"
+		}
+		if _, err := fmt.Fprintf(writer, "%d\t%d\t%s\t%d\t%d\t%s\t%s\n", change.Threshold, change.Cluster, change.Status, change.Size, change.Previous_size, merged, new_samples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/distance"
+)
+
+/*
+  function to update a cluster history and write the changes
+*/
+func updateHistory(t *testing.T, history *History, matrix *distance.Matrix) (*Clustering, string) {
+	clustering, changes, err := history.Update(matrix, []int{10, 5}, "2020-01-01T00:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if err := WriteChanges(&buffer, changes); err != nil {
+		t.Fatal(err)
+	}
+	return clustering, buffer.String()
+}
+
+func TestUpdateHistory(t *testing.T) {
+	history := &History{}
+	clustering, changes := updateHistory(t, history, testMatrix("a", "b", "c", "d"))
+	if !reflect.DeepEqual(clustering.Clusters, [][]int{{1, 1, 1, 2}, {1, 1, 2, 3}}) {
+		t.Errorf("unexpected clusters in the first run: %v", clustering.Clusters)
+	}
+	if changes != "threshold\tcluster\tstatus\tsize\tprevious_size\tmerged_from\tnew_samples\n10\t1\tnew\t3\t0\t-\ta,b,c\n10\t2\tnew\t1\t0\t-\td\n5\t1\tnew\t2\t0\t-\ta,b\n5\t2\tnew\t1\t0\t-\tc\n5\t3\tnew\t1\t0\t-\td\n" {
+		t.Errorf("unexpected changes in the first run:\n%v", changes)
+	}
+
+	// f links the clusters at 10 SNPs (keeping the oldest name) and e joins d at 5 SNPs
+	clustering, changes = updateHistory(t, history, testMatrix("a", "b", "c", "d", "e", "f"))
+	if !reflect.DeepEqual(clustering.Names, []string{"a", "b", "c", "d", "e", "f"}) || !reflect.DeepEqual(clustering.Clusters, [][]int{{1, 1, 1, 1, 1, 1}, {1, 1, 2, 3, 3, 2}}) {
+		t.Errorf("unexpected clusters in the second run: %v %v", clustering.Names, clustering.Clusters)
+	}
+	if changes != "threshold\tcluster\tstatus\tsize\tprevious_size\tmerged_from\tnew_samples\n10\t1\tmerged\t6\t3\t2\te,f\n5\t2\tgrew\t2\t1\t-\tf\n5\t3\tgrew\t2\t1\t-\te\n" {
+		t.Errorf("unexpected changes in the second run:\n%v", changes)
+	}
+
+	// new distances replace those in the history, so a and b are split at 5 SNPs (b gets the next name)
+	clustering, changes = updateHistory(t, history, &distance.Matrix{Names: []string{"a", "b"}, Distances: [][]int{{0, 7}, {7, 0}}})
+	if !reflect.DeepEqual(clustering.Clusters, [][]int{{1, 1, 1, 1, 1, 1}, {1, 4, 2, 3, 3, 2}}) {
+		t.Errorf("unexpected clusters in the third run: %v", clustering.Clusters)
+	}
+	if changes != "threshold\tcluster\tstatus\tsize\tprevious_size\tmerged_from\tnew_samples\n5\t4\tsplit\t1\t0\t-\t-\n" {
+		t.Errorf("unexpected changes in the third run:\n%v", changes)
+	}
+	if len(history.Runs) != 3 || history.Runs[1].Samples != 6 || history.Runs[1].New_samples != 2 || !reflect.DeepEqual(history.Next, []int{3, 5}) {
+		t.Errorf("unexpected history: %+v (runs %d)", history, len(history.Runs))
+	}
+}
+
+func TestUpdateHistoryErrors(t *testing.T) {
+	history := &History{}
+	updateHistory(t, history, testMatrix("a", "b", "c"))
+	if _, _, err := history.Update(testMatrix("a", "b", "c"), []int{25, 10}, ""); err == nil {
+		t.Error("the history was updated with different SNP thresholds")
+	}
+
+	// every new sample needs a distance to the samples in the history
+	if _, _, err := history.Update(testMatrix("a", "d"), []int{10, 5}, ""); err == nil {
+		t.Error("the history was updated without the distances to a new sample")
+	}
+	if len(history.Samples) != 3 || len(history.Runs) != 1 {
+		t.Errorf("a failed update changed the history: %+v", history)
+	}
+}
+
+func TestSaveHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file_name := path.Join(dir, "history.json")
+	history, err := LoadHistory(file_name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updateHistory(t, history, testMatrix("a", "b", "c", "d"))
+	if err := history.Save(file_name); err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadHistory(file_name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, history) {
+		t.Errorf("the history changed when it was read back in: %+v", again)
+	}
+	bad := map[string]string{
+		"not json":           "thresholds: 10,5\n",
+		"missing clusters":   `{"thresholds":[10,5],"samples":["a"],"distances":[[0]],"clusters":[[1]],"next_cluster":[2,2]}`,
+		"too many distances": `{"thresholds":[10],"samples":["a"],"distances":[[0],[0]],"clusters":[[1]],"next_cluster":[2]}`,
+	}
+	for name, text := range bad {
+		if err := ioutil.WriteFile(file_name, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadHistory(file_name); err == nil {
+			t.Errorf("a history file with %v was accepted", name)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package cluster
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/distance"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Clustering is the cluster each sample is in at each SNP threshold
+type Clustering struct {
+	Names []string
+
+	// the SNP thresholds, from the largest to the smallest (the order of the SNP address)
+	Thresholds []int
+
+	// the cluster of each sample at each threshold (Clusters[threshold][sample]), numbered from 1 by size (or kept from the cluster history)
+	Clusters [][]int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to parse a list of SNP thresholds separated by commas (e.g. 25,10,5), giving them from the largest to the smallest
+*/
+func ParseThresholds(list string) ([]int, error) {
+	var thresholds []int
+	seen := make(map[int]bool)
+	for _, field := range strings.Split(list, ",") {
+		threshold, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("bad SNP threshold: %v", field)
+		}
+		if !seen[threshold] {
+			thresholds = append(thresholds, threshold)
+			seen[threshold] = true
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(thresholds)))
+	return thresholds, nil
+}
+
+/*
+  function to find the root of a sample in a union-find forest
+*/
+func find(parents []int, i int) int {
+	for parents[i] != i {
+		parents[i] = parents[parents[i]]
+		i = parents[i]
+	}
+	return i
+}
+
+/*
+  function to cluster the samples at a threshold with single-linkage (samples are in the same cluster if there is a chain of samples between them, each within the threshold of the next)
+
+  the clusters are given from the largest to the smallest (ties are broken by the first sample name)
+*/
+func linkage(matrix *distance.Matrix, threshold int) [][]int {
+	n := len(matrix.Names)
+	parents := make([]int, n)
+	for i := range parents {
+		parents[i] = i
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if matrix.Distances[i][j] <= threshold {
+				parents[find(parents, i)] = find(parents, j)
+			}
+		}
+	}
+
+	// group the samples by cluster and sort the clusters
+	members := make(map[int][]int)
+	for i := 0; i < n; i++ {
+		root := find(parents, i)
+		members[root] = append(members[root], i)
+	}
+	var groups [][]int
+	first := make(map[int]string)
+	for _, samples := range members {
+		groups = append(groups, samples)
+		first[samples[0]] = matrix.Names[samples[0]]
+		for _, sample := range samples {
+			if matrix.Names[sample] < first[samples[0]] {
+				first[samples[0]] = matrix.Names[sample]
+			}
+		}
+	}
+	sort.Slice(groups, func(a, b int) bool {
+		if len(groups[a]) != len(groups[b]) {
+			return len(groups[a]) > len(groups[b])
+		}
+		return first[groups[a][0]] < first[groups[b][0]]
+	})
+	return groups
+}
+
+/*
+  function to cluster the samples at a threshold, numbering the clusters from 1 (the largest)
+*/
+func singleLinkage(matrix *distance.Matrix, threshold int) []int {
+	clusters := make([]int, len(matrix.Names))
+	for number, group := range linkage(matrix, threshold) {
+		for _, sample := range group {
+			clusters[sample] = number + 1
+		}
+	}
+	return clusters
+}
+
+/*
+  function to cluster the samples at each threshold
+*/
+func SingleLinkage(matrix *distance.Matrix, thresholds []int) *Clustering {
+	clustering := &Clustering{Names: matrix.Names, Thresholds: thresholds}
+	for _, threshold := range thresholds {
+		clustering.Clusters = append(clustering.Clusters, singleLinkage(matrix, threshold))
+	}
+	return clustering
+}
+
+/*
+  function to get the SNP address of a sample - its cluster at each threshold, from the largest to the smallest (e.g. 1.4.12)
+
+  single-linkage clusters at a smaller threshold are always inside a cluster at a larger threshold, so samples that share the start of their address are in the same cluster at those thresholds
+*/
+func (clustering *Clustering) Address(sample int) string {
+	var fields []string
+	for _, clusters := range clustering.Clusters {
+		fields = append(fields, strconv.Itoa(clusters[sample]))
+	}
+	return strings.Join(fields, ".")
+}
+
+/*
+  function to get the number of samples in each cluster at a threshold (indexed by cluster number - clusters that were merged into another have none)
+*/
+func (clustering *Clustering) sizes(threshold_index int) []int {
+	sizes := make([]int, 1)
+	for _, cluster := range clustering.Clusters[threshold_index] {
+		for len(sizes) <= cluster {
+			sizes = append(sizes, 0)
+		}
+		sizes[cluster]++
+	}
+	return sizes
+}
+
+/*
+  function to write the cluster of each sample at each threshold, and its SNP address
+*/
+func (clustering *Clustering) WriteMembership(writer io.Writer) error {
+	header := []string{"sample"}
+	for _, threshold := range clustering.Thresholds {
+		header = append(header, fmt.Sprintf("t%d", threshold))
+	}
+	header = append(header, "snp_address")
+	if _, err := fmt.Fprintf(writer, "%s\n", strings.Join(header, "\t")); err != nil {
+		return err
+	}
+	for i, name := range clustering.Names {
+		fields := []string{name}
+		for _, clusters := range clustering.Clusters {
+			fields = append(fields, strconv.Itoa(clusters[i]))
+		}
+		fields = append(fields, clustering.Address(i))
+		if _, err := fmt.Fprintf(writer, "%s\n", strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+  function to write the size and the samples of each cluster at each threshold
+*/
+func (clustering *Clustering) WriteSizes(writer io.Writer) error {
+	if _, err := fmt.Fprintf(writer, "threshold\tcluster\tsize\tsamples\n"); err != nil {
+		return err
+	}
+	for t, threshold := range clustering.Thresholds {
+		sizes := clustering.sizes(t)
+		for cluster := 1; cluster < len(sizes); cluster++ {
+			if sizes[cluster] == 0 {
+				continue
+			}
+			var samples []string
+			for i, name := range clustering.Names {
+				if clustering.Clusters[t][i] == cluster {
+					samples = append(samples, name)
+				}
+			}
+			if _, err := fmt.Fprintf(writer, "%d\t%d\t%d\t%s\n", threshold, cluster, sizes[cluster], strings.Join(samples, ",")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+  function to count the clusters with more than one sample, and the singletons, at each threshold
+*/
+func (clustering *Clustering) Counts() ([]int, []int) {
+	clusters, singletons := make([]int, len(clustering.Thresholds)), make([]int, len(clustering.Thresholds))
+	for t := range clustering.Thresholds {
+		for _, size := range clustering.sizes(t)[1:] {
+			if size > 1 {
+				clusters[t]++
+			} else if size == 1 {
+				singletons[t]++
+			}
+		}
+	}
+	return clusters, singletons
+}
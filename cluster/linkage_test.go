@@ -0,0 +1,89 @@
+package cluster
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/distance"
+)
+
+// the SNP distances between six samples (f links the a,b,c cluster to d,e at 10 SNPs)
+var test_distances = map[string]int{
+	"ab": 3, "ac": 11, "ad": 30, "ae": 40, "af": 9,
+	"bc": 8, "bd": 30, "be": 40, "bf": 10,
+	"cd": 30, "ce": 40, "cf": 2,
+	"de": 4, "df": 9,
+	"ef": 13,
+}
+
+/*
+  function to get a distance matrix for some of the test samples
+*/
+func testMatrix(names ...string) *distance.Matrix {
+	matrix := &distance.Matrix{Names: names, Distances: make([][]int, len(names))}
+	for i := range names {
+		matrix.Distances[i] = make([]int, len(names))
+		for j := range names {
+			if snps, ok := test_distances[names[i]+names[j]]; ok {
+				matrix.Distances[i][j] = snps
+			} else if snps, ok := test_distances[names[j]+names[i]]; ok {
+				matrix.Distances[i][j] = snps
+			}
+		}
+	}
+	return matrix
+}
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := ParseThresholds("5, 25,10,10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(thresholds, []int{25, 10, 5}) {
+		t.Errorf("unexpected thresholds: %v", thresholds)
+	}
+	for _, list := range []string{"", "5,,10", "-1", "ten"} {
+		if _, err := ParseThresholds(list); err == nil {
+			t.Errorf("the thresholds %q were accepted", list)
+		}
+	}
+}
+
+func TestSingleLinkage(t *testing.T) {
+	clustering := SingleLinkage(testMatrix("a", "b", "c", "d", "e"), []int{25, 10, 5})
+	expected := [][]int{{1, 1, 1, 2, 2}, {1, 1, 1, 2, 2}, {1, 1, 3, 2, 2}}
+	if !reflect.DeepEqual(clustering.Clusters, expected) {
+		t.Errorf("unexpected clusters: %v", clustering.Clusters)
+	}
+	if clustering.Address(2) != "1.1.3" || clustering.Address(4) != "2.2.2" {
+		t.Errorf("unexpected SNP addresses: %v and %v", clustering.Address(2), clustering.Address(4))
+	}
+	clusters, singletons := clustering.Counts()
+	if !reflect.DeepEqual(clusters, []int{2, 2, 2}) || !reflect.DeepEqual(singletons, []int{0, 0, 1}) {
+		t.Errorf("counted %v clusters and %v singletons", clusters, singletons)
+	}
+
+	// f is within 10 SNPs of c and d, so single-linkage chains all the samples together
+	chained := SingleLinkage(testMatrix("a", "b", "c", "d", "e", "f"), []int{10})
+	if !reflect.DeepEqual(chained.Clusters[0], []int{1, 1, 1, 1, 1, 1}) {
+		t.Errorf("unexpected clusters with f: %v", chained.Clusters[0])
+	}
+}
+
+func TestWriteClusters(t *testing.T) {
+	clustering := SingleLinkage(testMatrix("a", "b", "c", "d", "e"), []int{25, 10, 5})
+	var membership, sizes bytes.Buffer
+	if err := clustering.WriteMembership(&membership); err != nil {
+		t.Fatal(err)
+	}
+	if membership.String() != "sample\tt25\tt10\tt5\tsnp_address\na\t1\t1\t1\t1.1.1\nb\t1\t1\t1\t1.1.1\nc\t1\t1\t3\t1.1.3\nd\t2\t2\t2\t2.2.2\ne\t2\t2\t2\t2.2.2\n" {
+		t.Errorf("unexpected membership:\n%v", membership.String())
+	}
+	if err := clustering.WriteSizes(&sizes); err != nil {
+		t.Fatal(err)
+	}
+	if sizes.String() != "threshold\tcluster\tsize\tsamples\n25\t1\t3\ta,b,c\n25\t2\t2\td,e\n10\t1\t3\ta,b,c\n10\t2\t2\td,e\n5\t1\t2\ta,b\n5\t2\t2\td,e\n5\t3\t1\tc\n" {
+		t.Errorf("unexpected sizes:\n%v", sizes.String())
+	}
+}
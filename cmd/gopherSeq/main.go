@@ -12,8 +12,21 @@ import (
 	"sort"
 
 	"github.com/will-rowe/gopherSeq/align"
+	"github.com/will-rowe/gopherSeq/annotate"
+	"github.com/will-rowe/gopherSeq/cluster"
+	"github.com/will-rowe/gopherSeq/consensus"
+	"github.com/will-rowe/gopherSeq/distance"
 	"github.com/will-rowe/gopherSeq/envtest"
+	"github.com/will-rowe/gopherSeq/export"
+	"github.com/will-rowe/gopherSeq/extract"
+	"github.com/will-rowe/gopherSeq/filter"
+	"github.com/will-rowe/gopherSeq/liftover"
+	"github.com/will-rowe/gopherSeq/nj"
 	"github.com/will-rowe/gopherSeq/qcheck"
+	"github.com/will-rowe/gopherSeq/recomb"
+	"github.com/will-rowe/gopherSeq/reference"
+	"github.com/will-rowe/gopherSeq/snpalign"
+	"github.com/will-rowe/gopherSeq/tree"
 	"github.com/will-rowe/gopherSeq/version"
 )
 
@@ -27,10 +40,23 @@ type package_info struct {
 
 // set up a map for all the packages in gopher-seq
 var packages = map[string]package_info{
-	"qcheck":  package_info{"\tquality check WGS data", qcheck.Main},
-	"align":   package_info{"\talign, SNPcall and generate pseudogenome for WGS data", align.Main},
-	"envtest": package_info{"\ttest runtime environment for required software", envtest.Main},
-	"version": package_info{"\tprints version and exits", version.Main},
+	"qcheck":    package_info{"\tquality check WGS data", qcheck.Main},
+	"align":     package_info{"\talign, SNPcall and generate pseudogenome for WGS data", align.Main},
+	"annotate":  package_info{"\tannotate called SNPs with the genes they hit", annotate.Main},
+	"cluster":   package_info{"\tcluster samples at SNP thresholds and give them SNP addresses", cluster.Main},
+	"consensus": package_info{"\tbuild a pseudogenome from an all-sites VCF", consensus.Main},
+	"distance":  package_info{"\tpairwise SNP distances from pseudogenomes or an alignment", distance.Main},
+	"envtest":   package_info{"\ttest runtime environment for required software", envtest.Main},
+	"export":    package_info{"\texport a tree with metadata and clusters for Microreact and Auspice", export.Main},
+	"extract":   package_info{"\textract genes from pseudogenomes", extract.Main},
+	"filter":    package_info{"\tfilter variant calls (quality, depth, strand bias and SNP density)", filter.Main},
+	"liftover":  package_info{"\tmap features between a reference and a sample consensus", liftover.Main},
+	"nj":        package_info{"\tbuild a neighbour-joining tree from SNP distances", nj.Main},
+	"recomb":    package_info{"\tmask recombinant blocks in a whole genome alignment", recomb.Main},
+	"reference": package_info{"\tprepare and manage reference sequences", reference.Main},
+	"snpalign":  package_info{"\tbuild whole genome and core SNP alignments from pseudogenomes", snpalign.Main},
+	"tree":      package_info{"\treroot, prune, rename, compare and draw Newick trees", tree.Main},
+	"version":   package_info{"\tprints version and exits", version.Main},
 }
 
 // create a function to print info on our packages
@@ -0,0 +1,645 @@
+package consensus
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/dna"
+	"github.com/will-rowe/gopherSeq/liftover"
+	"github.com/will-rowe/gopherSeq/vcf"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// line width of the pseudogenome (as vcf2fa)
+const line_width = 60
+
+// highest base quality in the FASTQ (phred+33 '~', as vcf2fq)
+const max_quality = 93
+
+// the patterns vcf2fa uses on the ALT and INFO columns
+var (
+	snp_alt    = regexp.MustCompile(`^([A-Za-z.])(,[A-Za-z])*$`)
+	fq_info    = regexp.MustCompile(`FQ=(-?[\d\.]+)`)
+	af1_info   = regexp.MustCompile(`AF1=([\d\.]+)`)
+	mq_info    = regexp.MustCompile(`MQ=(\d+)`)
+	depth_info = regexp.MustCompile(`DP=(\d+)`)
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Options control how the pseudogenome is built
+type Options struct {
+	Min_depth    int
+	Max_depth    int
+	Min_mq       int
+	Min_qual     float64
+	Min_af       float64
+	Indel_window int
+	Mask         byte
+
+	// sites where the minor allele fraction is at least Mixed_af are counted as mixed (0 to turn off), and masked or given an IUPAC code
+	Mixed_af    float64
+	Mixed_iupac bool
+
+	// contig lengths to use when the VCF header has no ##contig lines
+	Lengths map[string]int
+
+	// reference regions that are masked in the pseudogenome (e.g. repeats and prophages)
+	Regions Regions
+
+	// apply the called indels to give the sample consensus (instead of a pseudogenome in reference coordinates)
+	Indels bool
+}
+
+// Stats summarises a pseudogenome
+type Stats struct {
+	Length int
+	Called int
+	Masked int
+	Mixed  int
+
+	// positions masked because they are in one of the Regions
+	Region_masked int
+
+	// indels applied to the consensus, and the chains from the reference to the consensus (if Indels is set)
+	Indels int
+	Chains liftover.Chains
+
+	// the number of bases with each quality (0 to 93 - masked bases have a quality of 0)
+	Qualities []int
+}
+
+// contig is a pseudogenome sequence as it is built
+type contig struct {
+	name   string
+	seq    []byte
+	qual   []byte
+	gaps   [][2]int
+	indels []indel
+}
+
+// indel is a called indel to apply to the consensus
+type indel struct {
+	pos     int
+	ref     string
+	alt     string
+	quality byte
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the default options (the same as vcfutils.pl vcf2fa)
+*/
+func DefaultOptions() *Options {
+	return &Options{Min_depth: 3, Max_depth: 100000, Min_mq: 10, Indel_window: 5, Mask: 'N'}
+}
+
+/*
+  function to convert a string to a number the way perl does (the longest numeric prefix, 0 if there isn't one)
+*/
+func perlNumber(value string) float64 {
+	for end := len(value); end > 0; end-- {
+		if number, err := strconv.ParseFloat(value[:end], 64); err == nil {
+			return number
+		}
+	}
+	return 0
+}
+
+/*
+  function to get the number captured by a pattern in the INFO column (and whether it matched)
+*/
+func infoNumber(pattern *regexp.Regexp, info string) (float64, bool) {
+	match := pattern.FindStringSubmatch(info)
+	if match == nil {
+		return 0, false
+	}
+	return perlNumber(match[1]), true
+}
+
+/*
+  function to format the INFO column of a record as it was in the file
+*/
+func infoString(record *vcf.Record) string {
+	if len(record.Info) == 0 {
+		return "."
+	}
+	pairs := make([]string, len(record.Info))
+	for i, field := range record.Info {
+		if field.Flag {
+			pairs[i] = field.Key
+		} else {
+			pairs[i] = field.Key + "=" + field.Value
+		}
+	}
+	return strings.Join(pairs, ";")
+}
+
+/*
+  function to get the quality of a call - the consensus quality (FQ, as vcf2fq) or QUAL if there's no FQ, capped at 93
+*/
+func callQuality(record *vcf.Record, info string) byte {
+	quality, ok := infoNumber(fq_info, info)
+	if !ok {
+		quality = record.Qual
+	}
+	if quality < 0 {
+		quality = -quality
+	}
+	quality = float64(int(quality + .499))
+	if quality > max_quality {
+		return max_quality
+	}
+	return byte(quality)
+}
+
+/*
+  function to get the name of a contig in the output - "_" + contig name, as vcf2fa (the chain from the reference uses this name too)
+*/
+func (current *contig) header() string {
+	return "_" + current.name
+}
+
+/*
+  function to add a base (and its quality) to a contig
+*/
+func (current *contig) add(base byte, quality byte) {
+	current.seq = append(current.seq, base)
+	current.qual = append(current.qual, quality)
+}
+
+/*
+  function to get the fraction of high-quality reads (DP4) supporting the called base
+*/
+func supportFraction(record *vcf.Record, alt_called bool) (float64, bool) {
+	dp4, ok := record.DP4()
+	if !ok || dp4[0]+dp4[1]+dp4[2]+dp4[3] == 0 {
+		return 0, false
+	}
+	total := float64(dp4[0] + dp4[1] + dp4[2] + dp4[3])
+	if alt_called {
+		return float64(dp4[2]+dp4[3]) / total, true
+	}
+	return float64(dp4[0]+dp4[1]) / total, true
+}
+
+/*
+  function to get the fraction of reads supporting the minor allele at a site (from DP4, or from the AD of the first sample)
+
+  haploid calls are forced to a single base, so this is how mixed sites (from contamination or a mixed-strain sample) are found
+*/
+func minorFraction(record *vcf.Record) (float64, bool) {
+	if dp4, ok := record.DP4(); ok {
+		ref, alt := dp4[0]+dp4[1], dp4[2]+dp4[3]
+		if ref+alt == 0 {
+			return 0, false
+		}
+		if ref < alt {
+			return float64(ref) / float64(ref+alt), true
+		}
+		return float64(alt) / float64(ref+alt), true
+	}
+	value, ok := record.SampleValue(0, "AD")
+	if !ok {
+		return 0, false
+	}
+	total, major := 0, 0
+	for _, part := range strings.Split(value, ",") {
+		count, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, false
+		}
+		total += count
+		if count > major {
+			major = count
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(total-major) / float64(total), true
+}
+
+/*
+  function to call the pseudogenome base for a SNP or reference site (the vcf2fa logic, with the extra quality, allele fraction and mixed site options)
+*/
+func (options *Options) callBase(record *vcf.Record, info string) (byte, bool) {
+	ref, alt := record.Ref[0], byte('.')
+	if len(record.Alt) != 0 {
+		alt = record.Alt[0][0]
+	}
+
+	// haploid calls from bcftools call -c have a negative FQ, anything else is a het and is masked
+	var base byte
+	fq, _ := infoNumber(fq_info, info)
+	alt_called := false
+	if fq < 0 {
+		af1, _ := infoNumber(af1_info, info)
+		if af1 < .5 || alt == '.' {
+			base = ref
+		} else {
+			base = alt
+			alt_called = true
+		}
+	} else {
+		base = options.Mask
+	}
+
+	// keep the base if it passes the filters
+	pass := false
+	if mq, ok := infoNumber(mq_info, info); ok && int(mq) >= options.Min_mq {
+		if depth, ok := infoNumber(depth_info, info); ok && int(depth) >= options.Min_depth && int(depth) <= options.Max_depth {
+			pass = true
+		}
+	}
+	if pass && alt_called && options.Min_qual > 0 && record.Qual < options.Min_qual {
+		pass = false
+	}
+	if pass && options.Min_af > 0 {
+		if fraction, ok := supportFraction(record, alt_called); !ok || fraction < options.Min_af {
+			pass = false
+		}
+	}
+	base = lower(base)
+	if pass {
+		base = upper(base)
+	}
+	if base == lower(base) {
+		return options.Mask, false
+	}
+
+	// mask (or give an IUPAC code to) mixed sites
+	if options.Mixed_af > 0 {
+		if fraction, ok := minorFraction(record); ok && fraction >= options.Mixed_af {
+			if options.Mixed_iupac && alt != '.' {
+				return dna.IUPAC(ref, alt), true
+			}
+			return options.Mask, true
+		}
+	}
+	return base, false
+}
+
+/*
+  function to get the called ALT allele of an indel (false if it wasn't called, or it failed a filter)
+*/
+func calledIndel(record *vcf.Record) (string, bool) {
+	if !record.Passed() || !record.IsIndel() {
+		return "", false
+	}
+	if genotype := record.Genotype(0); len(genotype) != 0 {
+		for _, allele := range genotype {
+			if allele > 0 && allele <= len(record.Alt) {
+				return record.Alt[allele-1], true
+			}
+		}
+		return "", false
+	}
+	if af1, ok := record.InfoFloat("AF1"); ok && af1 < .5 {
+		return "", false
+	}
+	return record.Alt[0], true
+}
+
+/*
+  function to apply the indels to a contig, giving the consensus sequence and the chain from the reference to the consensus
+
+  an indel that overlaps one already applied is skipped
+*/
+func (current *contig) applyIndels() ([]byte, []byte, *liftover.Chain, int) {
+	consensus := make([]byte, 0, len(current.seq))
+	qual := make([]byte, 0, len(current.qual))
+	chain := &liftover.Chain{Ref_name: current.name, Ref_size: len(current.seq), Query_name: current.header()}
+	applied, cursor, block_start := 0, 0, 0
+	for _, called := range current.indels {
+
+		// skip the bases the indel shares with the reference
+		shared := 0
+		for shared < len(called.ref) && shared < len(called.alt) && called.ref[shared] == called.alt[shared] {
+			shared++
+		}
+		start := called.pos - 1 + shared
+		end := called.pos - 1 + len(called.ref)
+		if called.pos-1 < cursor || end > len(current.seq) {
+			continue
+		}
+		consensus = append(consensus, current.seq[cursor:start]...)
+		qual = append(qual, current.qual[cursor:start]...)
+		for i := shared; i < len(called.alt); i++ {
+			consensus = append(consensus, upper(called.alt[i]))
+			qual = append(qual, called.quality)
+		}
+		cursor = end
+		applied++
+
+		// start a new block after the gap (or add the gap to the last block if there are no aligned bases in between)
+		deleted, inserted := end-start, len(called.alt)-shared
+		if deleted == inserted {
+			continue
+		}
+		if start == block_start && len(chain.Blocks) != 0 {
+			chain.Blocks[len(chain.Blocks)-1].Ref_gap += deleted
+			chain.Blocks[len(chain.Blocks)-1].Query_gap += inserted
+		} else if start == block_start {
+			chain.Ref_start += deleted
+			chain.Query_start += inserted
+		} else {
+			chain.Blocks = append(chain.Blocks, liftover.Block{Size: start - block_start, Ref_gap: deleted, Query_gap: inserted})
+		}
+		block_start = end
+	}
+	consensus = append(consensus, current.seq[cursor:]...)
+	qual = append(qual, current.qual[cursor:]...)
+	chain.Blocks = append(chain.Blocks, liftover.Block{Size: len(current.seq) - block_start})
+	chain.Query_size = len(consensus)
+	for _, block := range chain.Blocks {
+		chain.Score += block.Size
+	}
+	return consensus, qual, chain, applied
+}
+
+/*
+  functions to change the case of a base
+*/
+func lower(base byte) byte {
+	if base >= 'A' && base <= 'Z' {
+		return base + 'a' - 'A'
+	}
+	return base
+}
+func upper(base byte) byte {
+	if base >= 'a' && base <= 'z' {
+		return base - 'a' + 'A'
+	}
+	return base
+}
+
+/*
+  function to finish a contig (lowercase the bases around indels, pad it to the contig length, mask the regions) and write it out
+
+  if the indels are applied, the bases around them aren't lowercased and the chain from the reference is kept in the stats
+
+  if there is a FASTQ writer, the contig is also written as FASTQ with the quality of each call
+*/
+func (options *Options) finish(writer io.Writer, fastq io.Writer, current *contig, length int, stats *Stats) error {
+	if length == 0 {
+		return fmt.Errorf("contig %v couldn't be associated with a genome length", current.name)
+	}
+	for _, gap := range current.gaps {
+		begin := 0
+		if gap[0] > options.Indel_window {
+			begin = gap[0] - options.Indel_window
+		}
+		if begin > len(current.seq) {
+			return fmt.Errorf("indel at %v:%d is past the end of the sequence (are there indels without a record for the reference base?)", current.name, gap[0])
+		}
+		end := gap[0] + gap[1] + options.Indel_window
+		if end > len(current.seq) {
+			end = len(current.seq)
+		}
+		for i := begin; i < end; i++ {
+			current.seq[i] = lower(current.seq[i])
+		}
+	}
+	for len(current.seq) < length {
+		current.add(options.Mask, 0)
+	}
+	stats.Region_masked += options.Regions.apply(current.name, current.seq, options.Mask)
+	if options.Indels {
+		consensus, qual, chain, applied := current.applyIndels()
+		chain.ID = len(stats.Chains) + 1
+		current.seq, current.qual = consensus, qual
+		stats.Indels += applied
+		stats.Chains = append(stats.Chains, chain)
+	}
+	if stats.Qualities == nil {
+		stats.Qualities = make([]int, max_quality+1)
+	}
+	for i, base := range current.seq {
+		if base == options.Mask || base == lower(options.Mask) {
+			current.qual[i] = 0
+			stats.Masked++
+		} else if base == upper(base) {
+			stats.Called++
+		}
+		stats.Qualities[current.qual[i]]++
+	}
+	stats.Length += len(current.seq)
+
+	if _, err := fmt.Fprintf(writer, ">%s\n", current.header()); err != nil {
+		return err
+	}
+	for i := 0; i < len(current.seq); i += line_width {
+		end := i + line_width
+		if end > len(current.seq) {
+			end = len(current.seq)
+		}
+		if _, err := fmt.Fprintf(writer, "%s\n", current.seq[i:end]); err != nil {
+			return err
+		}
+	}
+
+	// the FASTQ has the same name and one line for the sequence and the qualities
+	if fastq == nil {
+		return nil
+	}
+	encoded := make([]byte, len(current.qual))
+	for i, quality := range current.qual {
+		encoded[i] = quality + 33
+	}
+	_, err := fmt.Fprintf(fastq, "@%s\n%s\n+\n%s\n", current.header(), current.seq, encoded)
+	return err
+}
+
+/*
+  function to build a pseudogenome from an all-sites VCF (from bcftools call -c)
+
+  with the default options, the output is the same as vcfutils.pl vcf2fa:
+
+   * positions without a record are masked
+   * SNPs and reference sites are called if MQ, DP (and optionally QUAL and the DP4 allele fraction) pass, otherwise they are masked
+   * heterozygous sites are masked
+   * sites that failed a filter (FILTER is set and isn't PASS) are masked, and indels that failed a filter are ignored
+   * mixed sites are counted and masked (or given an IUPAC code) if Mixed_af is set
+   * the bases around indels are written in lowercase
+   * each contig is padded to its length from the VCF header
+   * any Regions are masked
+   * if Indels is set, the called indels are applied to give the sample consensus (and a chain from the reference)
+*/
+func (options *Options) Build(reader *vcf.Reader, writer io.Writer) (*Stats, error) {
+	return options.BuildFastq(reader, writer, nil)
+}
+
+/*
+  function to build a pseudogenome, writing it as FASTA and also as FASTQ (if the FASTQ writer isn't nil) with the quality of each call
+*/
+func (options *Options) BuildFastq(reader *vcf.Reader, writer io.Writer, fastq io.Writer) (*Stats, error) {
+	stats := &Stats{}
+	lengths := make(map[string]int)
+	for name, length := range options.Lengths {
+		lengths[name] = length
+	}
+	for _, header_contig := range reader.Header.Contigs {
+		lengths[header_contig.ID] = header_contig.Length
+	}
+	var current *contig
+	last_pos := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// start a new contig
+		if current == nil || record.Chrom != current.name {
+			if current != nil {
+				if err := options.finish(writer, fastq, current, lengths[current.name], stats); err != nil {
+					return nil, err
+				}
+			}
+			current, last_pos = &contig{name: record.Chrom}, 0
+		}
+		if record.Pos < last_pos {
+			return nil, fmt.Errorf("unsorted input at %v:%d", record.Chrom, record.Pos)
+		}
+		for i := last_pos + 1; i < record.Pos; i++ {
+			current.add(options.Mask, 0)
+		}
+
+		// add the base for SNPs and reference sites, keep track of the indels
+		info := infoString(record)
+		alt := "."
+		if len(record.Alt) != 0 {
+			alt = strings.Join(record.Alt, ",")
+		}
+		if len(record.Ref) == 1 && !strings.Contains(info, "INDEL") && snp_alt.MatchString(alt) {
+			if !record.Passed() {
+				current.add(options.Mask, 0)
+			} else {
+				base, mixed := options.callBase(record, info)
+				current.add(base, callQuality(record, info))
+				if mixed {
+					stats.Mixed++
+				}
+			}
+		} else if options.Indels {
+			if called, ok := calledIndel(record); ok {
+				current.indels = append(current.indels, indel{record.Pos, record.Ref, called, callQuality(record, info)})
+			}
+		} else if alt != "." && record.Passed() {
+			current.gaps = append(current.gaps, [2]int{record.Pos, len(record.Ref)})
+		}
+		last_pos = record.Pos
+	}
+	if current == nil {
+		return nil, fmt.Errorf("no records in the VCF")
+	}
+	if err := options.finish(writer, fastq, current, lengths[current.name], stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+/*
+  function to build a pseudogenome from a VCF file (plain, gzip or BGZF) and write it to a fasta file
+*/
+func (options *Options) BuildFile(vcf_file string, fasta_file string) (*Stats, error) {
+	return options.BuildFiles(vcf_file, fasta_file, "")
+}
+
+/*
+  function to build a pseudogenome from a VCF file and write it to a fasta file and a FASTQ file (if the FASTQ file name isn't empty)
+*/
+func (options *Options) BuildFiles(vcf_file string, fasta_file string, fastq_file string) (*Stats, error) {
+	reader, err := vcf.Open(vcf_file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	fh, err := os.Create(fasta_file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	writer := bufio.NewWriter(fh)
+	var fastq *bufio.Writer
+	if len(fastq_file) != 0 {
+		fastq_fh, err := os.Create(fastq_file)
+		if err != nil {
+			return nil, err
+		}
+		defer fastq_fh.Close()
+		fastq = bufio.NewWriter(fastq_fh)
+	}
+	// (a nil *bufio.Writer isn't a nil io.Writer)
+	var stats *Stats
+	if fastq != nil {
+		stats, err = options.BuildFastq(reader, writer, fastq)
+	} else {
+		stats, err = options.BuildFastq(reader, writer, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", vcf_file, err)
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	if fastq != nil {
+		if err := fastq.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+/*
+  function to get the mean quality of the called (not masked) bases
+*/
+func (stats *Stats) MeanQuality() float64 {
+	total, bases := 0, 0
+	for quality, count := range stats.Qualities {
+		if quality != 0 {
+			total += quality * count
+			bases += count
+		}
+	}
+	if bases == 0 {
+		return 0
+	}
+	return float64(total) / float64(bases)
+}
+
+/*
+  function to write the quality histogram as a TSV (the number and fraction of bases with each quality, and the fraction with at least that quality)
+*/
+func (stats *Stats) WriteHistogram(writer io.Writer) error {
+	if _, err := fmt.Fprintf(writer, "quality\tbases\tfraction\tfraction_at_least\n"); err != nil {
+		return err
+	}
+	at_least := stats.Length
+	for quality, count := range stats.Qualities {
+		if count != 0 {
+			if _, err := fmt.Fprintf(writer, "%d\t%d\t%.4f\t%.4f\n", quality, count, float64(count)/float64(stats.Length), float64(at_least)/float64(stats.Length)); err != nil {
+				return err
+			}
+		}
+		at_least -= count
+	}
+	return nil
+}
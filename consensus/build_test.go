@@ -0,0 +1,221 @@
+package consensus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/vcf"
+)
+
+// an all-sites VCF (as from bcftools call -c) with a SNP, a het, low depth and mapping quality sites, an ALT with AF1 < 0.5, a deletion, a multi-allelic SNP and missing positions
+const test_vcf = "testdata/sample.vcf"
+
+// the output of bin/vcfutils.pl vcf2fa -d 5 for the test VCF (as run by gopherSeq align before the pseudogenomes were built natively)
+const test_vcf2fa = "testdata/sample.vcf2fa.fa"
+
+/*
+  function to build a pseudogenome from a VCF file into a string
+*/
+func buildString(t *testing.T, options *Options, vcf_file string) (string, *Stats) {
+	reader, err := vcf.Open(vcf_file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	var buffer bytes.Buffer
+	stats, err := options.Build(reader, &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String(), stats
+}
+
+func TestSameAsVcf2fa(t *testing.T) {
+	expected, err := ioutil.ReadFile(test_vcf2fa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultOptions()
+	options.Min_depth = 5
+	pseudogenome, stats := buildString(t, options, test_vcf)
+	if pseudogenome != string(expected) {
+		t.Errorf("the pseudogenome is not the same as the vcf2fa output:\n%v\nexpected:\n%v", pseudogenome, string(expected))
+	}
+	if stats.Length != 82 || stats.Called != 56 || stats.Masked != 14 {
+		t.Errorf("unexpected stats: %d bases, %d called, %d masked", stats.Length, stats.Called, stats.Masked)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	// the SNP at chrA:8 has QUAL 222, the one at chrB:3 has QUAL 60 and an MQ of 45
+	options := DefaultOptions()
+	options.Min_depth, options.Min_qual, options.Min_mq = 5, 100, 50
+	pseudogenome, _ := buildString(t, options, test_vcf)
+	sequences := strings.Split(pseudogenome, "\n")
+	if sequences[1][7] != 'G' {
+		t.Errorf("the QUAL 222 SNP at chrA:8 should be kept, found %c", sequences[1][7])
+	}
+	if sequences[4][2] != 'N' {
+		t.Errorf("the SNP at chrB:3 has a low QUAL and MQ and should be masked, found %c", sequences[4][2])
+	}
+
+	// a different masking character is used for the missing positions and the padding
+	options = DefaultOptions()
+	options.Min_depth, options.Mask = 5, '-'
+	pseudogenome, _ = buildString(t, options, test_vcf)
+	if !strings.HasSuffix(pseudogenome, ">_chrB\nTTTAGCTGG---\n") {
+		t.Errorf("unexpected masking of chrB:\n%v", pseudogenome)
+	}
+
+	// the masked regions are counted
+	options = DefaultOptions()
+	options.Min_depth, options.Regions = 5, Regions{}
+	options.Regions.Add("chrB", 0, 4)
+	pseudogenome, stats := buildString(t, options, test_vcf)
+	if !strings.HasSuffix(pseudogenome, ">_chrB\nNNNNGCTGGNNN\n") || stats.Region_masked != 4 {
+		t.Errorf("chrB:1-4 should be masked (%d masked):\n%v", stats.Region_masked, pseudogenome)
+	}
+}
+
+func TestFailedSitesMasked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consensus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	text, err := ioutil.ReadFile(test_vcf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := strings.Replace(string(text), "chrA\t8\t.\tA\tG\t222\t.\t", "chrA\t8\t.\tA\tG\t222\tSnpCluster\t", 1)
+	if filtered == string(text) {
+		t.Fatal("the SNP at chrA:8 is missing from the test VCF")
+	}
+	vcf_file := dir + "/filtered.vcf"
+	if err := ioutil.WriteFile(vcf_file, []byte(filtered), 0600); err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultOptions()
+	options.Min_depth = 5
+	pseudogenome, _ := buildString(t, options, vcf_file)
+	if sequence := strings.Split(pseudogenome, "\n")[1]; sequence[7] != 'N' {
+		t.Errorf("the SNP that failed a filter should be masked, found %c", sequence[7])
+	}
+}
+
+func TestUnknownLength(t *testing.T) {
+	text := "##fileformat=VCFv4.2\n#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tsample\n" +
+		"chr\t1\t.\tA\t.\t30\t.\tDP=10;AF1=0;MQ=60;FQ=-60\tGT\t0/0\n"
+	reader, err := vcf.NewReader(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if _, err := DefaultOptions().Build(reader, &buffer); err == nil {
+		t.Error("a contig without a length was accepted")
+	}
+
+	// the length can be given instead (e.g. from the reference)
+	reader, _ = vcf.NewReader(strings.NewReader(text))
+	options := DefaultOptions()
+	options.Lengths = map[string]int{"chr": 3}
+	if _, err := options.Build(reader, &buffer); err != nil || buffer.String() != ">_chr\nANN\n" {
+		t.Errorf("unexpected pseudogenome (%v):\n%v", err, buffer.String())
+	}
+}
+
+func TestMixedSites(t *testing.T) {
+	// mixed sites are only counted when asked for, so the default is the same as vcf2fa
+	options := DefaultOptions()
+	options.Min_depth = 5
+	pseudogenome, stats := buildString(t, options, test_vcf)
+	if stats.Mixed != 0 || strings.Split(pseudogenome, "\n")[1][29] != 'G' {
+		t.Errorf("found %d mixed sites with the default options", stats.Mixed)
+	}
+
+	// chrA:30 has 3 of 15 reads (DP4) supporting the ALT and the het at chrA:15 has half
+	options.Mixed_af = 0.2
+	pseudogenome, stats = buildString(t, options, test_vcf)
+	if stats.Mixed != 2 || strings.Split(pseudogenome, "\n")[1][29] != 'N' {
+		t.Errorf("chrA:15 and chrA:30 should be mixed and masked (%d mixed)", stats.Mixed)
+	}
+	options.Mixed_iupac = true
+	pseudogenome, _ = buildString(t, options, test_vcf)
+	if sequence := strings.Split(pseudogenome, "\n")[1]; sequence[14] != 'Y' || sequence[29] != 'R' {
+		t.Errorf("the mixed C/T and G/A sites should be Y and R, found %c and %c", sequence[14], sequence[29])
+	}
+	options.Mixed_af = 0.25
+	if _, stats = buildString(t, options, test_vcf); stats.Mixed != 1 {
+		t.Errorf("found %d mixed sites with a minor allele fraction of at least 0.25, expected only the het", stats.Mixed)
+	}
+}
+
+func TestIndels(t *testing.T) {
+	// the deletion at chrA:45 (CT>C) removes chrA:46
+	options := DefaultOptions()
+	options.Min_depth, options.Indels = 5, true
+	consensus, stats := buildString(t, options, test_vcf)
+	expected := ">_chrA\nCCTAAGCGTTCCTANAATTTTNNGATGTGGTGTCNNNACGACGACTGTATAAGGCGAACT\nGAGCNNNNN\n>_chrB\nTTTAGCTGGNNN\n"
+	if consensus != expected {
+		t.Errorf("unexpected consensus:\n%v\nexpected:\n%v", consensus, expected)
+	}
+	if stats.Indels != 1 || len(stats.Chains) != 2 {
+		t.Fatalf("applied %d indels and made %d chains", stats.Indels, len(stats.Chains))
+	}
+
+	// the chain maps onto the contig names of the consensus
+	chain := stats.Chains[0]
+	if chain.Ref_name != "chrA" || chain.Query_name != "_chrA" || chain.Ref_size != 70 || chain.Query_size != 69 {
+		t.Errorf("unexpected chain: %v %d > %v %d", chain.Ref_name, chain.Ref_size, chain.Query_name, chain.Query_size)
+	}
+	if name, position, ok := stats.Chains.Map("chrA", 47); !ok || name != "_chrA" || position != 46 {
+		t.Errorf("chrA:47 mapped to %v:%d (%v)", name, position, ok)
+	}
+	if _, _, ok := stats.Chains.Map("chrA", 46); ok {
+		t.Error("the deleted base chrA:46 was mapped")
+	}
+}
+
+func TestFastq(t *testing.T) {
+	reader, err := vcf.Open(test_vcf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	options := DefaultOptions()
+	options.Min_depth = 5
+	var fasta, fastq bytes.Buffer
+	stats, err := options.BuildFastq(reader, &fasta, &fastq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(fastq.String(), "\n")
+	if len(lines) != 9 || lines[0] != "@_chrA" || lines[2] != "+" || lines[4] != "@_chrB" {
+		t.Fatalf("unexpected FASTQ:\n%v", fastq.String())
+	}
+	sequence, qualities := lines[1], lines[3]
+	if len(qualities) != 70 || sequence != strings.Replace(strings.Join(strings.Split(fasta.String(), "\n")[1:3], ""), "\n", "", -1) {
+		t.Fatalf("the FASTQ sequence is not the pseudogenome:\n%v", fastq.String())
+	}
+
+	// the FQ of each call is the base quality (capped at 93), masked bases have a quality of 0
+	for position, expected := range map[int]byte{1: 60 + 33, 8: 93 + 33, 15: 33, 22: 33, 30: 30 + 33, 36: 33, 70: 33} {
+		if qualities[position-1] != expected {
+			t.Errorf("chrA:%d has quality %c, expected %c", position, qualities[position-1], expected)
+		}
+	}
+	if stats.Qualities[0] != stats.Masked || stats.MeanQuality() <= 30 {
+		t.Errorf("%d bases with quality 0 and %d masked (mean quality %.1f)", stats.Qualities[0], stats.Masked, stats.MeanQuality())
+	}
+	var histogram bytes.Buffer
+	if err := stats.WriteHistogram(&histogram); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(histogram.String()), "\n")
+	if rows[0] != "quality\tbases\tfraction\tfraction_at_least" || !strings.HasPrefix(rows[1], "0\t14\t") || !strings.HasSuffix(rows[1], "\t1.0000") {
+		t.Errorf("unexpected histogram:\n%v", histogram.String())
+	}
+}
@@ -0,0 +1,208 @@
+/*
+
+This package builds a pseudogenome (the reference sequence with each sample's SNPs) from an all-sites VCF.
+
+It replaces vcfutils.pl vcf2fa and gives the same output with the default options. The minimum depth, maximum depth, minimum mapping quality, minimum quality, minimum allele fraction and the masking character can all be changed.
+
+Sites that failed a filter (e.g. from gopherSeq filter) are masked, as are any regions of the reference given in a BED file or as GFF3 feature types (e.g. repeat_region).
+
+With --indels, the called indels are applied to give the true sample consensus (not in reference coordinates) and a chain file can be written to map positions between the reference and the consensus (see gopherSeq liftover).
+
+The pseudogenome can also be written as FASTQ, with the consensus quality (FQ) of each call as the base quality (as vcfutils.pl vcf2fq), and a histogram of the base qualities can be written.
+
+Mixed sites (where the minor allele has at least a set fraction of the reads) can be counted and masked, or given an IUPAC code.
+
+*/
+
+package consensus
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/liftover"
+	"github.com/will-rowe/gopherSeq/reference"
+	"github.com/will-rowe/gopherSeq/vcf"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up command line arguments
+var args struct {
+	Input        string  `arg:"positional,required,help:all-sites VCF from bcftools call -c (can be .gz)"`
+	Reference    string  `arg:"-r,help:reference sequence - only needed if the VCF header has no contig lengths"`
+	Output       string  `arg:"-o,help:output fasta file [default: STDOUT]"`
+	Min_depth    int     `arg:"-d,help:minimum depth [default: 3]"`
+	Max_depth    int     `arg:"-D,help:maximum depth [default: 100000]"`
+	Min_mq       int     `arg:"-Q,help:minimum RMS mapping quality [default: 10]"`
+	Min_qual     float64 `arg:"-q,help:minimum QUAL for a SNP to be used [default: off]"`
+	Min_af       float64 `arg:"-a,help:minimum fraction of reads (DP4) supporting the called base [default: off]"`
+	Indel_window int     `arg:"-l,help:bases either side of an indel that are written in lowercase [default: 5]"`
+	Mask         string  `arg:"-m,help:character used for masked positions [default: N]"`
+	Mixed_af     float64 `arg:"help:count sites where the minor allele fraction (DP4) is at least this as mixed and mask them [default: off]"`
+	Iupac        bool    `arg:"help:give mixed sites an IUPAC code instead of masking them [default: false]"`
+	Mask_bed     string  `arg:"help:BED file of reference regions to mask (e.g. repeats and prophages)"`
+	Mask_gff     string  `arg:"help:GFF3 annotation of the reference to take the masked features from"`
+	Mask_types   string  `arg:"help:feature types to mask from the GFF3 - separated by commas (e.g. repeat_region)"`
+	Indels       bool    `arg:"help:apply the called indels to give the sample consensus (not in reference coordinates) [default: false]"`
+	Chain        string  `arg:"help:write the chain file from the reference to the consensus (needs --indels)"`
+	Fastq        string  `arg:"help:also write the pseudogenome as FASTQ with the quality of each call (needs --output)"`
+	Histogram    string  `arg:"help:write a histogram of the base qualities (TSV)"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tbuilds a pseudogenome from an all-sites VCF (replaces vcfutils.pl vcf2fa)\n\nusage:\n\tgopherSeq consensus [options] INPUT\n\nhelp:\n\tgopherSeq consensus --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to check the masking character
+*/
+func MaskCharacter(mask string) (byte, error) {
+	if len(mask) != 1 || mask[0] <= ' ' || mask[0] > '~' || mask[0] == '>' {
+		return 0, fmt.Errorf("the mask must be a single printable character: %q", mask)
+	}
+	return mask[0], nil
+}
+
+/*
+  function to write the chain file (if one was asked for)
+*/
+func writeChain(stats *Stats) {
+	if len(args.Chain) == 0 {
+		return
+	}
+	if err := liftover.WriteFile(args.Chain, stats.Chains); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write chain file: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+/*
+  function to write the quality histogram (if one was asked for)
+*/
+func writeHistogram(stats *Stats) {
+	if len(args.Histogram) == 0 {
+		return
+	}
+	fh, err := os.Create(args.Histogram)
+	if err == nil {
+		err = stats.WriteHistogram(fh)
+		if close_err := fh.Close(); err == nil {
+			err = close_err
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not write histogram: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	options := DefaultOptions()
+	args.Min_depth, args.Max_depth, args.Min_mq, args.Indel_window, args.Mask = options.Min_depth, options.Max_depth, options.Min_mq, options.Indel_window, string(options.Mask)
+	arg.MustParse(&args)
+	mask, err := MaskCharacter(args.Mask)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	options.Min_depth, options.Max_depth, options.Min_mq, options.Min_qual, options.Min_af, options.Indel_window, options.Mask = args.Min_depth, args.Max_depth, args.Min_mq, args.Min_qual, args.Min_af, args.Indel_window, mask
+	options.Mixed_af, options.Mixed_iupac, options.Indels = args.Mixed_af, args.Iupac, args.Indels
+	if len(args.Fastq) != 0 && len(args.Output) == 0 {
+		fmt.Printf("a FASTQ can only be written with --output\n")
+		os.Exit(1)
+	}
+	if len(args.Chain) != 0 && !args.Indels {
+		fmt.Printf("a chain file can only be written with --indels\n")
+		os.Exit(1)
+	}
+
+	// get the contig lengths from the reference
+	if len(args.Reference) != 0 {
+		sequences, err := reference.Sequences(args.Reference)
+		if err != nil {
+			fmt.Printf("could not read reference: %v\n", err)
+			os.Exit(1)
+		}
+		options.Lengths = make(map[string]int)
+		for _, sequence := range sequences {
+			options.Lengths[sequence.Name] = len(sequence.Seq)
+		}
+	}
+
+	// get the regions to mask
+	if len(args.Mask_bed) != 0 || len(args.Mask_types) != 0 {
+		regions, err := LoadRegions(args.Mask_bed, args.Mask_gff, args.Mask_types)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not get the regions to mask: %v\n", err)
+			os.Exit(1)
+		}
+		options.Regions = regions
+	}
+
+	// build the pseudogenome (to STDOUT if there is no output file)
+	if len(args.Output) == 0 {
+		reader, err := vcf.Open(args.Input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read VCF: %v\n", err)
+			os.Exit(1)
+		}
+		defer reader.Close()
+		writer := bufio.NewWriter(os.Stdout)
+		defer writer.Flush()
+		stats, err := options.Build(reader, writer)
+		if err != nil {
+			writer.Flush()
+			fmt.Fprintf(os.Stderr, "could not build pseudogenome: %v\n", err)
+			os.Exit(1)
+		}
+		writeChain(stats)
+		writeHistogram(stats)
+		return
+	}
+	stats, err := options.BuildFiles(args.Input, args.Output, args.Fastq)
+	if err != nil {
+		fmt.Printf("could not build pseudogenome: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(" * pseudogenome --> %v (%d bases, %d called, %d masked)\n", args.Output, stats.Length, stats.Called, stats.Masked)
+	if options.Mixed_af > 0 {
+		fmt.Printf(" * mixed sites --> %d\n", stats.Mixed)
+	}
+	if len(options.Regions) != 0 {
+		fmt.Printf(" * masked regions --> %d bases\n", stats.Region_masked)
+	}
+	if options.Indels {
+		fmt.Printf(" * indels applied --> %d\n", stats.Indels)
+	}
+	if len(args.Fastq) != 0 {
+		fmt.Printf(" * FASTQ --> %v (mean quality of the called bases %.1f)\n", args.Fastq, stats.MeanQuality())
+	}
+	writeChain(stats)
+	writeHistogram(stats)
+}
@@ -0,0 +1,156 @@
+package consensus
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/bed"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Regions are the reference coordinates (0-based, half-open, by contig) that are masked in every pseudogenome
+type Regions map[string][][2]int
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the regions to mask from a BED file
+*/
+func RegionsFromBED(bed_file string) (Regions, error) {
+	bed_regions, err := bed.Read(bed_file)
+	if err != nil {
+		return nil, err
+	}
+	regions := make(Regions)
+	for _, region := range bed_regions {
+		regions.Add(region.Chrom, region.Start, region.End)
+	}
+	return regions, nil
+}
+
+/*
+  function to get the regions to mask from the features of an annotation (e.g. repeat_region, mobile_genetic_element)
+*/
+func RegionsFromFeatures(annotation *gff.Annotation, feature_types []string) (Regions, error) {
+	features := annotation.OfType(feature_types...)
+	if len(features) == 0 {
+		return nil, fmt.Errorf("no features of type %v in the annotation", feature_types)
+	}
+	regions := make(Regions)
+	for _, feature := range features {
+		regions.Add(feature.Seqid, feature.Start-1, feature.End)
+	}
+	return regions, nil
+}
+
+/*
+  function to get the regions to mask from a BED file and/or the features of a GFF3 (feature types are a comma separated list)
+*/
+func LoadRegions(bed_file string, gff_file string, feature_types string) (Regions, error) {
+	regions := make(Regions)
+	if len(bed_file) != 0 {
+		bed_regions, err := RegionsFromBED(bed_file)
+		if err != nil {
+			return nil, err
+		}
+		regions.Merge(bed_regions)
+	}
+	if len(feature_types) != 0 {
+		if len(gff_file) == 0 {
+			return nil, fmt.Errorf("an annotation is needed to mask features")
+		}
+		annotation, err := gff.Read(gff_file)
+		if err != nil {
+			return nil, err
+		}
+		var types []string
+		for _, feature_type := range strings.Split(feature_types, ",") {
+			if feature_type = strings.TrimSpace(feature_type); len(feature_type) != 0 {
+				types = append(types, feature_type)
+			}
+		}
+		feature_regions, err := RegionsFromFeatures(annotation, types)
+		if err != nil {
+			return nil, err
+		}
+		regions.Merge(feature_regions)
+	}
+	return regions, nil
+}
+
+/*
+  function to add a region (0-based, half-open)
+*/
+func (regions Regions) Add(contig string, start int, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > start {
+		regions[contig] = append(regions[contig], [2]int{start, end})
+	}
+}
+
+/*
+  function to add all the regions from another set
+*/
+func (regions Regions) Merge(other Regions) {
+	for contig, spans := range other {
+		for _, span := range spans {
+			regions.Add(contig, span[0], span[1])
+		}
+	}
+}
+
+/*
+  function to get the total length of the regions (overlapping regions are counted once)
+*/
+func (regions Regions) Length() int {
+	length := 0
+	for _, spans := range regions {
+		sorted := make([][2]int, len(spans))
+		copy(sorted, spans)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+		covered := 0
+		for _, span := range sorted {
+			if span[0] > covered {
+				covered = span[0]
+			}
+			if span[1] > covered {
+				length += span[1] - covered
+				covered = span[1]
+			}
+		}
+	}
+	return length
+}
+
+/*
+  function to mask the regions of a contig sequence, returning the number of positions in the regions (regions that run past the end of the sequence wrap around, as for features crossing the origin of a circular chromosome)
+*/
+func (regions Regions) apply(name string, seq []byte, mask byte) int {
+	spans := regions[name]
+	if len(spans) == 0 || len(seq) == 0 {
+		return 0
+	}
+	covered := make([]bool, len(seq))
+	masked := 0
+	for _, span := range spans {
+		for i := span[0]; i < span[1] && i-span[0] < len(seq); i++ {
+			position := i % len(seq)
+			seq[position] = mask
+			if !covered[position] {
+				covered[position] = true
+				masked++
+			}
+		}
+	}
+	return masked
+}
@@ -0,0 +1,79 @@
+package consensus
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+const test_mask_gff = `##gff-version 3
+chr	test	repeat_region	3	5	.	+	.	ID=repeat1
+chr	test	mobile_genetic_element	4	8	.	+	.	ID=mge1
+chr	test	gene	10	12	.	+	.	ID=gene1
+`
+
+func TestRegionsFromFeatures(t *testing.T) {
+	annotation, err := gff.Parse(strings.NewReader(test_mask_gff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	regions, err := RegionsFromFeatures(annotation, []string{"repeat_region", "mobile_genetic_element"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the overlapping features cover positions 3-8
+	if regions.Length() != 6 {
+		t.Errorf("the regions cover %d bases, expected 6", regions.Length())
+	}
+	seq := []byte("ACGTACGTACGT")
+	if masked := regions.apply("chr", seq, 'N'); masked != 6 || string(seq) != "ACNNNNNNACGT" {
+		t.Errorf("masked %d bases: %s", masked, seq)
+	}
+	if _, err := RegionsFromFeatures(annotation, []string{"prophage"}); err == nil {
+		t.Error("a feature type that isn't in the annotation was accepted")
+	}
+}
+
+func TestLoadRegions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consensus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bed_file, gff_file := path.Join(dir, "mask.bed"), path.Join(dir, "mask.gff")
+	if err := ioutil.WriteFile(bed_file, []byte("chr\t0\t2\nother\t5\t10\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(gff_file, []byte(test_mask_gff), 0600); err != nil {
+		t.Fatal(err)
+	}
+	regions, err := LoadRegions(bed_file, gff_file, "repeat_region, gene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions["chr"]) != 3 || len(regions["other"]) != 1 || regions.Length() != 2+3+3+5 {
+		t.Errorf("unexpected regions: %v", regions)
+	}
+	if _, err := LoadRegions("", "", "repeat_region"); err == nil {
+		t.Error("feature types were accepted without an annotation")
+	}
+}
+
+func TestRegionsWrapAround(t *testing.T) {
+	// a feature crossing the origin of a circular chromosome runs past the end of the sequence
+	regions := Regions{}
+	regions.Add("chr", 10, 14)
+	regions.Add("chr", 11, 12)
+	seq := []byte("ACGTACGTACGT")
+	if masked := regions.apply("chr", seq, 'N'); masked != 4 || string(seq) != "NNGTACGTACNN" {
+		t.Errorf("masked %d bases: %s", masked, seq)
+	}
+	if masked := regions.apply("other", seq, 'N'); masked != 0 {
+		t.Errorf("masked %d bases of a contig without regions", masked)
+	}
+}
@@ -0,0 +1,163 @@
+package distance
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Coverage is where each of a set of pseudogenomes has no base - the SNP alignment of the pseudogenomes only has the variable sites, so this is needed to count the sites compared for each pair
+type Coverage struct {
+	Names []string
+
+	// the length of the pseudogenomes (all the contigs)
+	Length int
+
+	// the runs of missing positions for each sample (0-based, half-open and sorted, with the contigs in the order of the first pseudogenome)
+	Missing [][][2]int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to find the positions of a set of pseudogenomes that have no base (N, gaps and IUPAC codes), reading one pseudogenome at a time
+*/
+func ReadCoverage(inputs []string) (*Coverage, error) {
+	coverage := &Coverage{}
+	offsets := make(map[string]int)
+	for i, input := range inputs {
+		records, err := fasta.Read(input)
+		if err != nil {
+			return nil, err
+		}
+		var runs [][2]int
+		for _, record := range records {
+			if i == 0 {
+				offsets[record.Name] = coverage.Length
+				coverage.Length += len(record.Seq)
+			}
+			offset, ok := offsets[record.Name]
+			if !ok {
+				return nil, fmt.Errorf("%v has a sequence (%v) that isn't in %v", input, record.Name, inputs[0])
+			}
+			seq := bytes.ToUpper(record.Seq)
+			for j := 0; j < len(seq); j++ {
+				if called(seq[j]) {
+					continue
+				}
+				start := j
+				for j < len(seq) && !called(seq[j]) {
+					j++
+				}
+				runs = append(runs, [2]int{offset + start, offset + j})
+			}
+		}
+		sort.Slice(runs, func(a, b int) bool { return runs[a][0] < runs[b][0] })
+		coverage.Names = append(coverage.Names, fasta.SampleName(input))
+		coverage.Missing = append(coverage.Missing, runs)
+	}
+	return coverage, nil
+}
+
+/*
+  function to get the number of positions in a set of runs
+*/
+func runLength(runs [][2]int) int {
+	length := 0
+	for _, run := range runs {
+		length += run[1] - run[0]
+	}
+	return length
+}
+
+/*
+  function to get the number of positions that are in both of two sets of sorted runs
+*/
+func overlap(a [][2]int, b [][2]int) int {
+	shared, i, j := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		start, end := a[i][0], a[i][1]
+		if b[j][0] > start {
+			start = b[j][0]
+		}
+		if b[j][1] < end {
+			end = b[j][1]
+		}
+		if end > start {
+			shared += end - start
+		}
+		if a[i][1] < b[j][1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return shared
+}
+
+/*
+  function to get the number of positions that are in any of the sets of runs
+*/
+func union(sets [][][2]int) int {
+	var runs [][2]int
+	for _, set := range sets {
+		runs = append(runs, set...)
+	}
+	sort.Slice(runs, func(a, b int) bool { return runs[a][0] < runs[b][0] })
+	length, covered := 0, 0
+	for _, run := range runs {
+		if run[0] > covered {
+			covered = run[0]
+		}
+		if run[1] > covered {
+			length += run[1] - covered
+			covered = run[1]
+		}
+	}
+	return length
+}
+
+/*
+  function to count the sites compared for each pair across the whole pseudogenomes (the distances from the variable sites are kept)
+
+  a pair is compared at every position where both samples have a base, or with core set, at every position where all the samples have a base
+*/
+func (matrix *Matrix) CountSites(coverage *Coverage, core bool) error {
+	if len(coverage.Names) != len(matrix.Names) {
+		return fmt.Errorf("the coverage has %d samples and the distance matrix has %d", len(coverage.Names), len(matrix.Names))
+	}
+	for i, name := range matrix.Names {
+		if coverage.Names[i] != name {
+			return fmt.Errorf("expected sample %v in the coverage, found %v", name, coverage.Names[i])
+		}
+	}
+	matrix.Sites, matrix.Core_sites = coverage.Length, coverage.Length
+	if core {
+		matrix.Core_sites -= union(coverage.Missing)
+	}
+	missing := make([]int, len(coverage.Missing))
+	for i, runs := range coverage.Missing {
+		missing[i] = runLength(runs)
+	}
+	for i := range matrix.Names {
+		for j := i; j < len(matrix.Names); j++ {
+			compared := coverage.Length - missing[i]
+			if core {
+				compared = matrix.Core_sites
+			} else if j != i {
+				compared = coverage.Length - missing[i] - missing[j] + overlap(coverage.Missing[i], coverage.Missing[j])
+			}
+			matrix.Compared[i][j], matrix.Compared[j][i] = compared, compared
+		}
+	}
+	return nil
+}
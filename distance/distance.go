@@ -0,0 +1,131 @@
+/*
+
+This package calculates the pairwise SNP distances between samples, from their pseudogenomes or from an alignment (e.g. the SNP alignment from gopherSeq snpalign).
+
+Only sites where both samples have a base (A, C, G or T) are compared, so N, gaps and IUPAC codes are ignored. By default this is done for each pair (pairwise deletion) - with --core, sites where any sample is missing are ignored for every pair.
+
+The distances are written as a matrix and (optionally) as a table with one line per pair of samples, giving the number of sites compared for each pair.
+
+*/
+
+package distance
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/fasta"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up command line arguments
+var args struct {
+	Input   []string `arg:"positional,required,help:pseudogenomes or a single alignment (in FASTA format - can be .gz)"`
+	Output  string   `arg:"-o,help:output file for the distance matrix [default: STDOUT]"`
+	Pairs   string   `arg:"-p,help:output file for the distances with one line per pair of samples"`
+	Core    bool     `arg:"-c,help:only compare sites where every sample has a base (core only deletion) [default: false]"`
+	Csv     bool     `arg:"help:write CSV instead of TSV [default: false]"`
+	Threads int      `arg:"-t,help:number of processors to use [default: maximum]"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tcalculates pairwise SNP distances from pseudogenomes or an alignment\n\nusage:\n\tgopherSeq distance [options] PSEUDOGENOME... | ALIGNMENT\n\nhelp:\n\tgopherSeq distance --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to write a table to a file (or STDOUT if there is no file name)
+*/
+func writeFile(file_name string, write func(io.Writer) error) error {
+	fh := os.Stdout
+	if len(file_name) != 0 {
+		var err error
+		if fh, err = os.Create(file_name); err != nil {
+			return err
+		}
+		defer fh.Close()
+	}
+	writer := bufio.NewWriter(fh)
+	if err := write(writer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	arg.MustParse(&args)
+	if args.Threads <= 0 || args.Threads > runtime.NumCPU() {
+		args.Threads = runtime.NumCPU()
+	}
+	separator := "\t"
+	if args.Csv {
+		separator = ","
+	}
+
+	// read the samples (a single input is an alignment, otherwise each input is a pseudogenome)
+	var sequences []*fasta.Sequence
+	var err error
+	if len(args.Input) == 1 {
+		sequences, err = ReadAlignment(args.Input[0])
+	} else {
+		sequences, err = ReadPseudogenomes(args.Input)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read samples: %v\n", err)
+		os.Exit(1)
+	}
+
+	// calculate and write the distances (the SNP alignment of the pseudogenomes only has the variable sites, so the sites compared are counted across the whole pseudogenomes)
+	matrix, err := Calculate(sequences, args.Core, args.Threads)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not calculate distances: %v\n", err)
+		os.Exit(1)
+	}
+	if len(args.Input) != 1 {
+		coverage, err := ReadCoverage(args.Input)
+		if err == nil {
+			err = matrix.CountSites(coverage, args.Core)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not count the sites compared: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := writeFile(args.Output, func(writer io.Writer) error { return matrix.Write(writer, separator) }); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write distance matrix: %v\n", err)
+		os.Exit(1)
+	}
+	if len(args.Pairs) != 0 {
+		if err := writeFile(args.Pairs, func(writer io.Writer) error { return matrix.WritePairs(writer, separator) }); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write pairwise distances: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Fprintf(os.Stderr, " * compared %d samples at %d of %d sites\n", len(matrix.Names), matrix.Core_sites, matrix.Sites)
+}
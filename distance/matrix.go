@@ -0,0 +1,293 @@
+package distance
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/snpalign"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Matrix holds the pairwise SNP distances between a set of samples
+type Matrix struct {
+	Names []string
+
+	// the number of sites where each pair of samples have different bases
+	Distances [][]int
+
+	// the number of sites where both samples of a pair have a base (A, C, G or T)
+	Compared [][]int
+
+	// the number of sites in the alignment, and how many were used (all of them, unless it's core only)
+	Sites      int
+	Core_sites int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to check if a base is called (N, gaps and IUPAC codes are not)
+*/
+func called(base byte) bool {
+	switch base {
+	case 'A', 'C', 'G', 'T':
+		return true
+	}
+	return false
+}
+
+/*
+  function to read an alignment (a multi-FASTA file with a sequence per sample)
+*/
+func ReadAlignment(file_name string) ([]*fasta.Sequence, error) {
+	sequences, err := fasta.Read(file_name)
+	if err != nil {
+		return nil, err
+	}
+	for _, sequence := range sequences {
+		if len(sequence.Seq) != len(sequences[0].Seq) {
+			return nil, fmt.Errorf("%v is not an alignment: %v is %d bases and %v is %d bases", file_name, sequences[0].Name, len(sequences[0].Seq), sequence.Name, len(sequence.Seq))
+		}
+		sequence.Seq = bytes.ToUpper(sequence.Seq)
+	}
+	return sequences, nil
+}
+
+/*
+  function to get the variable sites of a set of pseudogenomes (the other sites don't change the distances, so only the SNP alignment is kept in memory)
+
+  the sites compared for each pair then only count the variable sites - use ReadCoverage and CountSites to count them across the whole pseudogenomes
+*/
+func ReadPseudogenomes(inputs []string) ([]*fasta.Sequence, error) {
+	var snps bytes.Buffer
+	if _, err := snpalign.DefaultOptions().Build(inputs, nil, &snps, nil); err != nil {
+		return nil, err
+	}
+	return fasta.Parse(&snps)
+}
+
+/*
+  function to check the sequences are an alignment of different samples
+*/
+func check(sequences []*fasta.Sequence) error {
+	if len(sequences) < 2 {
+		return fmt.Errorf("at least 2 sequences are needed for a distance matrix")
+	}
+	seen := make(map[string]bool)
+	for _, sequence := range sequences {
+		if seen[sequence.Name] {
+			return fmt.Errorf("sample %v is in the alignment more than once", sequence.Name)
+		}
+		seen[sequence.Name] = true
+		if len(sequence.Seq) != len(sequences[0].Seq) {
+			return fmt.Errorf("%v is %d bases, expected %d", sequence.Name, len(sequence.Seq), len(sequences[0].Seq))
+		}
+	}
+	return nil
+}
+
+/*
+  function to get the sites of an alignment to compare (all of them, or with core set, the sites where every sample has a base)
+*/
+func Columns(sequences []*fasta.Sequence, core bool) []int {
+	var columns []int
+	for i := 0; i < len(sequences[0].Seq); i++ {
+		keep := true
+		if core {
+			for _, sequence := range sequences {
+				if !called(sequence.Seq[i]) {
+					keep = false
+					break
+				}
+			}
+		}
+		if keep {
+			columns = append(columns, i)
+		}
+	}
+	return columns
+}
+
+/*
+  function to count the SNPs between each pair of sequences, using a number of goroutines
+
+  sites where either sample is missing (not A, C, G or T) are skipped for that pair (pairwise deletion), or with core set, sites where any sample is missing are skipped for every pair
+*/
+func Calculate(sequences []*fasta.Sequence, core bool, threads int) (*Matrix, error) {
+	if err := check(sequences); err != nil {
+		return nil, err
+	}
+	return CalculateColumns(sequences, Columns(sequences, core), threads)
+}
+
+/*
+  function to count the SNPs between each pair of sequences at a set of sites (a site can be given more than once, e.g. for bootstrap replicates)
+*/
+func CalculateColumns(sequences []*fasta.Sequence, columns []int, threads int) (*Matrix, error) {
+	if err := check(sequences); err != nil {
+		return nil, err
+	}
+	matrix := &Matrix{Sites: len(sequences[0].Seq), Core_sites: len(columns)}
+	for _, sequence := range sequences {
+		matrix.Names = append(matrix.Names, sequence.Name)
+	}
+
+	// compare each sample with the samples after it (a row at a time)
+	n := len(sequences)
+	matrix.Distances, matrix.Compared = make([][]int, n), make([][]int, n)
+	for i := range sequences {
+		matrix.Distances[i], matrix.Compared[i] = make([]int, n), make([]int, n)
+	}
+	if threads < 1 {
+		threads = 1
+	}
+	rows := make(chan int, n)
+	var wg sync.WaitGroup
+	wg.Add(threads)
+	for worker := 0; worker < threads; worker++ {
+		go func() {
+			defer wg.Done()
+			for i := range rows {
+				a := sequences[i].Seq
+				for j := i + 1; j < n; j++ {
+					b := sequences[j].Seq
+					distance, compared := 0, 0
+					for _, column := range columns {
+						if !called(a[column]) || !called(b[column]) {
+							continue
+						}
+						compared++
+						if a[column] != b[column] {
+							distance++
+						}
+					}
+					matrix.Distances[i][j], matrix.Compared[i][j] = distance, compared
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		rows <- i
+	}
+	close(rows)
+	wg.Wait()
+
+	// fill in the lower half of the matrix and the diagonal
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			matrix.Distances[i][j], matrix.Compared[i][j] = matrix.Distances[j][i], matrix.Compared[j][i]
+		}
+		for _, column := range columns {
+			if called(sequences[i].Seq[column]) {
+				matrix.Compared[i][i]++
+			}
+		}
+	}
+	return matrix, nil
+}
+
+/*
+  function to write the distance matrix, with the fields separated by the separator (a tab or a comma)
+*/
+func (matrix *Matrix) Write(writer io.Writer, separator string) error {
+	if _, err := fmt.Fprintf(writer, "%s%s\n", separator, strings.Join(matrix.Names, separator)); err != nil {
+		return err
+	}
+	for i, name := range matrix.Names {
+		fields := []string{name}
+		for _, distance := range matrix.Distances[i] {
+			fields = append(fields, fmt.Sprintf("%d", distance))
+		}
+		if _, err := fmt.Fprintf(writer, "%s\n", strings.Join(fields, separator)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+  function to write the distances as a table with one line per pair of samples
+*/
+func (matrix *Matrix) WritePairs(writer io.Writer, separator string) error {
+	if _, err := fmt.Fprintf(writer, "%s\n", strings.Join([]string{"sample_1", "sample_2", "snps", "compared_sites"}, separator)); err != nil {
+		return err
+	}
+	for i := range matrix.Names {
+		for j := i + 1; j < len(matrix.Names); j++ {
+			fields := []string{matrix.Names[i], matrix.Names[j], fmt.Sprintf("%d", matrix.Distances[i][j]), fmt.Sprintf("%d", matrix.Compared[i][j])}
+			if _, err := fmt.Fprintf(writer, "%s\n", strings.Join(fields, separator)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+  function to read a distance matrix written by Write (the separator is a tab, or a comma if the header has no tabs)
+*/
+func ReadMatrix(file_name string) (*Matrix, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	matrix := &Matrix{}
+	separator := "\t"
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line_number := 0
+	for scanner.Scan() {
+		line_number++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) == 0 {
+			continue
+		}
+		if matrix.Names == nil {
+			if !strings.Contains(line, "\t") {
+				separator = ","
+			}
+			matrix.Names = strings.Split(line, separator)[1:]
+			continue
+		}
+		fields := strings.Split(line, separator)
+		row := len(matrix.Distances)
+		if row >= len(matrix.Names) || len(fields) != len(matrix.Names)+1 || fields[0] != matrix.Names[row] {
+			return nil, fmt.Errorf("%v: line %d: expected the rows in the same order as the columns, each with %d distances", file_name, line_number, len(matrix.Names))
+		}
+		distances := make([]int, len(matrix.Names))
+		for i, field := range fields[1:] {
+			if distances[i], err = strconv.Atoi(field); err != nil || distances[i] < 0 {
+				return nil, fmt.Errorf("%v: line %d: bad distance: %v", file_name, line_number, field)
+			}
+		}
+		matrix.Distances = append(matrix.Distances, distances)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(matrix.Names) == 0 || len(matrix.Distances) != len(matrix.Names) {
+		return nil, fmt.Errorf("%v: expected a square distance matrix", file_name)
+	}
+	for i := range matrix.Distances {
+		for j := range matrix.Distances {
+			if matrix.Distances[i][j] != matrix.Distances[j][i] {
+				return nil, fmt.Errorf("%v: the distances between %v and %v are not the same both ways", file_name, matrix.Names[i], matrix.Names[j])
+			}
+		}
+	}
+	return matrix, nil
+}
@@ -0,0 +1,174 @@
+package distance
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+)
+
+// three pseudogenomes with two contigs, missing bases (N, a gap and lowercase bases near an indel are called) and 3 SNPs
+var test_pseudogenomes = map[string]string{
+	"s1": ">_chrA\nACGTACGTACGTNNNN\n>_chrB\nGGGGCC\n",
+	"s2": ">_chrA\nACTTACGTacgtACNN\n>_chrB\nGGGACC\n",
+	"s3": ">_chrA\nNNGTACG-ACGAACGT\n>_chrB\nGGGGCC\n",
+}
+
+/*
+  function to write the test pseudogenomes and their whole genome alignment to a temporary directory
+*/
+func writePseudogenomes(t *testing.T) (string, []string, string) {
+	dir, err := ioutil.TempDir("", "distance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs []string
+	var alignment bytes.Buffer
+	for _, name := range []string{"s1", "s2", "s3"} {
+		file_name := path.Join(dir, name+".pseudogenome.fa")
+		if err := ioutil.WriteFile(file_name, []byte(test_pseudogenomes[name]), 0600); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, file_name)
+		sequences, err := fasta.Parse(strings.NewReader(test_pseudogenomes[name]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		alignment.WriteString(">" + name + "\n" + string(sequences[0].Seq) + string(sequences[1].Seq) + "\n")
+	}
+	aln := path.Join(dir, "full.aln")
+	if err := ioutil.WriteFile(aln, alignment.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir, inputs, aln
+}
+
+func TestCalculate(t *testing.T) {
+	dir, _, aln := writePseudogenomes(t)
+	defer os.RemoveAll(dir)
+	sequences, err := ReadAlignment(aln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrix, err := Calculate(sequences, false, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matrix.Distances, [][]int{{0, 2, 1}, {2, 0, 3}, {1, 3, 0}}) {
+		t.Errorf("unexpected distances: %v", matrix.Distances)
+	}
+	if !reflect.DeepEqual(matrix.Compared, [][]int{{18, 18, 15}, {18, 20, 17}, {15, 17, 19}}) || matrix.Sites != 22 || matrix.Core_sites != 22 {
+		t.Errorf("unexpected sites compared: %v (%d of %d)", matrix.Compared, matrix.Core_sites, matrix.Sites)
+	}
+
+	// with core, only the sites where every sample has a base are compared
+	core, err := Calculate(sequences, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(core.Distances, [][]int{{0, 2, 1}, {2, 0, 3}, {1, 3, 0}}) || core.Core_sites != 15 || core.Compared[0][1] != 15 {
+		t.Errorf("unexpected core distances: %v (%d sites)", core.Distances, core.Core_sites)
+	}
+}
+
+func TestPseudogenomeSites(t *testing.T) {
+	// the SNP alignment of the pseudogenomes gives the same matrix as the whole genome alignment once the sites are counted
+	dir, inputs, aln := writePseudogenomes(t)
+	defer os.RemoveAll(dir)
+	full, err := ReadAlignment(aln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snps, err := ReadPseudogenomes(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snps[0].Seq) >= len(full[0].Seq) {
+		t.Fatalf("the SNP alignment has %d sites", len(snps[0].Seq))
+	}
+	coverage, err := ReadCoverage(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, core := range []bool{false, true} {
+		expected, err := Calculate(full, core, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matrix, err := Calculate(snps, core, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := matrix.CountSites(coverage, core); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(matrix, expected) {
+			t.Errorf("core %v: the pseudogenomes gave %+v, the whole genome alignment gave %+v", core, matrix, expected)
+		}
+	}
+}
+
+func TestWriteMatrix(t *testing.T) {
+	dir, _, aln := writePseudogenomes(t)
+	defer os.RemoveAll(dir)
+	sequences, err := ReadAlignment(aln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrix, err := Calculate(sequences, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, separator := range []string{"\t", ","} {
+		var buffer bytes.Buffer
+		if err := matrix.Write(&buffer, separator); err != nil {
+			t.Fatal(err)
+		}
+		file_name := path.Join(dir, "matrix.txt")
+		if err := ioutil.WriteFile(file_name, buffer.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+		again, err := ReadMatrix(file_name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again.Names, matrix.Names) || !reflect.DeepEqual(again.Distances, matrix.Distances) {
+			t.Errorf("the matrix changed when it was read back in:\n%v", buffer.String())
+		}
+	}
+	var pairs bytes.Buffer
+	if err := matrix.WritePairs(&pairs, "\t"); err != nil {
+		t.Fatal(err)
+	}
+	if pairs.String() != "sample_1\tsample_2\tsnps\tcompared_sites\ns1\ts2\t2\t18\ns1\ts3\t1\t15\ns2\ts3\t3\t17\n" {
+		t.Errorf("unexpected pairs:\n%v", pairs.String())
+	}
+}
+
+func TestReadMatrixErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "distance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bad := map[string]string{
+		"asymmetric": "\ta\tb\na\t0\t1\nb\t2\t0\n",
+		"unordered":  "\ta\tb\nb\t1\t0\na\t0\t1\n",
+		"negative":   "\ta\tb\na\t0\t-1\nb\t-1\t0\n",
+		"short":      "\ta\tb\na\t0\t1\n",
+	}
+	for name, text := range bad {
+		file_name := path.Join(dir, name+".tsv")
+		if err := ioutil.WriteFile(file_name, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadMatrix(file_name); err == nil {
+			t.Errorf("a %v matrix was accepted", name)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+
+This package has some basic helpers for working with DNA sequences (reverse complement, IUPAC codes and translation).
+
+*/
+
+package dna
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"strings"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the complement of each IUPAC nucleotide code
+var complements = map[byte]byte{
+	'A': 'T', 'C': 'G', 'G': 'C', 'T': 'A', 'U': 'A', 'N': 'N',
+	'R': 'Y', 'Y': 'R', 'K': 'M', 'M': 'K', 'S': 'S', 'W': 'W',
+	'B': 'V', 'V': 'B', 'D': 'H', 'H': 'D',
+	'a': 't', 'c': 'g', 'g': 'c', 't': 'a', 'u': 'a', 'n': 'n',
+	'r': 'y', 'y': 'r', 'k': 'm', 'm': 'k', 's': 's', 'w': 'w',
+	'b': 'v', 'v': 'b', 'd': 'h', 'h': 'd',
+	'-': '-', '*': '*',
+}
+
+// the bacterial, archaeal and plant plastid code (NCBI translation table 11), with bases ordered TCAG
+const table_11 = "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"
+
+// the start codons for translation table 11
+var start_codons = map[string]bool{"TTG": true, "CTG": true, "ATT": true, "ATC": true, "ATA": true, "ATG": true, "GTG": true}
+
+// the IUPAC codes for each pair of bases
+var iupac_codes = map[string]byte{"AG": 'R', "CT": 'Y', "CG": 'S', "AT": 'W', "GT": 'K', "AC": 'M'}
+
+// the index of each base in the translation table
+var base_index = map[byte]int{'T': 0, 'C': 1, 'A': 2, 'G': 3, 'U': 0}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the reverse complement of a sequence
+*/
+func ReverseComplement(seq []byte) []byte {
+	rc := make([]byte, len(seq))
+	for i, base := range seq {
+		complement, ok := complements[base]
+		if !ok {
+			complement = 'N'
+		}
+		rc[len(seq)-1-i] = complement
+	}
+	return rc
+}
+
+/*
+  function to translate a codon using translation table 11 (returns X for codons containing ambiguous bases)
+*/
+func TranslateCodon(codon string) byte {
+	if len(codon) != 3 {
+		return 'X'
+	}
+	codon = strings.ToUpper(codon)
+	index := 0
+	for i := 0; i < 3; i++ {
+		base, ok := base_index[codon[i]]
+		if !ok {
+			return 'X'
+		}
+		index = index*4 + base
+	}
+	return table_11[index]
+}
+
+/*
+  function to check if a codon is a start codon in translation table 11
+*/
+func IsStartCodon(codon string) bool {
+	return start_codons[strings.ToUpper(codon)]
+}
+
+/*
+  function to translate a coding sequence (translation starts at the first base, incomplete codons are dropped)
+
+  if start is true, the first codon is translated as methionine when it is an alternative start codon
+*/
+func Translate(seq []byte, start bool) []byte {
+	protein := make([]byte, 0, len(seq)/3)
+	for i := 0; i+3 <= len(seq); i += 3 {
+		codon := string(seq[i : i+3])
+		if i == 0 && start && IsStartCodon(codon) {
+			protein = append(protein, 'M')
+			continue
+		}
+		protein = append(protein, TranslateCodon(codon))
+	}
+	return protein
+}
+
+/*
+  function to get the IUPAC code for two bases (N if either base isn't A, C, G or T)
+*/
+func IUPAC(a byte, b byte) byte {
+	a, b = upperBase(a), upperBase(b)
+	if a == b {
+		return a
+	}
+	if code, ok := iupac_codes[string([]byte{a, b})]; ok {
+		return code
+	}
+	if code, ok := iupac_codes[string([]byte{b, a})]; ok {
+		return code
+	}
+	return 'N'
+}
+
+/*
+  function to uppercase a base
+*/
+func upperBase(base byte) byte {
+	if base >= 'a' && base <= 'z' {
+		return base - 'a' + 'A'
+	}
+	return base
+}
@@ -0,0 +1,331 @@
+/*
+
+This package randomly subsamples sequencing reads down to a target fold-coverage of a reference genome.
+
+The steps included are:
+
+ * work out the genome size from the reference sequence
+ * count the bases in the read files for a sample to get the current coverage
+ * randomly keep a fraction of the reads (using a fixed seed so runs are reproducible)
+
+Paired files are read in lockstep and the same decision is made for both mates, so pairs are kept together.
+
+*/
+
+package downsample
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/reference"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// DefaultSeed is the seed used for the random number generator if none is supplied
+const DefaultSeed int64 = 42
+
+// maximum line length expected in a fastq file
+const max_line = 16 * 1024 * 1024
+
+///////////////
+// STRUCTS
+//////////////
+// fastq_record holds the 4 lines of a fastq entry
+type fastq_record [4]string
+
+// fastq_reader reads fastq records from a (possibly gzipped) file
+type fastq_reader struct {
+	file    *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+	name    string
+}
+
+// fastq_writer writes gzipped fastq records to a file
+type fastq_writer struct {
+	file   *os.File
+	gz     *gzip.Writer
+	buffer *bufio.Writer
+	name   string
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to open a plain or gzipped file for reading
+*/
+func openReader(file_name string) (*os.File, *gzip.Reader, io.Reader, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !strings.HasSuffix(file_name, ".gz") {
+		return fh, nil, fh, nil
+	}
+	gz, err := gzip.NewReader(fh)
+	if err != nil {
+		fh.Close()
+		return nil, nil, nil, fmt.Errorf("can't decompress %v: %v", file_name, err)
+	}
+	return fh, gz, gz, nil
+}
+
+/*
+  function to create a new fastq reader
+*/
+func newFastqReader(file_name string) (*fastq_reader, error) {
+	fh, gz, reader, err := openReader(file_name)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), max_line)
+	return &fastq_reader{fh, gz, scanner, file_name}, nil
+}
+
+/*
+  function to get the next record from a fastq reader (returns false once the file is finished)
+*/
+func (fq *fastq_reader) next() (fastq_record, bool, error) {
+	var record fastq_record
+	for i := 0; i < 4; i++ {
+		if !fq.scanner.Scan() {
+			if err := fq.scanner.Err(); err != nil {
+				return record, false, err
+			}
+			if i == 0 {
+				return record, false, nil
+			}
+			return record, false, fmt.Errorf("truncated fastq record in %v", fq.name)
+		}
+		record[i] = fq.scanner.Text()
+	}
+	if !strings.HasPrefix(record[0], "@") || !strings.HasPrefix(record[2], "+") {
+		return record, false, fmt.Errorf("file does not seem to be in fastq format: %v", fq.name)
+	}
+	return record, true, nil
+}
+
+/*
+  function to close a fastq reader
+*/
+func (fq *fastq_reader) close() {
+	if fq.gz != nil {
+		fq.gz.Close()
+	}
+	fq.file.Close()
+}
+
+/*
+  function to create a new gzipped fastq writer
+*/
+func newFastqWriter(file_name string) (*fastq_writer, error) {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(fh)
+	return &fastq_writer{fh, gz, bufio.NewWriter(gz), file_name}, nil
+}
+
+/*
+  function to flush and close a fastq writer, returning the first error
+*/
+func (fq *fastq_writer) close() error {
+	err := fq.buffer.Flush()
+	if gz_err := fq.gz.Close(); err == nil {
+		err = gz_err
+	}
+	if file_err := fq.file.Close(); err == nil {
+		err = file_err
+	}
+	return err
+}
+
+/*
+  function to close a fastq writer after an error, removing the partial file
+*/
+func (fq *fastq_writer) abort() {
+	fq.gz.Close()
+	fq.file.Close()
+	os.Remove(fq.name)
+}
+
+/*
+  function to get the genome size (total number of bases) of a fasta or GenBank reference
+*/
+func GenomeSize(reference_file string) (int, error) {
+	sequences, err := reference.Sequences(reference_file)
+	if err != nil {
+		return 0, err
+	}
+	genome_size := 0
+	for _, sequence := range sequences {
+		genome_size += len(sequence.Seq)
+	}
+	if genome_size == 0 {
+		return 0, fmt.Errorf("no sequence found in reference: %v", reference_file)
+	}
+	return genome_size, nil
+}
+
+/*
+  function to count the bases in a set of fastq files
+*/
+func CountBases(reads []string) (int, error) {
+	bases := 0
+	for _, file_name := range reads {
+		fq, err := newFastqReader(file_name)
+		if err != nil {
+			return 0, err
+		}
+		for {
+			record, ok, err := fq.next()
+			if err != nil {
+				fq.close()
+				return 0, err
+			}
+			if !ok {
+				break
+			}
+			bases += len(record[1])
+		}
+		fq.close()
+	}
+	return bases, nil
+}
+
+/*
+  function to get the name of a downsampled read file (output is always gzipped)
+
+  only the base name of the input is used, so Sample checks that it doesn't overwrite another file
+*/
+func outputName(file_name string, out_dir string) string {
+	return path.Join(out_dir, strings.TrimSuffix(path.Base(file_name), ".gz")+".gz")
+}
+
+/*
+  function to randomly keep a fraction of reads from one or two (paired) fastq files
+
+  for paired files, the records are read in lockstep and both mates are kept or dropped together
+*/
+func Subsample(reads []string, outputs []string, fraction float64, seed int64) (int, error) {
+	if len(reads) == 0 || len(reads) > 2 || len(reads) != len(outputs) {
+		return 0, fmt.Errorf("downsampling needs one single-end file or a pair of files")
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	// open the readers and writers
+	readers := make([]*fastq_reader, len(reads))
+	writers := make([]*fastq_writer, len(reads))
+	defer func() {
+		for i := range reads {
+			if readers[i] != nil {
+				readers[i].close()
+			}
+			if writers[i] != nil {
+				writers[i].abort()
+			}
+		}
+	}()
+	for i, file_name := range reads {
+		fq, err := newFastqReader(file_name)
+		if err != nil {
+			return 0, err
+		}
+		readers[i] = fq
+		if writers[i], err = newFastqWriter(outputs[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	// step through the records, making one decision per read (or pair)
+	kept := 0
+	for {
+		records := make([]fastq_record, len(readers))
+		finished := 0
+		for i, fq := range readers {
+			record, ok, err := fq.next()
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				finished++
+			}
+			records[i] = record
+		}
+		if finished == len(readers) {
+			break
+		} else if finished != 0 {
+			return 0, fmt.Errorf("paired files have a different number of reads: %v", strings.Join(reads, " "))
+		}
+		if rng.Float64() >= fraction {
+			continue
+		}
+		for i, record := range records {
+			for _, line := range record {
+				if _, err := writers[i].buffer.WriteString(line + "\n"); err != nil {
+					return 0, err
+				}
+			}
+		}
+		kept++
+	}
+
+	// finish the gzip streams, so a failed write is reported rather than leaving a truncated file
+	for i, writer := range writers {
+		writers[i] = nil
+		if err := writer.close(); err != nil {
+			return 0, fmt.Errorf("could not write %v: %v", writer.name, err)
+		}
+	}
+	return kept, nil
+}
+
+/*
+  function to downsample the read files for a sample to a target fold-coverage
+
+  returns the read files to use (the originals if the sample is already below the target) and the coverage before downsampling
+*/
+func Sample(reads []string, out_dir string, genome_size int, coverage float64, seed int64) ([]string, float64, error) {
+	if genome_size <= 0 || coverage <= 0 {
+		return nil, 0, fmt.Errorf("genome size and target coverage must be greater than 0")
+	}
+	bases, err := CountBases(reads)
+	if err != nil {
+		return nil, 0, err
+	}
+	current := float64(bases) / float64(genome_size)
+	if current <= coverage {
+		return reads, current, nil
+	}
+	outputs := make([]string, len(reads))
+	for i, file_name := range reads {
+		outputs[i] = outputName(file_name, out_dir)
+		if _, err := os.Stat(outputs[i]); err == nil {
+			return nil, 0, fmt.Errorf("%v already exists - are there read files with the same name in different directories?", outputs[i])
+		}
+		for j := 0; j < i; j++ {
+			if outputs[j] == outputs[i] {
+				return nil, 0, fmt.Errorf("%v and %v would both be written to %v", reads[j], file_name, outputs[i])
+			}
+		}
+	}
+	if _, err := Subsample(reads, outputs, coverage/current, seed); err != nil {
+		return nil, 0, err
+	}
+	return outputs, current, nil
+}
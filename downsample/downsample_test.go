@@ -0,0 +1,122 @@
+package downsample
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+/*
+  function to write a fastq file with a number of reads
+*/
+func writeReads(t *testing.T, file_name string, reads int, mate string) {
+	var lines []string
+	for i := 0; i < reads; i++ {
+		lines = append(lines, fmt.Sprintf("@read_%d/%s", i, mate), "ACGTACGTAC", "+", "IIIIIIIIII")
+	}
+	if err := ioutil.WriteFile(file_name, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+  function to read the read names from a gzipped fastq file
+*/
+func readNames(t *testing.T, file_name string) []string {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	gz, err := gzip.NewReader(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("%v is not a complete gzip file: %v", file_name, err)
+	}
+	var names []string
+	for i, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if i%4 == 0 {
+			names = append(names, strings.Split(line, "/")[0])
+		}
+	}
+	return names
+}
+
+func TestSubsampleKeepsPairs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "downsample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reads := []string{path.Join(dir, "s_1.fastq"), path.Join(dir, "s_2.fastq")}
+	writeReads(t, reads[0], 1000, "1")
+	writeReads(t, reads[1], 1000, "2")
+	outputs := []string{path.Join(dir, "out_1.fastq.gz"), path.Join(dir, "out_2.fastq.gz")}
+	kept, err := Subsample(reads, outputs, 0.25, DefaultSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept < 150 || kept > 350 {
+		t.Errorf("kept %d of 1000 pairs with a fraction of 0.25", kept)
+	}
+	first, second := readNames(t, outputs[0]), readNames(t, outputs[1])
+	if len(first) != kept || strings.Join(first, " ") != strings.Join(second, " ") {
+		t.Errorf("the mates were not kept together (%d and %d reads)", len(first), len(second))
+	}
+
+	// the same seed gives the same reads
+	again, err := Subsample(reads, outputs, 0.25, DefaultSeed)
+	if err != nil || again != kept {
+		t.Errorf("a second run kept %d pairs (%v), not %d", again, err, kept)
+	}
+}
+
+func TestSubsampleUnevenPairs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "downsample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reads := []string{path.Join(dir, "s_1.fastq"), path.Join(dir, "s_2.fastq")}
+	writeReads(t, reads[0], 10, "1")
+	writeReads(t, reads[1], 9, "2")
+	outputs := []string{path.Join(dir, "out_1.fastq.gz"), path.Join(dir, "out_2.fastq.gz")}
+	if _, err := Subsample(reads, outputs, 1, DefaultSeed); err == nil {
+		t.Fatal("paired files with a different number of reads were accepted")
+	}
+	for _, output := range outputs {
+		if _, err := os.Stat(output); err == nil {
+			t.Errorf("the partial output %v was left behind", output)
+		}
+	}
+}
+
+func TestSampleNameCollision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "downsample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, sub := range []string{"a", "b", "out"} {
+		if err := os.Mkdir(path.Join(dir, sub), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := path.Join(dir, "a", "s.fastq"), path.Join(dir, "b", "s.fastq")
+	writeReads(t, first, 100, "1")
+	writeReads(t, second, 100, "1")
+	out_dir := path.Join(dir, "out")
+	if _, _, err := Sample([]string{first}, out_dir, 100, 2, DefaultSeed); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Sample([]string{second}, out_dir, 100, 2, DefaultSeed); err == nil {
+		t.Error("a read file with the same name as an earlier one overwrote its downsampled reads")
+	}
+}
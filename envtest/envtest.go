@@ -45,6 +45,11 @@ var align_programs = []string{
 	"samtools",
 	"bcftools",
 }
+var tree_programs = []string{
+	"iqtree",
+	"iqtree2",
+	"raxml-ng",
+}
 var messages = []string{}
 var passed bool = true
 
@@ -84,7 +89,7 @@ func BinCheck() (passed bool, messages []string) {
 			return
 		}
 
-		// if env variable exists, test the bin for GATK, picard and vcfutils
+		// if env variable exists, test the bin for GATK and picard
 	} else {
 		passed = true
 		messages = append(messages, " * found gopherSeq_bin --> "+os.Getenv("gopherSeq_bin")+"\n")
@@ -96,26 +101,13 @@ func BinCheck() (passed bool, messages []string) {
 			messages = append(messages, " * found GATK --> "+os.Getenv("gopherSeq_bin")+"\n")
 		}
 		picard := "java -jar $gopherSeq_bin/picard.jar MarkDuplicates --version"
-		output, err := exec.Command("bash", "-c", picard).CombinedOutput()
+		output, _ := exec.Command("bash", "-c", picard).CombinedOutput()
 		if match, _ := regexp.MatchString("2.9.0", string(output)); match == false {
 			messages = append(messages, " * Picard not working - check the java and picard install\n")
 			passed = false
 		} else {
 			messages = append(messages, " * found Picard --> "+os.Getenv("gopherSeq_bin")+"\n")
 		}
-
-		// make sure they are using the vcfutils with vcf2fa
-		vcfutils := "grep vcf2fa $(which $gopherSeq_bin/vcfutils.pl)"
-		response, err := exec.Command("bash", "-c", vcfutils).Output()
-		if err != nil {
-			messages = append(messages, " * can't find vcfutils.pl in gopherSeq bin")
-			passed = false
-		} else if match, _ := regexp.MatchString("vcfutils.pl vcf2fa", string(response)); match == false {
-			messages = append(messages, " * your version of vcfutils.pl seems incorrect - please check for vcf2fa\n")
-			passed = false
-		} else {
-			messages = append(messages, " * found vcfutils.pl --> "+os.Getenv("gopherSeq_bin")+"\n")
-		}
 	}
 	return
 }
@@ -129,7 +121,7 @@ func NewBin() (passed bool) {
 	homeDir, _ := homedir.Dir()
 	newBin := homeDir + "/.gopherSeq_bin/"
 	if err := os.Mkdir(newBin, 0777); err != nil {
-		fmt.Println("can't make gopherSeq_bin - does it already exist?\n")
+		fmt.Printf("can't make gopherSeq_bin - does it already exist?\n\n")
 		fmt.Println(err)
 		passed = false
 	}
@@ -138,7 +130,6 @@ func NewBin() (passed bool) {
 	var urls = []string{
 		"https://github.com/will-rowe/gopherSeq/raw/master/bin/GenomeAnalysisTK.jar",
 		"https://github.com/will-rowe/gopherSeq/raw/master/bin/picard.jar",
-		"https://github.com/will-rowe/gopherSeq/raw/master/bin/vcfutils.pl",
 	}
 
 	// download to bin
@@ -185,7 +176,7 @@ func NewBin() (passed bool) {
 		}
 		defer f.Close()
 		if _, err = f.WriteString(exportCmd); err != nil {
-			fmt.Println("couldn't add gopherSeq_bin export statement to .profile file!\n\n")
+			fmt.Printf("couldn't add gopherSeq_bin export statement to .profile file!\n\n\n")
 			os.Exit(1)
 		}
 		return true
@@ -205,6 +196,23 @@ func Test4qcheck_progs() (bool, []string) {
 	passed, messages = ProgramTest(check_programs)
 	return passed, messages
 }
+func Test4tree_prog(program string) (bool, []string) {
+	passed, messages = true, nil
+	passed, messages = ProgramTest([]string{program})
+	return passed, messages
+}
+
+/*
+  function to check if a program is one of the ML tree builders that can be used by align
+*/
+func IsTreeProgram(program string) bool {
+	for _, tree_program := range tree_programs {
+		if program == tree_program {
+			return true
+		}
+	}
+	return false
+}
 
 /*
   functions to test for installed software
@@ -285,5 +293,12 @@ func Main() {
 		if passed == false {
 			fmt.Printf("\n!\nprogram test failed for required align programs!\n!\n\n")
 		}
+		fmt.Printf("Phylogeny programs (optional - only one is needed for align --phylogeny):\n")
+		for _, program := range tree_programs {
+			_, messages = Test4tree_prog(program)
+			for _, message := range messages {
+				fmt.Printf("%v", message)
+			}
+		}
 	}
 }
@@ -0,0 +1,309 @@
+package export
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the panels, coloring types and mutation format allowed by the Auspice v2 schema
+var auspice_panels = map[string]bool{"tree": true, "map": true, "frequencies": true, "entropy": true, "measurements": true}
+var auspice_types = map[string]bool{"categorical": true, "continuous": true, "ordinal": true, "boolean": true, "temporal": true}
+var nuc_mutation = regexp.MustCompile(`^[ACGTN-]([0-9]+)[ACGTN-]$`)
+var auspice_date = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+
+///////////////
+// STRUCTS
+//////////////
+// the parts of an Auspice v2 JSON
+type auspice struct {
+	Version string       `json:"version"`
+	Meta    auspiceMeta  `json:"meta"`
+	Tree    *auspiceNode `json:"tree"`
+}
+
+type auspiceMeta struct {
+	Title              string                       `json:"title,omitempty"`
+	Updated            string                       `json:"updated"`
+	Panels             []string                     `json:"panels"`
+	Colorings          []auspiceColoring            `json:"colorings,omitempty"`
+	Filters            []string                     `json:"filters,omitempty"`
+	Display_defaults   map[string]string            `json:"display_defaults,omitempty"`
+	Genome_annotations map[string]auspiceAnnotation `json:"genome_annotations,omitempty"`
+}
+
+type auspiceColoring struct {
+	Key   string `json:"key"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+}
+
+type auspiceAnnotation struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Strand string `json:"strand"`
+	Type   string `json:"type"`
+}
+
+type auspiceNode struct {
+	Name         string                 `json:"name"`
+	Node_attrs   map[string]interface{} `json:"node_attrs"`
+	Branch_attrs *auspiceBranch         `json:"branch_attrs,omitempty"`
+	Children     []*auspiceNode         `json:"children,omitempty"`
+}
+
+type auspiceBranch struct {
+	Mutations map[string][]string `json:"mutations,omitempty"`
+	Labels    map[string]string   `json:"labels,omitempty"`
+}
+
+type auspiceValue struct {
+	Value string `json:"value"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to convert the tree to Auspice nodes - internal nodes are given unique names (NODE_0000001 etc.) and div is the distance from the root
+*/
+func (dataset *Dataset) auspiceTree() *auspiceNode {
+	used := make(map[string]bool)
+	for _, tip := range dataset.Tree.Leaves() {
+		used[tip.Name] = true
+	}
+	count := 0
+	var convert func(node *newick.Node, div float64) *auspiceNode
+	convert = func(node *newick.Node, div float64) *auspiceNode {
+		if node.Parent != nil {
+			div += node.Length
+		}
+		converted := &auspiceNode{Name: node.Name, Node_attrs: map[string]interface{}{"div": div}}
+		if !node.IsLeaf() || len(node.Name) == 0 {
+			for {
+				count++
+				converted.Name = fmt.Sprintf("NODE_%07d", count)
+				if !used[converted.Name] {
+					break
+				}
+			}
+		}
+		used[converted.Name] = true
+		if node.IsLeaf() {
+			for i, column := range dataset.Columns {
+				if value := dataset.Value(node.Name, i); len(value) != 0 {
+					converted.Node_attrs[column] = auspiceValue{value}
+				}
+			}
+		}
+		branch := &auspiceBranch{}
+		if mutations := dataset.Mutations[node]; len(mutations) != 0 {
+			branch.Mutations = map[string][]string{"nuc": mutations}
+		}
+		if !node.IsLeaf() && node.Support != newick.NoSupport {
+			branch.Labels = map[string]string{"support": strconv.FormatFloat(node.Support, 'f', -1, 64)}
+		}
+		if branch.Mutations != nil || branch.Labels != nil {
+			converted.Branch_attrs = branch
+		}
+		for _, child := range node.Children {
+			converted.Children = append(converted.Children, convert(child, div))
+		}
+		return converted
+	}
+	return convert(dataset.Tree, 0)
+}
+
+/*
+  function to check a number in the JSON
+*/
+func number(value interface{}) (float64, bool) {
+	converted, ok := value.(float64)
+	return converted, ok
+}
+
+/*
+  function to check a JSON against the parts of the Auspice v2 schema that matter to us - the required fields, the panels and colorings, unique node names, div increasing from the root and the format of the mutations
+*/
+func CheckAuspice(data []byte) error {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	if parsed["version"] != "v2" {
+		return fmt.Errorf("the version must be v2")
+	}
+	meta, ok := parsed["meta"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("there is no meta object")
+	}
+	if updated, ok := meta["updated"].(string); !ok || !auspice_date.MatchString(updated) {
+		return fmt.Errorf("meta.updated must be a date (YYYY-MM-DD)")
+	}
+	panels, ok := meta["panels"].([]interface{})
+	if !ok || len(panels) == 0 {
+		return fmt.Errorf("meta.panels must be a list of panels")
+	}
+	genome_end := -1.0
+	if annotations, ok := meta["genome_annotations"].(map[string]interface{}); ok {
+		nuc, ok := annotations["nuc"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("meta.genome_annotations must have a nuc annotation")
+		}
+		start, ok_start := number(nuc["start"])
+		end, ok_end := number(nuc["end"])
+		if !ok_start || !ok_end || start < 1 || end < start {
+			return fmt.Errorf("meta.genome_annotations.nuc must have a start and end")
+		}
+		genome_end = end
+	}
+	for _, panel := range panels {
+		name, _ := panel.(string)
+		if !auspice_panels[name] {
+			return fmt.Errorf("%v is not an Auspice panel", panel)
+		}
+		if name == "entropy" && genome_end < 0 {
+			return fmt.Errorf("the entropy panel needs meta.genome_annotations")
+		}
+	}
+	keys := make(map[string]bool)
+	if colorings, ok := meta["colorings"].([]interface{}); ok {
+		for _, coloring := range colorings {
+			coloring, _ := coloring.(map[string]interface{})
+			key, _ := coloring["key"].(string)
+			kind, _ := coloring["type"].(string)
+			if len(key) == 0 || !auspice_types[kind] {
+				return fmt.Errorf("each coloring must have a key and a type (categorical, continuous, ordinal, boolean or temporal)")
+			}
+			keys[key] = true
+		}
+	}
+	if defaults, ok := meta["display_defaults"].(map[string]interface{}); ok {
+		if color_by, ok := defaults["color_by"].(string); ok && !keys[color_by] {
+			return fmt.Errorf("display_defaults.color_by is not one of the colorings")
+		}
+	}
+
+	// check the nodes of the tree
+	tree, ok := parsed["tree"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("there is no tree object")
+	}
+	names := make(map[string]bool)
+	var check func(node map[string]interface{}, parent_div float64) error
+	check = func(node map[string]interface{}, parent_div float64) error {
+		name, _ := node["name"].(string)
+		if len(name) == 0 || names[name] {
+			return fmt.Errorf("every node needs a unique name (%q)", name)
+		}
+		names[name] = true
+		attributes, ok := node["node_attrs"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("node %v has no node_attrs", name)
+		}
+		div, ok := number(attributes["div"])
+		if !ok || div < parent_div-1e-9 {
+			return fmt.Errorf("node %v must have a div at least as large as its parent's", name)
+		}
+		for key := range keys {
+			if value, ok := attributes[key]; ok {
+				if value, ok := value.(map[string]interface{}); !ok || value["value"] == nil {
+					return fmt.Errorf("node %v has a %v attribute without a value", name, key)
+				}
+			}
+		}
+		if branch, ok := node["branch_attrs"].(map[string]interface{}); ok {
+			mutations, _ := branch["mutations"].(map[string]interface{})
+			nuc, _ := mutations["nuc"].([]interface{})
+			for _, mutation := range nuc {
+				text, _ := mutation.(string)
+				match := nuc_mutation.FindStringSubmatch(text)
+				if match == nil {
+					return fmt.Errorf("node %v has a mutation that is not like A1234G (%v)", name, mutation)
+				}
+				if position, _ := strconv.Atoi(match[1]); genome_end >= 0 && float64(position) > genome_end {
+					return fmt.Errorf("node %v has a mutation outside the genome (%v)", name, text)
+				}
+			}
+		}
+		if children, ok := node["children"]; ok {
+			list, ok := children.([]interface{})
+			if !ok || len(list) == 0 {
+				return fmt.Errorf("the children of node %v must be a list of nodes", name)
+			}
+			for _, child := range list {
+				child, ok := child.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("the children of node %v must be a list of nodes", name)
+				}
+				if err := check(child, div); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return check(tree, 0)
+}
+
+/*
+  function to write the dataset as an Auspice v2 JSON (checking it before it is written)
+*/
+func (dataset *Dataset) WriteAuspiceJSON(writer io.Writer) error {
+	output := auspice{
+		Version: "v2",
+		Meta: auspiceMeta{
+			Title:   dataset.Title,
+			Updated: time.Now().Format("2006-01-02"),
+			Panels:  []string{"tree"},
+			Filters: dataset.Columns,
+		},
+		Tree: dataset.auspiceTree(),
+	}
+	for _, column := range dataset.Columns {
+		output.Meta.Colorings = append(output.Meta.Colorings, auspiceColoring{Key: column, Title: column, Type: "categorical"})
+	}
+	if len(dataset.Columns) != 0 {
+		output.Meta.Display_defaults = map[string]string{"color_by": dataset.Columns[0]}
+	}
+	if dataset.Length > 0 {
+		output.Meta.Panels = append(output.Meta.Panels, "entropy")
+		output.Meta.Genome_annotations = map[string]auspiceAnnotation{"nuc": auspiceAnnotation{Start: 1, End: dataset.Length, Strand: "+", Type: "source"}}
+	}
+	data, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+	if err := CheckAuspice(data); err != nil {
+		return fmt.Errorf("the Auspice JSON is not valid: %v", err)
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+/*
+  function to write the Auspice v2 JSON (<prefix>.auspice.json)
+*/
+func (dataset *Dataset) WriteAuspice(prefix string) error {
+	fh, err := os.Create(prefix + ".auspice.json")
+	if err != nil {
+		return err
+	}
+	if err := dataset.WriteAuspiceJSON(fh); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+)
+
+/*
+  function to write the test dataset (with mutations) as an Auspice JSON
+*/
+func testAuspice(t *testing.T) []byte {
+	dataset := testDataset(t)
+	sequences := []*fasta.Sequence{{Name: "a", Seq: []byte("A")}, {Name: "b", Seq: []byte("A")}, {Name: "c", Seq: []byte("G")}, {Name: "d", Seq: []byte("G")}}
+	if err := dataset.MapMutations(sequences, &Sites{Positions: []int{10}, Length: 100}); err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if err := dataset.WriteAuspiceJSON(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestWriteAuspiceJSON(t *testing.T) {
+	var output auspice
+	if err := json.Unmarshal(testAuspice(t), &output); err != nil {
+		t.Fatal(err)
+	}
+	meta := output.Meta
+	if output.Version != "v2" || meta.Title != "test" || len(meta.Colorings) != 2 || meta.Display_defaults["color_by"] != "country" || strings.Join(meta.Panels, ",") != "tree,entropy" || meta.Genome_annotations["nuc"].End != 100 {
+		t.Errorf("unexpected meta: %+v", meta)
+	}
+
+	// the internal nodes are named, and the support values and mutations are on the branches
+	ab, cd := output.Tree.Children[0], output.Tree.Children[1]
+	if output.Tree.Name != "NODE_0000001" || ab.Name != "NODE_0000002" || ab.Branch_attrs == nil || ab.Branch_attrs.Labels["support"] != "95" {
+		t.Errorf("unexpected internal node: %+v", ab)
+	}
+	if cd.Branch_attrs == nil || strings.Join(cd.Branch_attrs.Mutations["nuc"], ",") != "A10G" {
+		t.Errorf("unexpected mutations: %+v", cd.Branch_attrs)
+	}
+	tip := ab.Children[0]
+	country, _ := tip.Node_attrs["country"].(map[string]interface{})
+	if tip.Name != "a" || tip.Node_attrs["div"] != 2.0 || country["value"] != "UK" {
+		t.Errorf("unexpected tip: %+v", tip)
+	}
+	if _, ok := ab.Children[1].Node_attrs["t10"]; ok {
+		t.Error("b was given a cluster it isn't in")
+	}
+}
+
+func TestCheckAuspice(t *testing.T) {
+	data := string(testAuspice(t))
+	if err := CheckAuspice([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	bad := map[string][2]string{
+		"an old version":             {`"version":"v2"`, `"version":"v1"`},
+		"an unknown panel":           {`"panels":["tree","entropy"]`, `"panels":["tree","entropy","table"]`},
+		"entropy without a genome":   {`"genome_annotations"`, `"other_annotations"`},
+		"a bad coloring":             {`"type":"categorical"`, `"type":"colour"`},
+		"a bad color_by":             {`"color_by":"country"`, `"color_by":"host"`},
+		"a bad mutation":             {`"A10G"`, `"A10"`},
+		"a mutation past the genome": {`"A10G"`, `"A1000G"`},
+		"two nodes with one name":    {`"name":"b"`, `"name":"a"`},
+		"a negative branch":          {`"div":2`, `"div":0.5`},
+	}
+	for name, change := range bad {
+		if !strings.Contains(data, change[0]) {
+			t.Fatalf("the JSON doesn't have %v:\n%v", change[0], data)
+		}
+		if err := CheckAuspice([]byte(strings.Replace(data, change[0], change[1], 1))); err == nil {
+			t.Errorf("a JSON with %v was accepted", name)
+		}
+	}
+}
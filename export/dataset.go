@@ -0,0 +1,324 @@
+package export
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/metadata"
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the bit for each base in a set of states (anything else is missing, so could be any base)
+var base_bits = map[byte]uint8{'A': 1, 'C': 2, 'G': 4, 'T': 8}
+
+const any_base uint8 = 15
+
+///////////////
+// STRUCTS
+//////////////
+// Dataset is a tree with the metadata and mutations of its samples
+type Dataset struct {
+	Title string
+	Tree  *newick.Node
+
+	// the metadata columns, and the values of them for each sample
+	Columns []string
+	Values  map[string][]string
+
+	// the mutations on the branch above each node (e.g. A1234G), and the length of the genome they are numbered on (0 if there are no mutations)
+	Mutations map[*newick.Node][]string
+	Length    int
+}
+
+// Sites gives the position on the reference (starting from 1) of each column of an alignment
+type Sites struct {
+	Positions []int
+	Length    int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to make a new dataset for a tree, checking there is only one tip with each name
+*/
+func NewDataset(title string, tree *newick.Node) (*Dataset, error) {
+	if _, err := tree.LeafNames(); err != nil {
+		return nil, err
+	}
+	return &Dataset{Title: title, Tree: tree, Values: make(map[string][]string)}, nil
+}
+
+/*
+  function to add the columns of a metadata table (every column except the ID column) to the dataset
+*/
+func (dataset *Dataset) AddTable(table *metadata.Table, id_column string) error {
+	id, err := table.Column(id_column)
+	if err != nil {
+		return err
+	}
+	columns := table.Others(id_column)
+	for _, column := range columns {
+		for _, existing := range dataset.Columns {
+			if column == existing {
+				return fmt.Errorf("there is more than one %v column", column)
+			}
+		}
+	}
+	offset := len(dataset.Columns)
+	dataset.Columns = append(dataset.Columns, columns...)
+	for sample, values := range dataset.Values {
+		dataset.Values[sample] = append(values, make([]string, len(columns))...)
+	}
+	for _, row := range table.Rows {
+		if id >= len(row) {
+			continue
+		}
+		values, ok := dataset.Values[row[id]]
+		if !ok {
+			values = make([]string, len(dataset.Columns))
+			dataset.Values[row[id]] = values
+		}
+		j := offset
+		for i, value := range row {
+			if i == id {
+				continue
+			}
+			if j < len(values) {
+				values[j] = value
+			}
+			j++
+		}
+	}
+	return nil
+}
+
+/*
+  function to get the value of a column for a sample (empty if there isn't one)
+*/
+func (dataset *Dataset) Value(sample string, column int) string {
+	if values, ok := dataset.Values[sample]; ok {
+		return values[column]
+	}
+	return ""
+}
+
+/*
+  function to number the columns of an alignment on the reference
+
+  the positions come from the positions table of a SNP alignment (from gopherSeq snpalign), which has the contig and position of each column - if the reference has more than one contig, the positions are numbered along the contigs joined in the order of the reference. Without a positions table, the alignment is a whole genome alignment, so column i is position i
+*/
+func ReadSites(positions_file string, reference_file string, alignment_length int) (*Sites, error) {
+	sites := &Sites{}
+	offsets := make(map[string]int)
+	if len(reference_file) != 0 {
+		contigs, err := fasta.Read(reference_file)
+		if err != nil {
+			return nil, err
+		}
+		for _, contig := range contigs {
+			offsets[contig.Name] = sites.Length
+			sites.Length += len(contig.Seq)
+		}
+	}
+	if len(positions_file) == 0 {
+		for i := 1; i <= alignment_length; i++ {
+			sites.Positions = append(sites.Positions, i)
+		}
+		if sites.Length < alignment_length {
+			sites.Length = alignment_length
+		}
+		return sites, nil
+	}
+	table, err := metadata.Read(positions_file)
+	if err != nil {
+		return nil, err
+	}
+	contig_column, err := table.Column("contig")
+	if err != nil {
+		return nil, err
+	}
+	position_column, err := table.Column("position")
+	if err != nil {
+		return nil, err
+	}
+	contigs := make(map[string]bool)
+	for _, row := range table.Rows {
+		if contig_column >= len(row) || position_column >= len(row) {
+			return nil, fmt.Errorf("%v: there is a short line in the positions table", positions_file)
+		}
+		position, err := strconv.Atoi(row[position_column])
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v is not a position", positions_file, row[position_column])
+		}
+		contig := row[contig_column]
+		contigs[contig] = true
+		if len(offsets) != 0 {
+			offset, ok := offsets[contig]
+			if !ok {
+				return nil, fmt.Errorf("contig %v is not in the reference", contig)
+			}
+			position += offset
+		}
+		sites.Positions = append(sites.Positions, position)
+		if position > sites.Length {
+			sites.Length = position
+		}
+	}
+	if len(contigs) > 1 && len(offsets) == 0 {
+		return nil, fmt.Errorf("the alignment covers %d contigs - the reference is needed to number the sites", len(contigs))
+	}
+	if len(sites.Positions) != alignment_length {
+		return nil, fmt.Errorf("the positions table has %d sites but the alignment has %d", len(sites.Positions), alignment_length)
+	}
+	return sites, nil
+}
+
+/*
+  function to get the states that are most common in a set of children (Fitch parsimony - for two children, the states they share or else all of their states)
+*/
+func fitch(sets []uint8) uint8 {
+	var counts [4]int
+	most := 0
+	for _, set := range sets {
+		for bit := uint(0); bit < 4; bit++ {
+			if set&(1<<bit) != 0 {
+				counts[bit]++
+				if counts[bit] > most {
+					most = counts[bit]
+				}
+			}
+		}
+	}
+	var states uint8
+	for bit := uint(0); bit < 4; bit++ {
+		if counts[bit] == most {
+			states |= 1 << bit
+		}
+	}
+	return states
+}
+
+/*
+  function to pick one state from a set (the parent's state if it is in the set)
+*/
+func pick(set uint8, parent uint8) uint8 {
+	if set&parent != 0 {
+		return parent
+	}
+	for bit := uint(0); bit < 4; bit++ {
+		if set&(1<<bit) != 0 {
+			return 1 << bit
+		}
+	}
+	return parent
+}
+
+/*
+  function to map the mutations in an alignment onto the branches of the tree by parsimony (only the variable columns are used, and samples missing from the alignment or with an N are treated as unknown)
+*/
+func (dataset *Dataset) MapMutations(sequences []*fasta.Sequence, sites *Sites) error {
+	nodes := dataset.Tree.Nodes()
+	index := make(map[*newick.Node]int)
+	for i, node := range nodes {
+		index[node] = i
+	}
+	rows := make(map[string][]byte)
+	length := -1
+	for _, sequence := range sequences {
+		if length != -1 && len(sequence.Seq) != length {
+			return fmt.Errorf("the sequences in the alignment are not all the same length")
+		}
+		length = len(sequence.Seq)
+		rows[sequence.Name] = sequence.Seq
+	}
+	if length != len(sites.Positions) {
+		return fmt.Errorf("there are %d sites for an alignment of %d columns", len(sites.Positions), length)
+	}
+	tips := make([][]byte, len(nodes))
+	found := 0
+	for i, node := range nodes {
+		if node.IsLeaf() {
+			if tips[i] = rows[node.Name]; tips[i] != nil {
+				found++
+			}
+		}
+	}
+	if found == 0 {
+		return fmt.Errorf("none of the tips of the tree are in the alignment")
+	}
+	names := []byte{1: 'A', 2: 'C', 4: 'G', 8: 'T'}
+	dataset.Mutations = make(map[*newick.Node][]string)
+	dataset.Length = sites.Length
+	sets := make([]uint8, len(nodes))
+	states := make([]uint8, len(nodes))
+	var children []uint8
+	for column := 0; column < length; column++ {
+		var seen uint8
+		for i, node := range nodes {
+			sets[i] = any_base
+			if node.IsLeaf() && tips[i] != nil {
+				if bit, ok := base_bits[tips[i][column]]; ok {
+					sets[i] = bit
+					seen |= bit
+				}
+			}
+		}
+		if seen == 0 || seen&(seen-1) == 0 {
+			continue
+		}
+
+		// get the possible states of each node from the leaves up, then pick a state for each node from the root down
+		for i := len(nodes) - 1; i >= 0; i-- {
+			if nodes[i].IsLeaf() {
+				continue
+			}
+			children = children[:0]
+			for _, child := range nodes[i].Children {
+				children = append(children, sets[index[child]])
+			}
+			sets[i] = fitch(children)
+		}
+		for i, node := range nodes {
+			if node.Parent == nil {
+				states[i] = pick(sets[i], 0)
+				continue
+			}
+			parent := states[index[node.Parent]]
+			states[i] = pick(sets[i], parent)
+			if states[i] != parent {
+				dataset.Mutations[node] = append(dataset.Mutations[node], fmt.Sprintf("%c%d%c", names[parent], sites.Positions[column], names[states[i]]))
+			}
+		}
+	}
+	return nil
+}
+
+/*
+  function to count the mutations mapped onto the tree
+*/
+func (dataset *Dataset) CountMutations() int {
+	count := 0
+	for _, mutations := range dataset.Mutations {
+		count += len(mutations)
+	}
+	return count
+}
+
+/*
+  function to check a sample name has no characters that would break a CSV or Newick file
+*/
+func checkName(name string) error {
+	if len(name) == 0 || strings.ContainsAny(name, "\n\r") {
+		return fmt.Errorf("%q is not a valid sample name", name)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package export
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/metadata"
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+const test_tree = "((a:1,b:1)95:1,(c:1,d:1):1);"
+
+/*
+  function to make a dataset of the test tree, with the metadata and clusters of the samples
+*/
+func testDataset(t *testing.T) *Dataset {
+	tree, err := newick.Parse(test_tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataset, err := NewDataset("test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"sample,country\na,UK\nb,France\nc,UK\n", "sample\tt10\na\t1\nd\t2\n"} {
+		table, err := metadata.Parse(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dataset.AddTable(table, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dataset
+}
+
+func TestAddTable(t *testing.T) {
+	dataset := testDataset(t)
+	if !reflect.DeepEqual(dataset.Columns, []string{"country", "t10"}) {
+		t.Errorf("unexpected columns: %v", dataset.Columns)
+	}
+
+	// samples that are only in one of the tables have no value in the other
+	if dataset.Value("a", 1) != "1" || dataset.Value("d", 0) != "" || dataset.Value("d", 1) != "2" || dataset.Value("c", 1) != "" || dataset.Value("x", 0) != "" {
+		t.Errorf("unexpected values: %v", dataset.Values)
+	}
+	table, _ := metadata.Parse(strings.NewReader("sample,country\na,Spain\n"))
+	if err := dataset.AddTable(table, ""); err == nil {
+		t.Error("a second country column was added")
+	}
+	tree, _ := newick.Parse("(a,(a,b));")
+	if _, err := NewDataset("test", tree); err == nil {
+		t.Error("a tree with two tips called a was used")
+	}
+}
+
+func TestReadSites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reference, positions := path.Join(dir, "ref.fa"), path.Join(dir, "core.positions.tsv")
+	if err := ioutil.WriteFile(reference, []byte(">chrA\nACGTACGTAC\n>chrB\nGGGGG\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(positions, []byte("column\tcontig\tposition\talleles\tmissing\n1\tchrA\t3\tGT\t0\n2\tchrB\t4\tAG\t1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the positions on the second contig follow on from the first
+	sites, err := ReadSites(positions, reference, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sites.Positions, []int{3, 14}) || sites.Length != 15 {
+		t.Errorf("unexpected sites: %+v", sites)
+	}
+	if sites, err := ReadSites("", "", 3); err != nil || !reflect.DeepEqual(sites.Positions, []int{1, 2, 3}) || sites.Length != 3 {
+		t.Errorf("unexpected sites of a whole genome alignment: %+v (%v)", sites, err)
+	}
+	if _, err := ReadSites(positions, "", 2); err == nil {
+		t.Error("the sites of two contigs were numbered without the reference")
+	}
+	if _, err := ReadSites(positions, reference, 3); err == nil {
+		t.Error("the sites were numbered for an alignment of the wrong length")
+	}
+}
+
+func TestMapMutations(t *testing.T) {
+	dataset := testDataset(t)
+
+	// c and d share a G at the first site, b has a T at the second and the third site only has one base
+	sequences := []*fasta.Sequence{
+		{Name: "a", Seq: []byte("ACN")},
+		{Name: "b", Seq: []byte("ATA")},
+		{Name: "c", Seq: []byte("GCA")},
+		{Name: "d", Seq: []byte("GCA")},
+	}
+	if err := dataset.MapMutations(sequences, &Sites{Positions: []int{10, 20, 30}, Length: 100}); err != nil {
+		t.Fatal(err)
+	}
+	mutations := make(map[string][]string)
+	for node, list := range dataset.Mutations {
+		names := ""
+		for _, leaf := range node.Leaves() {
+			names += leaf.Name
+		}
+		mutations[names] = list
+	}
+	if !reflect.DeepEqual(mutations, map[string][]string{"cd": {"A10G"}, "b": {"C20T"}}) || dataset.CountMutations() != 2 || dataset.Length != 100 {
+		t.Errorf("unexpected mutations: %v", mutations)
+	}
+	if err := dataset.MapMutations(sequences, &Sites{Positions: []int{10, 20}}); err == nil {
+		t.Error("the mutations were mapped with the wrong number of sites")
+	}
+	if err := dataset.MapMutations([]*fasta.Sequence{{Name: "x", Seq: []byte("ACG")}}, &Sites{Positions: []int{1, 2, 3}}); err == nil {
+		t.Error("the mutations were mapped from an alignment without any of the tips")
+	}
+}
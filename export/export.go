@@ -0,0 +1,169 @@
+/*
+
+This package exports a tree, with the metadata and SNP clusters of its samples, for viewing in Microreact and Nextstrain Auspice.
+
+The outputs are:
+
+ * Microreact - a CSV data table (an id column matching the tips of the tree, then the metadata and cluster columns) and the tree in Newick format
+ * Auspice - a v2 JSON with the tree, the metadata and clusters as node attributes, and the mutations on each branch (mapped from a SNP alignment by parsimony)
+
+*/
+
+package export
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/metadata"
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up command line arguments
+var args struct {
+	Tree      string `arg:"positional,required,help:tree (in Newick format)"`
+	Metadata  string `arg:"-m,help:sample metadata (TSV or CSV with a header line)"`
+	Id        string `arg:"help:column of the metadata with the sample names [default: the first column]"`
+	Clusters  string `arg:"-c,help:cluster membership table from gopherSeq cluster (<prefix>.membership.tsv)"`
+	Alignment string `arg:"-a,help:SNP alignment to map the mutations onto the tree for Auspice (e.g. core.snps.aln from gopherSeq snpalign - with core.positions.tsv next to it - or a whole genome alignment)"`
+	Reference string `arg:"-r,help:reference the alignment was built on (needed to number the sites of a reference with more than one contig)"`
+	Format    string `arg:"-f,help:microreact - auspice or both [default: both]"`
+	Title     string `arg:"help:title of the Auspice view [default: the name of the tree]"`
+	Output    string `arg:"-o,help:prefix for the output files [default: ./export]"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\texports a tree with its metadata and SNP clusters for Microreact and Auspice\n\nusage:\n\tgopherSeq export [options] TREE\n\nhelp:\n\tgopherSeq export --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to find the positions table of a SNP alignment from gopherSeq snpalign (empty if there isn't one)
+*/
+func positionsFile(alignment string) string {
+	if !strings.HasSuffix(alignment, ".snps.aln") {
+		return ""
+	}
+	positions := strings.TrimSuffix(alignment, ".snps.aln") + ".positions.tsv"
+	if _, err := os.Stat(positions); err != nil {
+		return ""
+	}
+	return positions
+}
+
+/*
+  function to print an error and exit
+*/
+func exit(message string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", message, err)
+	os.Exit(1)
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	args.Format = "both"
+	args.Output = "./export"
+	arg.MustParse(&args)
+	if args.Format != "microreact" && args.Format != "auspice" && args.Format != "both" {
+		fmt.Fprintf(os.Stderr, "--format must be microreact, auspice or both\n")
+		os.Exit(1)
+	}
+	if len(args.Title) == 0 {
+		args.Title = strings.TrimSuffix(path.Base(args.Tree), path.Ext(args.Tree))
+	}
+
+	// read the tree and add the metadata and clusters
+	tree, err := newick.Read(args.Tree)
+	if err != nil {
+		exit("could not read tree", err)
+	}
+	dataset, err := NewDataset(args.Title, tree)
+	if err != nil {
+		exit("could not use tree", err)
+	}
+	if len(args.Metadata) != 0 {
+		table, err := metadata.Read(args.Metadata)
+		if err != nil {
+			exit("could not read metadata", err)
+		}
+		if err := dataset.AddTable(table, args.Id); err != nil {
+			exit("could not add metadata", err)
+		}
+	}
+	if len(args.Clusters) != 0 {
+		table, err := metadata.Read(args.Clusters)
+		if err != nil {
+			exit("could not read clusters", err)
+		}
+		if err := dataset.AddTable(table, ""); err != nil {
+			exit("could not add clusters", err)
+		}
+	}
+	matched := 0
+	for _, tip := range tree.Leaves() {
+		if _, ok := dataset.Values[tip.Name]; ok {
+			matched++
+		}
+	}
+	fmt.Fprintf(os.Stderr, " * %d tips (%d with metadata) and %d metadata columns\n", len(tree.Leaves()), matched, len(dataset.Columns))
+
+	// map the mutations from the alignment
+	if len(args.Alignment) != 0 {
+		sequences, err := fasta.Read(args.Alignment)
+		if err != nil {
+			exit("could not read alignment", err)
+		}
+		if len(sequences) == 0 {
+			exit("could not read alignment", fmt.Errorf("no sequences in %v", args.Alignment))
+		}
+		sites, err := ReadSites(positionsFile(args.Alignment), args.Reference, len(sequences[0].Seq))
+		if err != nil {
+			exit("could not number the alignment sites", err)
+		}
+		if err := dataset.MapMutations(sequences, sites); err != nil {
+			exit("could not map mutations", err)
+		}
+		fmt.Fprintf(os.Stderr, " * mapped %d mutations onto the tree\n", dataset.CountMutations())
+	}
+
+	// write the outputs
+	if args.Format != "auspice" {
+		if err := dataset.WriteMicroreact(args.Output); err != nil {
+			exit("could not write Microreact files", err)
+		}
+		fmt.Fprintf(os.Stderr, " * Microreact --> %s.microreact.csv and %s.microreact.nwk\n", args.Output, args.Output)
+	}
+	if args.Format != "microreact" {
+		if err := dataset.WriteAuspice(args.Output); err != nil {
+			exit("could not write Auspice JSON", err)
+		}
+		fmt.Fprintf(os.Stderr, " * Auspice --> %s.auspice.json\n", args.Output)
+	}
+}
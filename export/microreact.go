@@ -0,0 +1,75 @@
+package export
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to check the dataset can be loaded into Microreact - the sample names are used as the id column, so they must be unique and usable, and the metadata columns can't be called id
+*/
+func (dataset *Dataset) checkMicroreact() error {
+	for _, column := range dataset.Columns {
+		if column == "id" || len(column) == 0 {
+			return fmt.Errorf("a metadata column can't be called %q in Microreact (the sample names are the id column)", column)
+		}
+	}
+	for _, tip := range dataset.Tree.Leaves() {
+		if err := checkName(tip.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+  function to write the Microreact data table (CSV with an id column, matching the tips of the tree, then the metadata columns)
+*/
+func (dataset *Dataset) WriteMicroreactCSV(writer io.Writer) error {
+	if err := dataset.checkMicroreact(); err != nil {
+		return err
+	}
+	table := csv.NewWriter(writer)
+	if err := table.Write(append([]string{"id"}, dataset.Columns...)); err != nil {
+		return err
+	}
+	for _, tip := range dataset.Tree.Leaves() {
+		row := []string{tip.Name}
+		for i := range dataset.Columns {
+			row = append(row, dataset.Value(tip.Name, i))
+		}
+		if err := table.Write(row); err != nil {
+			return err
+		}
+	}
+	table.Flush()
+	return table.Error()
+}
+
+/*
+  function to write the files for Microreact (<prefix>.microreact.csv and <prefix>.microreact.nwk)
+*/
+func (dataset *Dataset) WriteMicroreact(prefix string) error {
+	fh, err := os.Create(prefix + ".microreact.csv")
+	if err != nil {
+		return err
+	}
+	if err := dataset.WriteMicroreactCSV(fh); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	return newick.WriteFile(prefix+".microreact.nwk", dataset.Tree)
+}
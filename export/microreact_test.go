@@ -0,0 +1,23 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMicroreactCSV(t *testing.T) {
+	dataset := testDataset(t)
+	var buffer bytes.Buffer
+	if err := dataset.WriteMicroreactCSV(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "id,country,t10\na,UK,1\nb,France,\nc,UK,\nd,,2\n" {
+		t.Errorf("unexpected Microreact table:\n%v", buffer.String())
+	}
+
+	// the sample names are the id column
+	dataset.Columns[0] = "id"
+	if err := dataset.WriteMicroreactCSV(&buffer); err == nil {
+		t.Error("a metadata column called id was written")
+	}
+}
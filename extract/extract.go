@@ -0,0 +1,173 @@
+/*
+
+This package extracts the sequence of specific genes from each sample's pseudogenome.
+
+Genes are given by name or locus tag (looked up in the reference GFF3) or as BED intervals. Minus strand genes are reverse complemented, the CDS can be translated and one multi-FASTA is written per gene, containing the gene from every sample.
+
+*/
+
+package extract
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/bed"
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+var stamp = time.Now().Format(time.RFC3339)
+
+// set up command line arguments
+var args struct {
+	Input      []string `arg:"positional,required,help:pseudogenomes to extract the genes from (e.g. pseudogenomes/*.pseudogenome.fa)"`
+	Annotation string   `arg:"-g,help:annotation of the reference (in GFF3 format)"`
+	Genes      string   `arg:"-n,help:gene names or locus tags to extract (separated by commas) - needs --annotation"`
+	Bed        string   `arg:"-b,help:BED file of the regions to extract"`
+	Translate  bool     `arg:"-p,help:also write the translated CDS of each gene [default: false]"`
+	Bed_coding bool     `arg:"-c,help:the BED regions are CDS (in frame), so translate them too with --translate [default: false]"`
+	Output_dir string   `arg:"-o,help:specify output directory"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\textracts genes from pseudogenomes and writes one multi-FASTA per gene\n\nusage:\n\tgopherSeq extract [options] --annotation GFF3 --genes gyrA,parC INPUT\n\tgopherSeq extract [options] --bed BED INPUT\n\nhelp:\n\tgopherSeq extract --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to make a gene name safe to use as a file name
+*/
+func fileName(name string) string {
+	return strings.NewReplacer("/", "_", ":", "_", " ", "_").Replace(name)
+}
+
+/*
+  function to get the regions to extract
+*/
+func getRegions() ([]*Region, error) {
+	var regions []*Region
+	if len(args.Genes) != 0 {
+		if len(args.Annotation) == 0 {
+			return nil, fmt.Errorf("--genes needs an --annotation")
+		}
+		annotation, err := gff.Read(args.Annotation)
+		if err != nil {
+			return nil, fmt.Errorf("could not read annotation: %v", err)
+		}
+		var queries []string
+		for _, query := range strings.Split(args.Genes, ",") {
+			if query = strings.TrimSpace(query); len(query) != 0 {
+				queries = append(queries, query)
+			}
+		}
+		if regions, err = FromAnnotation(annotation, queries); err != nil {
+			return nil, err
+		}
+	}
+	if len(args.Bed) != 0 {
+		intervals, err := bed.Read(args.Bed)
+		if err != nil {
+			return nil, fmt.Errorf("could not read BED file: %v", err)
+		}
+		regions = append(regions, FromBED(intervals, args.Bed_coding)...)
+	}
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no regions to extract - use --genes and --annotation, or --bed")
+	}
+	return regions, nil
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	args.Output_dir = "./gopherSeq-extract-" + string(stamp)
+	arg.MustParse(&args)
+	regions, err := getRegions()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(args.Output_dir); os.IsNotExist(err) {
+		if err := os.Mkdir(args.Output_dir, 0700); err != nil {
+			fmt.Printf("can't make output directory: %v\n", args.Output_dir)
+			os.Exit(1)
+		}
+	}
+
+	// extract the regions from each pseudogenome
+	genes := make([][]*fasta.Sequence, len(regions))
+	proteins := make([][]*fasta.Sequence, len(regions))
+	for _, input_file := range args.Input {
+		sample := fasta.SampleName(input_file)
+		records, err := fasta.Read(input_file)
+		if err != nil {
+			fmt.Printf("could not read pseudogenome: %v\n", err)
+			os.Exit(1)
+		}
+		sequences := make(map[string][]byte)
+		for _, record := range records {
+			sequences[record.Name] = record.Seq
+		}
+		for i, region := range regions {
+			seq, ok := FindSequence(sequences, region.Seqid)
+			if !ok {
+				fmt.Printf("%v has no sequence called %v\n", input_file, region.Seqid)
+				os.Exit(1)
+			}
+			extracted, err := region.Extract(seq)
+			if err != nil {
+				fmt.Printf("%v: %v\n", input_file, err)
+				os.Exit(1)
+			}
+			genes[i] = append(genes[i], &fasta.Sequence{Name: sample, Description: region.Name, Seq: extracted})
+			if args.Translate && region.Coding {
+				proteins[i] = append(proteins[i], &fasta.Sequence{Name: sample, Description: region.Name, Seq: region.Translate(extracted)})
+			}
+		}
+	}
+
+	// write one multi-FASTA per gene
+	for i, region := range regions {
+		out_file := filepath.Join(args.Output_dir, fileName(region.Name)+".fa")
+		if err := fasta.WriteFile(out_file, genes[i]); err != nil {
+			fmt.Printf("could not write %v: %v\n", out_file, err)
+			os.Exit(1)
+		}
+		fmt.Printf(" * %v (%d samples) --> %v\n", region.Name, len(genes[i]), out_file)
+		if args.Translate && region.Coding {
+			out_file = filepath.Join(args.Output_dir, fileName(region.Name)+".faa")
+			if err := fasta.WriteFile(out_file, proteins[i]); err != nil {
+				fmt.Printf("could not write %v: %v\n", out_file, err)
+				os.Exit(1)
+			}
+			fmt.Printf(" * %v (translated) --> %v\n", region.Name, out_file)
+		}
+	}
+}
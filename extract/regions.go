@@ -0,0 +1,169 @@
+package extract
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/bed"
+	"github.com/will-rowe/gopherSeq/dna"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Region is a gene (or BED interval) to extract, with its spans in biological order
+type Region struct {
+	Name   string
+	Seqid  string
+	Spans  []Span
+	Strand byte
+	Coding bool
+	Phase  int
+}
+
+// Span is a 1-based, inclusive interval of a region
+type Span struct {
+	Start int
+	End   int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to check if a gene matches a query (locus_tag, gene name, Name or ID)
+*/
+func matches(feature *gff.Feature, query string) bool {
+	for _, tag := range []string{"locus_tag", "gene", "Name", "ID"} {
+		for _, value := range feature.AttributeValues(tag) {
+			if value == query {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+  function to get the region for a gene (its CDS if it has one, otherwise the gene itself)
+*/
+func geneRegion(annotation *gff.Annotation, name string, gene *gff.Feature) *Region {
+	region := &Region{Name: name, Seqid: gene.Seqid, Strand: gene.Strand}
+	lines := []*gff.Feature{gene}
+	for _, child := range gene.Children {
+		if child.Type == "CDS" {
+			lines = annotation.ByID(child.ID())
+			if len(lines) == 0 {
+				lines = []*gff.Feature{child}
+			}
+			region.Coding = true
+			break
+		}
+	}
+
+	// put the spans in biological order
+	ordered := make([]*gff.Feature, len(lines))
+	copy(ordered, lines)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Start < ordered[j].Start })
+	if region.Strand == '-' {
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+	for _, line := range ordered {
+		region.Spans = append(region.Spans, Span{line.Start, line.End})
+	}
+	if region.Coding && ordered[0].Phase > 0 {
+		region.Phase = ordered[0].Phase
+	}
+	return region
+}
+
+/*
+  function to find the regions for a list of gene names or locus tags
+*/
+func FromAnnotation(annotation *gff.Annotation, queries []string) ([]*Region, error) {
+	var regions []*Region
+	genes := annotation.OfType("gene", "pseudogene")
+	for _, query := range queries {
+		var found []*gff.Feature
+		for _, gene := range genes {
+			if matches(gene, query) {
+				found = append(found, gene)
+			}
+		}
+		switch len(found) {
+		case 0:
+			return nil, fmt.Errorf("gene not found in the annotation: %v", query)
+		case 1:
+			regions = append(regions, geneRegion(annotation, query, found[0]))
+		default:
+			return nil, fmt.Errorf("gene name matches %d genes in the annotation (try the locus tag): %v", len(found), query)
+		}
+	}
+	return regions, nil
+}
+
+/*
+  function to get the regions from a set of BED intervals
+
+  a BED interval could be any kind of region, so it is only treated as coding (and translated) if coding is set
+*/
+func FromBED(intervals []*bed.Region, coding bool) []*Region {
+	var regions []*Region
+	for _, interval := range intervals {
+		regions = append(regions, &Region{Name: interval.Label(), Seqid: interval.Chrom, Spans: []Span{{interval.Start + 1, interval.End}}, Strand: interval.Strand, Coding: coding})
+	}
+	return regions
+}
+
+/*
+  function to find the sequence for a region's seqid (pseudogenome headers can have a prefix, e.g. ">_NC_011294.1")
+*/
+func FindSequence(sequences map[string][]byte, seqid string) ([]byte, bool) {
+	if seq, ok := sequences[seqid]; ok {
+		return seq, true
+	}
+	for name, seq := range sequences {
+		if strings.HasSuffix(name, "_"+seqid) {
+			return seq, true
+		}
+	}
+	return nil, false
+}
+
+/*
+  function to extract a region from a sequence (spans that run past the end of the sequence wrap around, minus strand regions are reverse complemented)
+*/
+func (region *Region) Extract(seq []byte) ([]byte, error) {
+	var extracted []byte
+	for _, span := range region.Spans {
+		if span.Start < 1 || span.Start > len(seq) || span.End-span.Start >= len(seq) {
+			return nil, fmt.Errorf("%v: %d-%d is outside of %v (%d bp)", region.Name, span.Start, span.End, region.Seqid, len(seq))
+		}
+		part := make([]byte, 0, span.End-span.Start+1)
+		for position := span.Start; position <= span.End; position++ {
+			part = append(part, seq[(position-1)%len(seq)])
+		}
+		if region.Strand == '-' {
+			part = dna.ReverseComplement(part)
+		}
+		extracted = append(extracted, part...)
+	}
+	return extracted, nil
+}
+
+/*
+  function to translate an extracted region (starting at its phase)
+*/
+func (region *Region) Translate(extracted []byte) []byte {
+	if region.Phase >= len(extracted) {
+		return nil
+	}
+	return dna.Translate(extracted[region.Phase:], region.Phase == 0)
+}
@@ -0,0 +1,107 @@
+package extract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/bed"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+const test_gff = `##gff-version 3
+chr	test	gene	11	22	.	+	.	ID=gene1;Name=plus;locus_tag=T001
+chr	test	CDS	11	22	.	+	0	ID=cds1;Parent=gene1
+chr	test	gene	31	42	.	-	.	ID=gene2;Name=minus;locus_tag=T002
+chr	test	CDS	31	42	.	-	0	ID=cds2;Parent=gene2
+chr	test	gene	55	65	.	+	.	ID=gene3;Name=wrap;locus_tag=T003
+chr	test	gene	1	2	.	+	.	ID=gene4;Name=wrap;locus_tag=T004
+`
+
+// the pseudogenome has the genes of the annotation (ATG AAA GGG TAA and, on the minus strand, ATG TTT CCC TGA)
+const test_seq = "CCCCCCCCCC" + "ATGAAAGGGTAA" + "CCCCCCCC" + "TCAGGGAAACAT" + "CCCCCCCCCCCCGGGGGG"
+
+func TestExtractGenes(t *testing.T) {
+	annotation, err := gff.Parse(strings.NewReader(test_gff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	regions, err := FromAnnotation(annotation, []string{"T001", "minus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct{ seq, protein string }{{"ATGAAAGGGTAA", "MKG*"}, {"ATGTTTCCCTGA", "MFP*"}}
+	for i, region := range regions {
+		if !region.Coding {
+			t.Errorf("%v should be extracted from its CDS", region.Name)
+		}
+		extracted, err := region.Extract([]byte(test_seq))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(extracted) != expected[i].seq || string(region.Translate(extracted)) != expected[i].protein {
+			t.Errorf("%v: extracted %s (%s), expected %s (%s)", region.Name, extracted, region.Translate(extracted), expected[i].seq, expected[i].protein)
+		}
+	}
+
+	// a gene name matching two genes needs the locus tag
+	if _, err := FromAnnotation(annotation, []string{"wrap"}); err == nil {
+		t.Error("an ambiguous gene name was accepted")
+	}
+	if _, err := FromAnnotation(annotation, []string{"missing"}); err == nil {
+		t.Error("a missing gene was accepted")
+	}
+}
+
+func TestExtractWrapsAround(t *testing.T) {
+	region := &Region{Name: "wrap", Seqid: "chr", Spans: []Span{{55, 65}}, Strand: '+'}
+	extracted, err := region.Extract([]byte(test_seq))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(extracted) != "GGGGGGCCCCC" {
+		t.Errorf("a region past the end of a circular sequence gave %s", extracted)
+	}
+	region.Spans = []Span{{61, 62}}
+	if _, err := region.Extract([]byte(test_seq)); err == nil {
+		t.Error("a region starting past the end of the sequence was accepted")
+	}
+}
+
+func TestFindSequence(t *testing.T) {
+	sequences := map[string][]byte{"_NC_011294.1": []byte("ACGT")}
+	if seq, ok := FindSequence(sequences, "NC_011294.1"); !ok || string(seq) != "ACGT" {
+		t.Error("a pseudogenome header with a leading underscore was not matched")
+	}
+	if _, ok := FindSequence(sequences, "NC_004741.1"); ok {
+		t.Error("a missing sequence was matched")
+	}
+}
+
+func TestFromBED(t *testing.T) {
+	intervals := []*bed.Region{{Chrom: "chr", Start: 10, End: 22, Strand: '+'}, {Chrom: "chr", Start: 30, End: 42, Name: "minus", Strand: '-'}}
+
+	// BED regions are only translated when they are marked as coding
+	for _, coding := range []bool{false, true} {
+		regions := FromBED(intervals, coding)
+		if len(regions) != 2 || regions[0].Name != "chr:11-22" || regions[1].Name != "minus" || regions[1].Strand != '-' || regions[0].Coding != coding {
+			t.Fatalf("unexpected regions: %+v", regions)
+		}
+		if extracted, err := regions[1].Extract([]byte(test_seq)); err != nil || string(extracted) != "ATGTTTCCCTGA" {
+			t.Errorf("extracted %s from the minus strand (%v)", extracted, err)
+		}
+	}
+}
+
+func TestRefSeqGene(t *testing.T) {
+	annotation, err := gff.Read("../test/data/RefSeq/NC_011294.gff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	regions, err := FromAnnotation(annotation, []string{"SEN_RS00010"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions[0].Spans) != 1 || regions[0].Spans[0] != (Span{101, 2563}) || regions[0].Strand != '+' || !regions[0].Coding {
+		t.Errorf("SEN_RS00010 should be the CDS at 101-2563 (+), found %v %c", regions[0].Spans, regions[0].Strand)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+
+This package reads and writes fasta files.
+
+It also creates samtools-compatible fasta indices (.fai) and Picard/GATK-compatible sequence dictionaries (.dict), validating the fasta file as it goes.
+
+*/
+
+package fasta
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// LineWidth is the number of bases written per line
+const LineWidth = 60
+
+// maximum line length expected in a fasta file
+const max_line = 256 * 1024 * 1024
+
+///////////////
+// STRUCTS
+//////////////
+// Sequence is a single fasta entry
+type Sequence struct {
+	Name        string
+	Description string
+	Seq         []byte
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to open a plain or gzipped file for reading
+*/
+func open(file_name string) (io.ReadCloser, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(file_name, ".gz") {
+		return fh, nil
+	}
+	gz, err := gzip.NewReader(fh)
+	if err != nil {
+		fh.Close()
+		return nil, fmt.Errorf("can't decompress %v: %v", file_name, err)
+	}
+	return &gzip_file{gz, fh}, nil
+}
+
+// gzip_file closes both the decompressor and the underlying file
+type gzip_file struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (gf *gzip_file) Close() error {
+	gf.Reader.Close()
+	return gf.file.Close()
+}
+
+/*
+  function to split a fasta header into the sequence name and description
+*/
+func splitHeader(header string) (string, string) {
+	header = strings.TrimSpace(strings.TrimPrefix(header, ">"))
+	if i := strings.IndexAny(header, " \t"); i != -1 {
+		return header[:i], strings.TrimSpace(header[i:])
+	}
+	return header, ""
+}
+
+/*
+  function to read all the sequences from a fasta reader
+*/
+func Parse(reader io.Reader) ([]*Sequence, error) {
+	var sequences []*Sequence
+	var current *Sequence
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), max_line)
+	line_number := 0
+	for scanner.Scan() {
+		line_number++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, ">") {
+			name, description := splitHeader(line)
+			current = &Sequence{Name: name, Description: description}
+			sequences = append(sequences, current)
+			continue
+		}
+		if strings.HasPrefix(line, ";") || len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: sequence found before the first fasta header", line_number)
+		}
+		current.Seq = append(current.Seq, strings.TrimSpace(line)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sequences, nil
+}
+
+/*
+  function to read all the sequences from a (possibly gzipped) fasta file
+*/
+func Read(file_name string) ([]*Sequence, error) {
+	fh, err := open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	sequences, err := Parse(fh)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file_name, err)
+	}
+	if len(sequences) == 0 {
+		return nil, fmt.Errorf("no sequences found in fasta file: %v", file_name)
+	}
+	return sequences, nil
+}
+
+/*
+  function to get the sample name from a pseudogenome (or consensus) fasta file
+*/
+func SampleName(file_name string) string {
+	sample := filepath.Base(strings.TrimSuffix(file_name, ".gz"))
+	for _, suffix := range []string{".pseudogenome.fa", ".consensus.fa", ".fasta", ".fa", ".fna"} {
+		if strings.HasSuffix(sample, suffix) {
+			return strings.TrimSuffix(sample, suffix)
+		}
+	}
+	return sample
+}
+
+/*
+  function to write a sequence in fasta format
+*/
+func Write(writer io.Writer, sequence *Sequence) error {
+	header := ">" + sequence.Name
+	if len(sequence.Description) != 0 {
+		header += " " + sequence.Description
+	}
+	if _, err := fmt.Fprintf(writer, "%s\n", header); err != nil {
+		return err
+	}
+	for i := 0; i < len(sequence.Seq); i += LineWidth {
+		end := i + LineWidth
+		if end > len(sequence.Seq) {
+			end = len(sequence.Seq)
+		}
+		if _, err := fmt.Fprintf(writer, "%s\n", sequence.Seq[i:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+  function to write a set of sequences to a fasta file
+*/
+func WriteFile(file_name string, sequences []*Sequence) error {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(fh)
+	for _, sequence := range sequences {
+		if err := Write(writer, sequence); err != nil {
+			fh.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
@@ -0,0 +1,238 @@
+package fasta
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the IUPAC nucleotide codes allowed in a reference sequence
+const allowed_bases = "ACGTNRYKMSWBDHVacgtnrykmswbdhv"
+
+// the maximum number of problems reported for a fasta file
+const max_problems = 50
+
+///////////////
+// STRUCTS
+//////////////
+// Contig holds the index information for one fasta entry (the columns of a .fai plus the MD5 used in a .dict)
+type Contig struct {
+	Name       string
+	Length     int
+	Offset     int64
+	Line_bases int
+	Line_width int
+	MD5        string
+}
+
+// ValidationError lists the problems found in a fasta file
+type ValidationError struct {
+	File     string
+	Problems []string
+}
+
+func (ve *ValidationError) Error() string {
+	return fmt.Sprintf("invalid fasta file %v:\n\t%v", ve.File, strings.Join(ve.Problems, "\n\t"))
+}
+
+// contig_scan tracks a contig while the fasta file is being read
+type contig_scan struct {
+	contig      *Contig
+	md5         hash.Hash
+	header_line int
+	short_line  int
+	bad_bases   int
+	bad_line    int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to index and validate a (plain text) fasta file
+
+  the checks are for missing or duplicate contig names, empty contigs, illegal characters and inconsistent line lengths (all lines of a contig but the last must be the same length)
+*/
+func Index(file_name string) ([]*Contig, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var contigs []*Contig
+	var current *contig_scan
+	var problems []string
+	seen := make(map[string]int)
+	report := func(line_number int, format string, a ...interface{}) {
+		if len(problems) < max_problems {
+			problems = append(problems, fmt.Sprintf("line %d: ", line_number)+fmt.Sprintf(format, a...))
+		} else if len(problems) == max_problems {
+			problems = append(problems, "too many problems, giving up on reporting the rest")
+		}
+	}
+
+	// finish off a contig once we reach the next header or the end of the file
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if current.contig.Length == 0 {
+			report(current.header_line, "contig %v is empty", current.contig.Name)
+		}
+		if current.bad_bases > 0 {
+			report(current.bad_line, "contig %v has %d illegal characters (only IUPAC nucleotide codes are allowed)", current.contig.Name, current.bad_bases)
+		}
+		current.contig.MD5 = hex.EncodeToString(current.md5.Sum(nil))
+		current = nil
+	}
+
+	// read the file line by line, keeping track of the byte offset
+	reader := bufio.NewReaderSize(fh, 1024*1024)
+	var offset int64
+	line_number := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+		line_number++
+		line_width := len(line)
+		line = bytes.TrimRight(line, "\r\n")
+		if bytes.HasPrefix(line, []byte(">")) {
+			finish()
+			name, _ := splitHeader(string(line))
+			if len(name) == 0 {
+				report(line_number, "fasta header has no sequence name")
+			} else if previous, ok := seen[name]; ok {
+				report(line_number, "duplicate contig name %v (first seen on line %d)", name, previous)
+			} else {
+				seen[name] = line_number
+			}
+			current = &contig_scan{contig: &Contig{Name: name, Offset: offset + int64(line_width)}, md5: md5.New(), header_line: line_number}
+			contigs = append(contigs, current.contig)
+			offset += int64(line_width)
+			continue
+		}
+		offset += int64(line_width)
+		if current == nil {
+			if len(bytes.TrimSpace(line)) != 0 {
+				report(line_number, "sequence found before the first fasta header")
+			}
+			continue
+		}
+
+		// check the line length is consistent with the rest of the contig
+		contig := current.contig
+		if current.short_line != 0 {
+			if len(line) != 0 {
+				report(line_number, "contig %v has inconsistent line lengths (line %d is shorter than the lines before it)", contig.Name, current.short_line)
+				current.short_line = 0
+			}
+		} else if len(line) == 0 {
+			current.short_line = line_number
+		} else if contig.Line_bases == 0 {
+			contig.Line_bases, contig.Line_width = len(line), line_width
+		} else if len(line) > contig.Line_bases {
+			report(line_number, "contig %v has inconsistent line lengths (expected %d bases per line, found %d)", contig.Name, contig.Line_bases, len(line))
+		} else if len(line) < contig.Line_bases || line_width != contig.Line_width {
+			current.short_line = line_number
+		}
+
+		// check the bases and update the checksum
+		for _, base := range line {
+			if strings.IndexByte(allowed_bases, base) == -1 {
+				if current.bad_bases == 0 {
+					current.bad_line = line_number
+				}
+				current.bad_bases++
+			}
+		}
+		contig.Length += len(line)
+		current.md5.Write(bytes.ToUpper(line))
+	}
+	finish()
+	if len(contigs) == 0 {
+		problems = append(problems, "no fasta headers found")
+	}
+	if len(problems) != 0 {
+		return nil, &ValidationError{file_name, problems}
+	}
+	return contigs, nil
+}
+
+/*
+  function to write a samtools-compatible fasta index
+*/
+func WriteIndex(file_name string, contigs []*Contig) error {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(fh)
+	for _, contig := range contigs {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%d\n", contig.Name, contig.Length, contig.Offset, contig.Line_bases, contig.Line_width)
+	}
+	if err := writer.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+/*
+  function to write a Picard/GATK-compatible sequence dictionary
+*/
+func WriteDict(file_name string, fasta_file string, contigs []*Contig) error {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return err
+	}
+	uri, err := filepath.Abs(fasta_file)
+	if err != nil {
+		uri = fasta_file
+	}
+	writer := bufio.NewWriter(fh)
+	fmt.Fprintf(writer, "@HD\tVN:1.0\tSO:unsorted\n")
+	for _, contig := range contigs {
+		fmt.Fprintf(writer, "@SQ\tSN:%s\tLN:%d\tM5:%s\tUR:file://%s\n", contig.Name, contig.Length, contig.MD5, uri)
+	}
+	if err := writer.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+/*
+  function to index a fasta file and write both the .fai (fasta.fai) and .dict (fasta basename + .dict)
+*/
+func IndexAndDict(fasta_file string) ([]*Contig, error) {
+	contigs, err := Index(fasta_file)
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteIndex(fasta_file+".fai", contigs); err != nil {
+		return nil, err
+	}
+	dict := strings.TrimSuffix(fasta_file, filepath.Ext(fasta_file)) + ".dict"
+	if err := WriteDict(dict, fasta_file, contigs); err != nil {
+		return nil, err
+	}
+	return contigs, nil
+}
@@ -0,0 +1,105 @@
+package fasta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the test reference has a multi-line contig, a soft-masked contig and a single-line contig
+const test_fasta = "testdata/ref.fa"
+
+// the expected index and dict of the test reference (UR:file://FASTA stands in for the path of the indexed copy)
+const (
+	test_fai  = "testdata/ref.fa.fai"
+	test_dict = "testdata/ref.dict"
+)
+
+/*
+  function to write a fasta file to a temporary directory
+*/
+func writeTestFasta(t *testing.T, dir string, name string, data string) string {
+	file_name := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file_name, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file_name
+}
+
+func TestIndexAndDict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile(test_fasta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fasta_file := writeTestFasta(t, dir, "ref.fa", string(data))
+	contigs, err := IndexAndDict(fasta_file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contigs) != 3 || contigs[1].Name != "chr2" || contigs[1].Length != 16 {
+		t.Errorf("unexpected contigs: %+v", contigs)
+	}
+
+	// the soft-masked contig has the MD5 of its uppercased sequence
+	for _, files := range [][3]string{{fasta_file + ".fai", test_fai, ""}, {filepath.Join(dir, "ref.dict"), test_dict, fasta_file}} {
+		got, err := ioutil.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ioutil.ReadFile(files[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.Replace(string(expected), "file://FASTA", "file://"+files[2], -1); string(got) != want {
+			t.Errorf("%v doesn't match %v:\n%v\nexpected:\n%v", files[0], files[1], string(got), want)
+		}
+	}
+}
+
+func TestIndexProblems(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		name    string
+		data    string
+		problem string
+	}{
+		{"duplicate name", ">chr1\nACGT\n>chr1 again\nACGT\n", "line 3: duplicate contig name chr1 (first seen on line 1)"},
+		{"empty contig", ">chr1\n>chr2\nACGT\n", "line 1: contig chr1 is empty"},
+		{"illegal character", ">chr1\nACGT\nAC-T\nAXGT\n", "line 3: contig chr1 has 2 illegal characters"},
+		{"ragged line length", ">chr1\nACGT\nAC\nACGT\n", "line 4: contig chr1 has inconsistent line lengths (line 3 is shorter"},
+		{"long line", ">chr1\nACGT\nACGTA\n", "line 3: contig chr1 has inconsistent line lengths (expected 4 bases per line, found 5)"},
+		{"sequence before the first header", "ACGT\n>chr1\nACGT\n", "line 1: sequence found before the first fasta header"},
+		{"no name", ">\nACGT\n", "line 1: fasta header has no sequence name"},
+		{"no headers", "", "no fasta headers found"},
+	}
+	for _, test := range tests {
+		_, err := Index(writeTestFasta(t, dir, "test.fa", test.data))
+		validation, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("%v: expected a ValidationError, got %v", test.name, err)
+			continue
+		}
+		if len(validation.Problems) != 1 || !strings.HasPrefix(validation.Problems[0], test.problem) {
+			t.Errorf("%v: unexpected problems %q", test.name, validation.Problems)
+		}
+	}
+
+	// a blank line at the end of a contig is fine
+	if _, err := Index(writeTestFasta(t, dir, "test.fa", ">chr1\nACGT\nAC\n\n>chr2\nACGT\n")); err != nil {
+		t.Error(err)
+	}
+	if _, err := Index(filepath.Join(dir, "missing.fa")); err == nil {
+		t.Error("a missing file was indexed")
+	}
+}
@@ -0,0 +1,113 @@
+/*
+
+This package filters the variant calls from bcftools call, giving each site the FILTER reasons it failed (or PASS).
+
+The filters are:
+
+ * LowQual - QUAL of a called SNP
+ * LowDepth/HighDepth - read depth (DP)
+ * LowMQ - RMS mapping quality (MQ)
+ * StrandRatio - fraction of the ALT reads (DP4) on the minor strand
+ * StrandBias - phred-scaled strand bias P-value (SP)
+ * SnpCluster - too many SNPs within a window (similar to vcfutils.pl varFilter)
+ * IndelGap - SNPs close to an indel (as vcfutils.pl varFilter -w)
+
+Only sites that PASS are used for the pseudogenome.
+
+*/
+
+package filter
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/vcf"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up command line arguments
+var args struct {
+	Input        string  `arg:"positional,required,help:variant calls from bcftools call (in VCF format - can be .gz)"`
+	Output       string  `arg:"-o,help:output VCF file (gzipped if it ends in .gz) [default: STDOUT]"`
+	Min_qual     float64 `arg:"-q,help:minimum QUAL for a SNP [default: 20]"`
+	Min_depth    int     `arg:"-d,help:minimum depth [default: 5]"`
+	Max_depth    int     `arg:"-D,help:maximum depth [default: off]"`
+	Min_mq       int     `arg:"-Q,help:minimum RMS mapping quality [default: 20]"`
+	Strand_ratio float64 `arg:"-s,help:minimum fraction of the ALT reads (DP4) on each strand [default: off]"`
+	Max_sp       int     `arg:"-b,help:maximum phred-scaled strand bias P-value (SP) [default: 40]"`
+	Snp_window   int     `arg:"-w,help:window size for the SNP density filter [default: 10]"`
+	Max_snps     int     `arg:"-n,help:maximum number of SNPs in the window [default: 3]"`
+	Indel_gap    int     `arg:"-g,help:fail SNPs within this many bases of an indel [default: 3]"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tfilters variant calls and gives each site its FILTER reasons\n\nusage:\n\tgopherSeq filter [options] INPUT\n\nhelp:\n\tgopherSeq filter --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	options := DefaultOptions()
+	args.Min_qual, args.Min_depth, args.Max_depth, args.Min_mq, args.Strand_ratio = options.Min_qual, options.Min_depth, options.Max_depth, options.Min_mq, options.Strand_ratio
+	args.Max_sp, args.Snp_window, args.Max_snps, args.Indel_gap = options.Max_sp, options.Snp_window, options.Max_snps, options.Indel_gap
+	arg.MustParse(&args)
+	options.Min_qual, options.Min_depth, options.Max_depth, options.Min_mq, options.Strand_ratio = args.Min_qual, args.Min_depth, args.Max_depth, args.Min_mq, args.Strand_ratio
+	options.Max_sp, options.Snp_window, options.Max_snps, options.Indel_gap = args.Max_sp, args.Snp_window, args.Max_snps, args.Indel_gap
+
+	// filter the calls (to STDOUT if there is no output file)
+	if len(args.Output) == 0 {
+		reader, err := vcf.Open(args.Input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read VCF: %v\n", err)
+			os.Exit(1)
+		}
+		defer reader.Close()
+		options.Describe(reader.Header)
+		writer, err := vcf.NewWriter(os.Stdout, reader.Header)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not write VCF: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := options.Filter(reader, writer); err != nil {
+			writer.Close()
+			fmt.Fprintf(os.Stderr, "could not filter VCF: %v\n", err)
+			os.Exit(1)
+		}
+		if err := writer.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write VCF: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	stats, err := options.FilterFile(args.Input, args.Output)
+	if err != nil {
+		fmt.Printf("could not filter VCF: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(" * filtered VCF --> %v (%d of %d sites passed, %d of %d SNPs passed)\n", args.Output, stats.Passed, stats.Sites, stats.SNPs_passed, stats.SNPs)
+	fmt.Printf(" * failed filters --> %s\n", stats.Summary())
+}
@@ -0,0 +1,346 @@
+package filter
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/will-rowe/gopherSeq/vcf"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the FILTER reasons given to failed sites
+const (
+	LowQual     = "LowQual"
+	LowDepth    = "LowDepth"
+	HighDepth   = "HighDepth"
+	LowMQ       = "LowMQ"
+	StrandRatio = "StrandRatio"
+	StrandBias  = "StrandBias"
+	SnpCluster  = "SnpCluster"
+	IndelGap    = "IndelGap"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Options are the thresholds for each filter (a threshold of 0 turns the filter off)
+type Options struct {
+	Min_qual     float64
+	Min_depth    int
+	Max_depth    int
+	Min_mq       int
+	Strand_ratio float64
+	Max_sp       int
+	Snp_window   int
+	Max_snps     int
+	Indel_gap    int
+}
+
+// Stats summarises a filtered VCF
+type Stats struct {
+	Sites       int
+	Passed      int
+	SNPs        int
+	SNPs_passed int
+	Reasons     map[string]int
+}
+
+// pending is a record waiting for the window filters
+type pending struct {
+	record *vcf.Record
+	snp    bool
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the default options (similar to vcfutils.pl varFilter)
+*/
+func DefaultOptions() *Options {
+	return &Options{Min_qual: 20, Min_depth: 5, Min_mq: 20, Max_sp: 40, Snp_window: 10, Max_snps: 3, Indel_gap: 3}
+}
+
+/*
+  function to add the FILTER definitions (for the filters that are turned on) to a VCF header
+*/
+func (options *Options) Describe(header *vcf.Header) {
+	if options.Min_qual > 0 {
+		header.AddFilter(LowQual, fmt.Sprintf("QUAL of a SNP below %g", options.Min_qual))
+	}
+	if options.Min_depth > 0 {
+		header.AddFilter(LowDepth, fmt.Sprintf("Read depth (DP) below %d", options.Min_depth))
+	}
+	if options.Max_depth > 0 {
+		header.AddFilter(HighDepth, fmt.Sprintf("Read depth (DP) above %d", options.Max_depth))
+	}
+	if options.Min_mq > 0 {
+		header.AddFilter(LowMQ, fmt.Sprintf("RMS mapping quality (MQ) below %d", options.Min_mq))
+	}
+	if options.Strand_ratio > 0 {
+		header.AddFilter(StrandRatio, fmt.Sprintf("Fraction of ALT reads (DP4) on the minor strand below %g", options.Strand_ratio))
+	}
+	if options.Max_sp > 0 {
+		header.AddFilter(StrandBias, fmt.Sprintf("Phred-scaled strand bias P-value (SP) above %d", options.Max_sp))
+	}
+	if options.Max_snps > 0 && options.Snp_window > 0 {
+		header.AddFilter(SnpCluster, fmt.Sprintf("More than %d SNPs within %d bp", options.Max_snps, options.Snp_window))
+	}
+	if options.Indel_gap > 0 {
+		header.AddFilter(IndelGap, fmt.Sprintf("SNP within %d bp of an indel", options.Indel_gap))
+	}
+}
+
+/*
+  function to add a FILTER reason to a record (replacing PASS)
+*/
+func fail(record *vcf.Record, reason string) {
+	var filters []string
+	for _, filter := range record.Filter {
+		if filter == reason {
+			return
+		}
+		if filter != "PASS" && filter != "." {
+			filters = append(filters, filter)
+		}
+	}
+	record.Filter = append(filters, reason)
+}
+
+/*
+  function to check if the sample has been called with an ALT allele
+*/
+func altCalled(record *vcf.Record) bool {
+	if !record.IsVariant() {
+		return false
+	}
+	if genotype := record.Genotype(0); len(genotype) != 0 {
+		for _, allele := range genotype {
+			if allele > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	if af1, ok := record.InfoFloat("AF1"); ok {
+		return af1 >= .5
+	}
+	return true
+}
+
+/*
+  function to get the phred-scaled strand bias P-value (SP of the first sample, or from the first PV4 value)
+*/
+func strandBias(record *vcf.Record) (int, bool) {
+	if sp, ok := record.SampleInt(0, "SP"); ok {
+		return sp, true
+	}
+	if pv4, ok := record.InfoFloats("PV4"); ok && len(pv4) == 4 {
+		if pv4[0] <= 0 {
+			return math.MaxInt32, true
+		}
+		return int(-10*math.Log10(pv4[0]) + .5), true
+	}
+	return 0, false
+}
+
+/*
+  function to apply the filters that only need the site itself
+*/
+func (options *Options) siteFilters(record *vcf.Record, alt_called bool) {
+	if depth, ok := record.Depth(); ok {
+		if options.Min_depth > 0 && depth < options.Min_depth {
+			fail(record, LowDepth)
+		}
+		if options.Max_depth > 0 && depth > options.Max_depth {
+			fail(record, HighDepth)
+		}
+	}
+	if mq, ok := record.MappingQuality(); ok && options.Min_mq > 0 && mq < options.Min_mq {
+		fail(record, LowMQ)
+	}
+	if !alt_called {
+		return
+	}
+	if options.Min_qual > 0 && record.Qual != vcf.MissingQual && record.Qual < options.Min_qual {
+		fail(record, LowQual)
+	}
+	if dp4, ok := record.DP4(); ok && options.Strand_ratio > 0 && dp4[2]+dp4[3] > 0 {
+		minor := dp4[2]
+		if dp4[3] < minor {
+			minor = dp4[3]
+		}
+		if float64(minor)/float64(dp4[2]+dp4[3]) < options.Strand_ratio {
+			fail(record, StrandRatio)
+		}
+	}
+	if sp, ok := strandBias(record); ok && options.Max_sp > 0 && sp > options.Max_sp {
+		fail(record, StrandBias)
+	}
+}
+
+/*
+  function to record a site in the stats and write it out
+*/
+func (stats *Stats) write(writer *vcf.Writer, site *pending) error {
+	stats.Sites++
+	if site.snp {
+		stats.SNPs++
+	}
+	if site.record.Passed() {
+		site.record.Filter = []string{"PASS"}
+		stats.Passed++
+		if site.snp {
+			stats.SNPs_passed++
+		}
+	}
+	for _, reason := range site.record.Filter {
+		if reason != "PASS" {
+			stats.Reasons[reason]++
+		}
+	}
+	return writer.Write(site.record)
+}
+
+/*
+  function to filter the records of a VCF, giving each the FILTER reasons it failed (or PASS)
+
+  the SNP density and indel gap filters look at the SNPs either side of a site, so records are held back until they are out of the window:
+
+   * SnpCluster - more than Max_snps SNPs (called ALT alleles) within Snp_window bp, all of them are failed
+   * IndelGap - SNPs within Indel_gap bp of a called indel (as varFilter -w)
+*/
+func (options *Options) Filter(reader *vcf.Reader, writer *vcf.Writer) (*Stats, error) {
+	stats := &Stats{Reasons: make(map[string]int)}
+	lookahead := options.Snp_window
+	if options.Indel_gap+1 > lookahead {
+		lookahead = options.Indel_gap + 1
+	}
+	var queue []*pending
+	var indels [][2]int
+	chrom, last_pos := "", 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// write out the records that are out of the window
+		if record.Chrom != chrom {
+			chrom, indels = record.Chrom, nil
+		} else if record.Pos < last_pos {
+			return nil, fmt.Errorf("unsorted input at %v:%d", record.Chrom, record.Pos)
+		}
+		for len(queue) != 0 && (queue[0].record.Chrom != chrom || queue[0].record.Pos+lookahead <= record.Pos) {
+			if err := stats.write(writer, queue[0]); err != nil {
+				return nil, err
+			}
+			queue = queue[1:]
+		}
+		last_pos = record.Pos
+
+		// filter the site
+		alt_called := altCalled(record)
+		options.siteFilters(record, alt_called)
+		site := &pending{record: record, snp: alt_called && !record.IsIndel()}
+		queue = append(queue, site)
+
+		// fail SNPs near a called indel
+		if options.Indel_gap > 0 {
+			if alt_called && record.IsIndel() {
+				indel := [2]int{record.Pos - options.Indel_gap, record.Pos + len(record.Ref) - 1 + options.Indel_gap}
+				indels = append(indels, indel)
+				for _, queued := range queue {
+					if queued.snp && queued.record.Pos >= indel[0] && queued.record.Pos <= indel[1] {
+						fail(queued.record, IndelGap)
+					}
+				}
+			}
+			kept := indels[:0]
+			for _, indel := range indels {
+				if indel[1] >= record.Pos {
+					kept = append(kept, indel)
+					if site.snp && record.Pos >= indel[0] {
+						fail(record, IndelGap)
+					}
+				}
+			}
+			indels = kept
+		}
+
+		// fail clusters of SNPs
+		if site.snp && options.Max_snps > 0 && options.Snp_window > 0 {
+			var window []*pending
+			for _, queued := range queue {
+				if queued.snp && queued.record.Pos > record.Pos-options.Snp_window {
+					window = append(window, queued)
+				}
+			}
+			if len(window) > options.Max_snps {
+				for _, queued := range window {
+					fail(queued.record, SnpCluster)
+				}
+			}
+		}
+	}
+	for _, site := range queue {
+		if err := stats.write(writer, site); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+/*
+  function to filter a VCF file (plain, gzip or BGZF) and write the filtered VCF (gzipped if the name ends in .gz)
+*/
+func (options *Options) FilterFile(vcf_file string, out_file string) (*Stats, error) {
+	reader, err := vcf.Open(vcf_file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	options.Describe(reader.Header)
+	writer, err := vcf.Create(out_file, reader.Header)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := options.Filter(reader, writer)
+	if err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("%v: %v", vcf_file, err)
+	}
+	return stats, writer.Close()
+}
+
+/*
+  function to list the FILTER reasons (and how many sites failed each) in alphabetical order
+*/
+func (stats *Stats) Summary() string {
+	var reasons []string
+	for reason := range stats.Reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	summary := ""
+	for i, reason := range reasons {
+		if i != 0 {
+			summary += " "
+		}
+		summary += fmt.Sprintf("%s=%d", reason, stats.Reasons[reason])
+	}
+	if len(summary) == 0 {
+		return "none"
+	}
+	return summary
+}
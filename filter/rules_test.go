@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/vcf"
+)
+
+// a site of the test VCF
+type test_site struct {
+	pos      int
+	ref, alt string
+	qual     int
+	info     string
+	sample   string
+	expected string
+}
+
+// sites that each fail one filter (with the default options), a cluster of 4 SNPs in 10 bp and SNPs either side of a deletion
+var test_sites = []test_site{
+	{1, "A", ".", 30, "DP=10;MQ=60", "0:0", "PASS"},
+	{5, "C", "T", 200, "DP=3;MQ=60;DP4=0,0,2,1", "1:0", "LowDepth"},
+	{10, "G", "A", 10, "DP=20;MQ=60;DP4=0,0,10,10", "1:0", "LowQual"},
+	{15, "T", "C", 200, "DP=20;MQ=10;DP4=0,0,10,10", "1:0", "LowMQ"},
+	{20, "A", "G", 200, "DP=20;MQ=60;DP4=0,0,19,1", "1:50", "StrandBias"},
+	{40, "C", "T", 200, "DP=20;MQ=60;DP4=0,0,10,10", "1:0", "SnpCluster"},
+	{42, "C", "T", 200, "DP=20;MQ=60;DP4=0,0,10,10", "1:0", "SnpCluster"},
+	{44, "C", "T", 200, "DP=20;MQ=60;DP4=0,0,10,10", "1:0", "SnpCluster"},
+	{46, "C", "T", 200, "DP=20;MQ=60;DP4=0,0,10,10", "1:0", "SnpCluster"},
+	{68, "G", "A", 200, "DP=20;MQ=60;DP4=0,0,10,10", "1:0", "IndelGap"},
+	{70, "AT", "A", 200, "INDEL;DP=20;MQ=60;DP4=0,0,10,10", "1:0", "PASS"},
+	{73, "G", "A", 200, "DP=20;MQ=60;DP4=0,0,10,10", "1:0", "IndelGap"},
+	{76, "G", "A", 200, "DP=20;MQ=60;DP4=0,0,10,10", "1:0", "PASS"},
+	{100, "T", "G", 200, "DP=20;MQ=60;DP4=0,0,10,10", "1:0", "PASS"},
+}
+
+/*
+  function to filter the test sites, returning the filtered records and the stats
+*/
+func filterSites(t *testing.T, options *Options) ([]*vcf.Record, *Stats, *vcf.Header) {
+	text := "##fileformat=VCFv4.2\n#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tsample\n"
+	for _, site := range test_sites {
+		text += fmt.Sprintf("chr\t%d\t.\t%s\t%s\t%d\t.\t%s\tGT:SP\t%s\n", site.pos, site.ref, site.alt, site.qual, site.info, site.sample)
+	}
+	reader, err := vcf.NewReader(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.Describe(reader.Header)
+	var buffer bytes.Buffer
+	writer, err := vcf.NewWriter(&buffer, reader.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := options.Filter(reader, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := vcf.NewReader(strings.NewReader(buffer.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []*vcf.Record
+	for {
+		record, err := filtered.Read()
+		if err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+	return records, stats, filtered.Header
+}
+
+func TestFilterReasons(t *testing.T) {
+	records, stats, header := filterSites(t, DefaultOptions())
+	if len(records) != len(test_sites) {
+		t.Fatalf("wrote %d of %d records", len(records), len(test_sites))
+	}
+	for i, record := range records {
+		if record.Pos != test_sites[i].pos || record.FilterString() != test_sites[i].expected {
+			t.Errorf("site %d has FILTER %v, expected %v", record.Pos, record.FilterString(), test_sites[i].expected)
+		}
+	}
+	if stats.Sites != 14 || stats.Passed != 4 || stats.SNPs != 12 || stats.SNPs_passed != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if summary := stats.Summary(); summary != "IndelGap=2 LowDepth=1 LowMQ=1 LowQual=1 SnpCluster=4 StrandBias=1" {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+	if header.Filter[SnpCluster] == nil || header.Filter[HighDepth] != nil {
+		t.Error("the header should only describe the filters that are turned on")
+	}
+}
+
+func TestFiltersOff(t *testing.T) {
+	// with every threshold at 0 (as align without --filter) every site passes, so the pseudogenomes are the same as vcf2fa
+	records, stats, header := filterSites(t, &Options{})
+	for _, record := range records {
+		if !record.Passed() {
+			t.Errorf("site %d failed %v with the filters off", record.Pos, record.FilterString())
+		}
+	}
+	if stats.SNPs != 12 || stats.SNPs_passed != 12 || stats.Summary() != "none" || len(header.Filter) != 0 {
+		t.Errorf("unexpected stats with the filters off: %+v", stats)
+	}
+}
+
+func TestStrandRatio(t *testing.T) {
+	options := &Options{Strand_ratio: 0.1}
+	records, _, _ := filterSites(t, options)
+	for _, record := range records {
+		if failed := !record.Passed(); failed != (record.Pos == 20) {
+			t.Errorf("site %d has FILTER %v with a strand ratio of 0.1", record.Pos, record.FilterString())
+		}
+	}
+}
@@ -0,0 +1,463 @@
+/*
+
+This package reads GenBank flat files.
+
+Each GenBank record is parsed into its sequence and features, so that it can be written as a fasta sequence (for indexing) and a GFF3 annotation (for downstream annotation and masking).
+
+*/
+
+package genbank
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the column where feature locations and qualifiers start
+const qualifier_column = 21
+
+// maximum line length expected in a GenBank file
+const max_line = 16 * 1024 * 1024
+
+///////////////
+// STRUCTS
+//////////////
+// Record is a single GenBank entry
+type Record struct {
+	Locus      string
+	Accession  string
+	Version    string
+	Definition string
+	Molecule   string
+	Circular   bool
+	Length     int
+	Features   []*Feature
+	Skipped    []string
+	Seq        []byte
+}
+
+// Feature is an entry in the GenBank feature table
+type Feature struct {
+	Key        string
+	Location   string
+	Spans      []Span
+	Strand     byte
+	Partial    bool
+	Qualifiers []Qualifier
+}
+
+// Span is one (1-based, inclusive) interval of a feature location
+type Span struct {
+	Start int
+	End   int
+}
+
+// UnsupportedLocation is the error for a valid feature location that can't be given as spans on one strand of the record
+type UnsupportedLocation struct {
+	Location string
+	Reason   string
+}
+
+func (ul *UnsupportedLocation) Error() string {
+	return fmt.Sprintf("unsupported feature location %v: %v", ul.Location, ul.Reason)
+}
+
+// Qualifier is a /key=value pair from the feature table
+type Qualifier struct {
+	Key   string
+	Value string
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the name to use for the sequence (accession.version if available, otherwise the locus name)
+*/
+func (record *Record) Name() string {
+	if len(record.Version) != 0 {
+		return record.Version
+	}
+	if len(record.Accession) != 0 {
+		return record.Accession
+	}
+	return record.Locus
+}
+
+/*
+  function to get the first value of a qualifier (and whether it was found)
+*/
+func (feature *Feature) Qualifier(key string) (string, bool) {
+	for _, qualifier := range feature.Qualifiers {
+		if qualifier.Key == key {
+			return qualifier.Value, true
+		}
+	}
+	return "", false
+}
+
+/*
+  function to check if a file name looks like a GenBank file
+*/
+func IsGenBank(file_name string) bool {
+	file_name = strings.ToLower(strings.TrimSuffix(file_name, ".gz"))
+	for _, ext := range []string{".gb", ".gbk", ".gbff", ".genbank"} {
+		if strings.HasSuffix(file_name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+  function to parse a feature location into spans (e.g. complement(join(<1..100,200..>300)))
+
+  spans are returned in biological order, so a minus strand feature lists its spans from the 5' end of the feature
+*/
+func ParseLocation(location string) ([]Span, byte, bool, error) {
+	location = strings.Replace(location, " ", "", -1)
+	partial := strings.ContainsAny(location, "<>")
+	spans, complement, err := parseLocation(strings.NewReplacer("<", "", ">", "").Replace(location))
+	if unsupported, ok := err.(*UnsupportedLocation); ok {
+		unsupported.Location = location
+		return nil, 0, false, unsupported
+	}
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("can't parse feature location %v: %v", location, err)
+	}
+	if len(spans) == 0 {
+		return nil, 0, false, fmt.Errorf("can't parse feature location %v", location)
+	}
+	strand := byte('+')
+	if complement {
+		strand = '-'
+	}
+	return spans, strand, partial, nil
+}
+
+/*
+  function to recursively parse a location string
+*/
+func parseLocation(location string) ([]Span, bool, error) {
+	switch {
+	case strings.HasPrefix(location, "complement(") && strings.HasSuffix(location, ")"):
+		spans, complement, err := parseLocation(location[len("complement(") : len(location)-1])
+		if err != nil {
+			return nil, false, err
+		}
+		for i, j := 0, len(spans)-1; i < j; i, j = i+1, j-1 {
+			spans[i], spans[j] = spans[j], spans[i]
+		}
+		return spans, !complement, nil
+	case (strings.HasPrefix(location, "join(") || strings.HasPrefix(location, "order(")) && strings.HasSuffix(location, ")"):
+		inner := location[strings.Index(location, "(")+1 : len(location)-1]
+		var spans []Span
+		complement := false
+		for i, part := range splitTopLevel(inner) {
+			part_spans, part_complement, err := parseLocation(part)
+			if err != nil {
+				return nil, false, err
+			}
+			if i == 0 {
+				complement = part_complement
+			} else if part_complement != complement {
+				return nil, false, &UnsupportedLocation{Reason: "mixed strand joins are not supported"}
+			}
+			spans = append(spans, part_spans...)
+		}
+
+		// for join(complement(a),complement(b)) the feature runs from b to a
+		if complement {
+			for i, j := 0, len(spans)-1; i < j; i, j = i+1, j-1 {
+				spans[i], spans[j] = spans[j], spans[i]
+			}
+		}
+		return spans, complement, nil
+	case strings.Contains(location, ":"):
+		return nil, false, &UnsupportedLocation{Reason: "locations on other records are not supported"}
+	}
+
+	// a simple location: 100..200, 100^101 or 100
+	var start, end int
+	var err error
+	if i := strings.Index(location, ".."); i != -1 {
+		if start, err = strconv.Atoi(location[:i]); err == nil {
+			end, err = strconv.Atoi(location[i+2:])
+		}
+	} else if i := strings.Index(location, "^"); i != -1 {
+		if start, err = strconv.Atoi(location[:i]); err == nil {
+			end = start
+		}
+	} else if i := strings.Index(location, "."); i != -1 {
+		if start, err = strconv.Atoi(location[:i]); err == nil {
+			end, err = strconv.Atoi(location[i+1:])
+		}
+	} else {
+		start, err = strconv.Atoi(location)
+		end = start
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if start > end {
+		start, end = end, start
+	}
+	return []Span{{start, end}}, false, nil
+}
+
+/*
+  function to split a comma separated list, ignoring commas inside brackets
+*/
+func splitTopLevel(list string) []string {
+	var parts []string
+	depth, last := 0, 0
+	for i, char := range list {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, list[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, list[last:])
+}
+
+/*
+  function to finish a qualifier value (remove the quotes and, for translations, the line breaks)
+*/
+func cleanQualifier(qualifier Qualifier) Qualifier {
+	value := qualifier.Value
+	if strings.HasPrefix(value, "\"") {
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "\""), "\"")
+		value = strings.Replace(value, "\"\"", "\"", -1)
+	}
+	if qualifier.Key == "translation" {
+		value = strings.Replace(value, " ", "", -1)
+	}
+	return Qualifier{qualifier.Key, value}
+}
+
+/*
+  function to check if a qualifier value is still waiting for its closing quote
+*/
+func openQuote(value string) bool {
+	return strings.HasPrefix(value, "\"") && (len(value) == 1 || strings.Count(value, "\"")%2 == 1)
+}
+
+/*
+  function to read all the records from a GenBank reader
+
+  features with an unsupported location (e.g. a join across both strands) are left out of the record and listed in its Skipped field
+*/
+func Parse(reader io.Reader) ([]*Record, error) {
+	var records []*Record
+	var record *Record
+	var feature *Feature
+	var qualifier *Qualifier
+	section := ""
+	line_number, feature_line := 0, 0
+
+	// finish off the current qualifier and feature
+	finishQualifier := func() {
+		if qualifier != nil {
+			feature.Qualifiers = append(feature.Qualifiers, cleanQualifier(*qualifier))
+			qualifier = nil
+		}
+	}
+	finishFeature := func() error {
+		finishQualifier()
+		if feature == nil {
+			return nil
+		}
+		spans, strand, partial, err := ParseLocation(feature.Location)
+		if _, ok := err.(*UnsupportedLocation); ok {
+			record.Skipped = append(record.Skipped, fmt.Sprintf("line %d: skipped %v feature (%v)", feature_line, feature.Key, err))
+			feature = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		feature.Spans, feature.Strand, feature.Partial = spans, strand, partial
+		record.Features = append(record.Features, feature)
+		feature = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), max_line)
+	for scanner.Scan() {
+		line_number++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		// the start and end of a record
+		if strings.HasPrefix(line, "LOCUS") {
+			record = &Record{}
+			fields := strings.Fields(line)
+			if len(fields) > 1 {
+				record.Locus = fields[1]
+			}
+			for i, field := range fields {
+				if (field == "bp" || field == "aa") && i > 0 {
+					record.Length, _ = strconv.Atoi(fields[i-1])
+					if i+1 < len(fields) {
+						record.Molecule = fields[i+1]
+					}
+				}
+				if field == "circular" {
+					record.Circular = true
+				}
+			}
+			section = "LOCUS"
+			continue
+		}
+		if record == nil {
+			return nil, fmt.Errorf("line %d: expected a LOCUS line", line_number)
+		}
+		if strings.HasPrefix(line, "//") {
+			if err := finishFeature(); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line_number, err)
+			}
+			if len(record.Seq) == 0 {
+				return nil, fmt.Errorf("line %d: record %v has no sequence (ORIGIN)", line_number, record.Locus)
+			}
+			if record.Length != 0 && record.Length != len(record.Seq) {
+				return nil, fmt.Errorf("line %d: record %v should be %d bp but the sequence is %d bp", line_number, record.Locus, record.Length, len(record.Seq))
+			}
+			records = append(records, record)
+			record, section = nil, ""
+			continue
+		}
+
+		// a new top level section
+		if line[0] != ' ' {
+			if err := finishFeature(); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line_number, err)
+			}
+			fields := strings.SplitN(line, " ", 2)
+			section = fields[0]
+			value := ""
+			if len(fields) == 2 {
+				value = strings.TrimSpace(fields[1])
+			}
+			switch section {
+			case "DEFINITION":
+				record.Definition = value
+			case "ACCESSION":
+				if accession := strings.Fields(value); len(accession) != 0 {
+					record.Accession = accession[0]
+				}
+			case "VERSION":
+				if version := strings.Fields(value); len(version) != 0 {
+					record.Version = version[0]
+				}
+			}
+			continue
+		}
+
+		// the contents of a section
+		switch section {
+		case "DEFINITION":
+			if strings.HasPrefix(line, "            ") {
+				record.Definition += " " + strings.TrimSpace(line)
+			}
+		case "ORIGIN":
+			for _, char := range []byte(line) {
+				// GenBank sequences are written in lowercase, which doesn't mean they are soft-masked
+				if char >= 'a' && char <= 'z' {
+					record.Seq = append(record.Seq, char-'a'+'A')
+				} else if (char >= 'A' && char <= 'Z') || char == '-' || char == '*' {
+					record.Seq = append(record.Seq, char)
+				}
+			}
+		case "FEATURES":
+			if len(line) <= qualifier_column || strings.TrimSpace(line[:qualifier_column]) != "" {
+				// a new feature key
+				if err := finishFeature(); err != nil {
+					return nil, fmt.Errorf("line %d: %v", line_number, err)
+				}
+				fields := strings.Fields(line)
+				if len(fields) != 2 {
+					return nil, fmt.Errorf("line %d: can't read feature: %v", line_number, strings.TrimSpace(line))
+				}
+				feature, feature_line = &Feature{Key: fields[0], Location: fields[1]}, line_number
+				continue
+			}
+			if feature == nil {
+				return nil, fmt.Errorf("line %d: feature qualifier found before a feature", line_number)
+			}
+			text := strings.TrimSpace(line[qualifier_column:])
+			if qualifier != nil && openQuote(qualifier.Value) {
+				// the continuation of a quoted qualifier
+				qualifier.Value += " " + text
+			} else if strings.HasPrefix(text, "/") {
+				// a new qualifier
+				finishQualifier()
+				parts := strings.SplitN(text[1:], "=", 2)
+				qualifier = &Qualifier{Key: parts[0]}
+				if len(parts) == 2 {
+					qualifier.Value = parts[1]
+				}
+			} else if qualifier != nil {
+				qualifier.Value += " " + text
+			} else {
+				// the continuation of a location
+				feature.Location += text
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if record != nil {
+		return nil, fmt.Errorf("record %v is missing its end (//)", record.Locus)
+	}
+	return records, nil
+}
+
+/*
+  function to read all the records from a (possibly gzipped) GenBank file
+*/
+func Read(file_name string) ([]*Record, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var reader io.Reader = fh
+	if strings.HasSuffix(file_name, ".gz") {
+		gz, err := gzip.NewReader(fh)
+		if err != nil {
+			return nil, fmt.Errorf("can't decompress %v: %v", file_name, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	records, err := Parse(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file_name, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records found in GenBank file: %v", file_name)
+	}
+	return records, nil
+}
@@ -0,0 +1,135 @@
+package genbank
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// the test record is a 60 bp circular plasmid with a partial minus strand CDS, a split CDS, a CDS crossing the origin and a mixed strand join
+const test_genbank = "testdata/test.gbk"
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		spans    []Span
+		strand   byte
+		partial  bool
+	}{
+		{"100..200", []Span{{100, 200}}, '+', false},
+		{"100", []Span{{100, 100}}, '+', false},
+		{"100^101", []Span{{100, 100}}, '+', false},
+		{"<1..>300", []Span{{1, 300}}, '+', true},
+		{"complement(5..20)", []Span{{5, 20}}, '-', false},
+		{"join(1..10,20..30)", []Span{{1, 10}, {20, 30}}, '+', false},
+		{"join(55..60,1..6)", []Span{{55, 60}, {1, 6}}, '+', false},
+		{"complement(join(1..10,20..30))", []Span{{20, 30}, {1, 10}}, '-', false},
+		{"join(complement(1..10),complement(20..30))", []Span{{20, 30}, {1, 10}}, '-', false},
+		{"order(1..10, 20..>30)", []Span{{1, 10}, {20, 30}}, '+', true},
+	}
+	for _, test := range tests {
+		spans, strand, partial, err := ParseLocation(test.location)
+		if err != nil {
+			t.Errorf("%v: %v", test.location, err)
+			continue
+		}
+		if !reflect.DeepEqual(spans, test.spans) || strand != test.strand || partial != test.partial {
+			t.Errorf("%v: unexpected location %v %c %v", test.location, spans, strand, partial)
+		}
+	}
+
+	// joins across strands and references to other records can't be given as spans
+	for _, location := range []string{"join(35..40,complement(42..45))", "join(1..10,J00194.1:100..202)"} {
+		if _, _, _, err := ParseLocation(location); err == nil {
+			t.Errorf("%v was parsed", location)
+		} else if _, ok := err.(*UnsupportedLocation); !ok {
+			t.Errorf("%v: unexpected error %v", location, err)
+		}
+	}
+	for _, location := range []string{"", "1..x", "join()"} {
+		if _, _, _, err := ParseLocation(location); err == nil {
+			t.Errorf("%q was parsed", location)
+		} else if _, ok := err.(*UnsupportedLocation); ok {
+			t.Errorf("%q was only unsupported: %v", location, err)
+		}
+	}
+}
+
+func TestCdsPhases(t *testing.T) {
+	tests := []struct {
+		codon_start string
+		spans       []Span
+		phases      []int
+	}{
+		{"", []Span{{1, 9}}, []int{0}},
+		{"2", []Span{{5, 20}}, []int{1}},
+		{"3", []Span{{5, 20}}, []int{2}},
+		{"", []Span{{25, 31}, {33, 40}}, []int{0, 2}},
+		{"", []Span{{20, 30}, {1, 10}}, []int{0, 1}},
+		{"2", []Span{{1, 4}, {10, 12}, {20, 30}}, []int{1, 0, 0}},
+	}
+	for _, test := range tests {
+		feature := &Feature{Key: "CDS", Spans: test.spans}
+		if len(test.codon_start) != 0 {
+			feature.Qualifiers = []Qualifier{{"codon_start", test.codon_start}}
+		}
+		if phases := cdsPhases(feature); !reflect.DeepEqual(phases, test.phases) {
+			t.Errorf("codon_start %q and spans %v: phases %v, expected %v", test.codon_start, test.spans, phases, test.phases)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	records, err := Read(test_genbank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("read %d records", len(records))
+	}
+	record := records[0]
+	if record.Name() != "TEST1.1" || record.Locus != "TEST1" || record.Length != 60 || !record.Circular || record.Molecule != "DNA" || record.Definition != "Test plasmid for the GenBank reader, complete sequence." {
+		t.Errorf("unexpected record: %+v", record)
+	}
+	if len(record.Seq) != 60 || strings.ToUpper(string(record.Seq)) != string(record.Seq) {
+		t.Errorf("unexpected sequence: %s", record.Seq)
+	}
+
+	// the mixed strand join is skipped and the rest of the features are read
+	if len(record.Features) != 7 || !reflect.DeepEqual(record.Skipped, []string{"line 26: skipped misc_feature feature (unsupported feature location join(35..40,complement(42..45)): mixed strand joins are not supported)"}) {
+		t.Fatalf("read %d features, skipping %q", len(record.Features), record.Skipped)
+	}
+	cds := record.Features[2]
+	if cds.Key != "CDS" || cds.Strand != '-' || !cds.Partial || !reflect.DeepEqual(cds.Spans, []Span{{5, 20}}) {
+		t.Errorf("unexpected CDS: %+v", cds)
+	}
+
+	// multi-line qualifiers are joined (without spaces for translations)
+	if product, _ := cds.Qualifier("product"); product != "a partial protein with a long name" {
+		t.Errorf("unexpected product: %q", product)
+	}
+	if translation, _ := cds.Qualifier("translation"); translation != "MKVLA" {
+		t.Errorf("unexpected translation: %q", translation)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	record := "LOCUS       TEST1   8 bp    DNA     linear\nFEATURES             Location/Qualifiers\n     gene            1..4\nORIGIN\n        1 acgtacgt\n//\n"
+	if _, err := Parse(strings.NewReader(record)); err != nil {
+		t.Fatal(err)
+	}
+	bad := map[string][2]string{
+		"no LOCUS line":     {"LOCUS", "LOCAL"},
+		"a missing end":     {"//\n", ""},
+		"the wrong length":  {"8 bp", "9 bp"},
+		"no sequence":       {"        1 acgtacgt\n", ""},
+		"a bad location":    {"1..4", "1..x"},
+		"a qualifier alone": {"     gene            1..4\n", "                     /gene=\"a\"\n"},
+		"a broken feature":  {"gene            1..4", "gene 1..4 extra"},
+	}
+	for name, change := range bad {
+		if _, err := Parse(strings.NewReader(strings.Replace(record, change[0], change[1], 1))); err == nil {
+			t.Errorf("a record with %v was parsed", name)
+		}
+	}
+}
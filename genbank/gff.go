@@ -0,0 +1,214 @@
+package genbank
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the GFF3 (sequence ontology) types for GenBank feature keys that don't share a name
+var gff_types = map[string]string{
+	"source":         "region",
+	"mobile_element": "mobile_genetic_element",
+	"misc_feature":   "sequence_feature",
+	"misc_RNA":       "ncRNA",
+	"regulatory":     "regulatory_region",
+	"rep_origin":     "origin_of_replication",
+	"misc_binding":   "binding_site",
+	"protein_bind":   "protein_binding_site",
+	"mat_peptide":    "mature_protein_region",
+	"sig_peptide":    "signal_peptide",
+}
+
+// qualifiers that are renamed to their GFF3 attribute names
+var gff_attributes = map[string]string{
+	"db_xref": "Dbxref",
+	"note":    "Note",
+}
+
+// qualifiers that are too long to be useful in a GFF3
+var skip_qualifiers = map[string]bool{
+	"translation": true,
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the sequences from a set of GenBank records
+*/
+func Sequences(records []*Record) []*fasta.Sequence {
+	var sequences []*fasta.Sequence
+	for _, record := range records {
+		sequences = append(sequences, &fasta.Sequence{Name: record.Name(), Description: record.Definition, Seq: record.Seq})
+	}
+	return sequences
+}
+
+/*
+  function to get the GFF3 type for a GenBank feature key
+*/
+func gffType(key string) string {
+	if gff_type, ok := gff_types[key]; ok {
+		return gff_type
+	}
+	return key
+}
+
+/*
+  function to get the key used to link a feature to its gene (locus_tag, or gene name if there isn't one)
+*/
+func geneKey(feature *Feature) string {
+	if locus_tag, ok := feature.Qualifier("locus_tag"); ok {
+		return "locus_tag:" + locus_tag
+	}
+	if gene, ok := feature.Qualifier("gene"); ok {
+		return "gene:" + gene
+	}
+	return ""
+}
+
+/*
+  function to get the GFF3 phase of each span of a CDS
+*/
+func cdsPhases(feature *Feature) []int {
+	offset := 0
+	if codon_start, ok := feature.Qualifier("codon_start"); ok {
+		fmt.Sscanf(codon_start, "%d", &offset)
+		offset--
+	}
+	phases := make([]int, len(feature.Spans))
+	consumed := 0
+	for i, span := range feature.Spans {
+		phases[i] = ((offset-consumed)%3 + 3) % 3
+		consumed += span.End - span.Start + 1
+	}
+	return phases
+}
+
+/*
+  function to convert the features of a set of GenBank records to a GFF3 annotation
+
+  genes get an ID, and the features that share a locus_tag (or gene name) with a gene are given it as their Parent
+*/
+func Annotation(records []*Record) (*gff.Annotation, error) {
+	annotation := gff.NewAnnotation()
+	for _, record := range records {
+		seqid := record.Name()
+		annotation.Lengths[seqid] = len(record.Seq)
+		annotation.Circular[seqid] = record.Circular
+
+		// give each feature an ID and find the genes
+		ids := make([]string, len(record.Features))
+		genes := make(map[string]string)
+		counts := make(map[string]int)
+		for i, feature := range record.Features {
+			gff_type := gffType(feature.Key)
+			ids[i] = fmt.Sprintf("%s-%s%d", seqid, strings.ToLower(gff_type), counts[gff_type])
+			counts[gff_type]++
+			if feature.Key == "gene" {
+				if key := geneKey(feature); len(key) != 0 {
+					if _, ok := genes[key]; !ok {
+						genes[key] = ids[i]
+					}
+				}
+			}
+		}
+
+		// convert each feature (one GFF3 line per span)
+		for i, feature := range record.Features {
+			attributes := []gff.Attribute{{Tag: "ID", Values: []string{ids[i]}}}
+			if feature.Key != "gene" && feature.Key != "source" {
+				if parent, ok := genes[geneKey(feature)]; ok {
+					attributes = append(attributes, gff.Attribute{Tag: "Parent", Values: []string{parent}})
+				}
+			}
+			if name, ok := feature.Qualifier("gene"); ok {
+				attributes = append(attributes, gff.Attribute{Tag: "Name", Values: []string{name}})
+			} else if name, ok := feature.Qualifier("locus_tag"); ok {
+				attributes = append(attributes, gff.Attribute{Tag: "Name", Values: []string{name}})
+			}
+			if feature.Key == "source" && record.Circular {
+				attributes = append(attributes, gff.Attribute{Tag: "Is_circular", Values: []string{"true"}})
+			}
+			if feature.Partial {
+				attributes = append(attributes, gff.Attribute{Tag: "partial", Values: []string{"true"}})
+			}
+			attributes = append(attributes, gff.Attribute{Tag: "gbkey", Values: []string{feature.Key}})
+
+			// collect the qualifiers, joining repeated keys into a list
+			for _, qualifier := range feature.Qualifiers {
+				if skip_qualifiers[qualifier.Key] {
+					continue
+				}
+				tag := qualifier.Key
+				if attribute, ok := gff_attributes[tag]; ok {
+					tag = attribute
+				}
+				value := qualifier.Value
+				if len(value) == 0 {
+					value = "true"
+				}
+				found := false
+				for j := range attributes {
+					if attributes[j].Tag == tag {
+						attributes[j].Values = append(attributes[j].Values, value)
+						found = true
+						break
+					}
+				}
+				if !found {
+					attributes = append(attributes, gff.Attribute{Tag: tag, Values: []string{value}})
+				}
+			}
+
+			// work out the phase for CDS features
+			phases := make([]int, len(feature.Spans))
+			for j := range phases {
+				phases[j] = -1
+			}
+			if feature.Key == "CDS" {
+				phases = cdsPhases(feature)
+			}
+			for j, span := range feature.Spans {
+				annotation.Features = append(annotation.Features, &gff.Feature{
+					Seqid:      seqid,
+					Source:     "GenBank",
+					Type:       gffType(feature.Key),
+					Start:      span.Start,
+					End:        span.End,
+					Strand:     feature.Strand,
+					Phase:      phases[j],
+					Attributes: attributes,
+				})
+			}
+		}
+	}
+	return annotation, annotation.Link()
+}
+
+/*
+  function to convert a GenBank file to a fasta file and a GFF3 file
+*/
+func Convert(genbank_file string, fasta_file string, gff_file string) ([]*Record, error) {
+	records, err := Read(genbank_file)
+	if err != nil {
+		return nil, err
+	}
+	if err := fasta.WriteFile(fasta_file, Sequences(records)); err != nil {
+		return nil, err
+	}
+	annotation, err := Annotation(records)
+	if err != nil {
+		return nil, err
+	}
+	return records, gff.WriteFile(gff_file, annotation)
+}
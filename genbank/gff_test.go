@@ -0,0 +1,68 @@
+package genbank
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// the expected fasta and GFF3 conversions of the test record
+const (
+	test_fasta = "testdata/test.fa"
+	test_gff   = "testdata/test.gff3"
+)
+
+func TestAnnotation(t *testing.T) {
+	records, err := Read(test_genbank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotation, err := Annotation(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if annotation.Lengths["TEST1.1"] != 60 || !annotation.Circular["TEST1.1"] || len(annotation.Features) != 11 {
+		t.Fatalf("unexpected annotation: %d features", len(annotation.Features))
+	}
+
+	// each span of the CDS crossing the origin is linked to the gene
+	for _, cds := range annotation.ByID("TEST1.1-cds2") {
+		if len(cds.Parents) == 0 || cds.Phase != 0 {
+			t.Errorf("unexpected CDS: %+v", cds)
+		}
+		for _, parent := range cds.Parents {
+			if parent.ID() != "TEST1.1-gene2" {
+				t.Errorf("CDS %v-%v has the parent %v", cds.Start, cds.End, parent.ID())
+			}
+		}
+	}
+	if genes := annotation.OfType("gene"); len(genes) != 5 {
+		t.Errorf("found %d gene lines", len(genes))
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genbank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fasta_file, gff_file := filepath.Join(dir, "test.fa"), filepath.Join(dir, "test.gff3")
+	if _, err := Convert(test_genbank, fasta_file, gff_file); err != nil {
+		t.Fatal(err)
+	}
+	for _, files := range [][2]string{{fasta_file, test_fasta}, {gff_file, test_gff}} {
+		got, err := ioutil.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ioutil.ReadFile(files[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(expected) {
+			t.Errorf("%v doesn't match %v:\n%v", files[0], files[1], string(got))
+		}
+	}
+}
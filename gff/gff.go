@@ -0,0 +1,542 @@
+/*
+
+This package reads and writes GFF3 annotations.
+
+Features are parsed with their attributes, strand and phase, linked to their parents and children using the ID/Parent attributes, and indexed so that they can be queried by position.
+
+*/
+
+package gff
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// maximum line length expected in a GFF3 file
+const max_line = 16 * 1024 * 1024
+
+///////////////
+// STRUCTS
+//////////////
+// Feature is a single line of a GFF3 file
+type Feature struct {
+	Seqid      string
+	Source     string
+	Type       string
+	Start      int
+	End        int
+	Score      string
+	Strand     byte
+	Phase      int
+	Attributes []Attribute
+	Parents    []*Feature
+	Children   []*Feature
+}
+
+// Attribute is a tag and its values from the ninth column of a GFF3 line
+type Attribute struct {
+	Tag    string
+	Values []string
+}
+
+// Annotation holds all the features from a GFF3 file
+type Annotation struct {
+	Features []*Feature
+	Lengths  map[string]int
+	Circular map[string]bool
+	ids      map[string][]*Feature
+	index    map[string]*feature_index
+}
+
+// feature_index allows features on one sequence to be queried by position
+type feature_index struct {
+	features []*Feature
+	max_end  []int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the first value of an attribute (and whether it was found)
+*/
+func (feature *Feature) Attribute(tag string) (string, bool) {
+	for _, attribute := range feature.Attributes {
+		if attribute.Tag == tag && len(attribute.Values) != 0 {
+			return attribute.Values[0], true
+		}
+	}
+	return "", false
+}
+
+/*
+  function to get all the values of an attribute
+*/
+func (feature *Feature) AttributeValues(tag string) []string {
+	for _, attribute := range feature.Attributes {
+		if attribute.Tag == tag {
+			return attribute.Values
+		}
+	}
+	return nil
+}
+
+/*
+  function to set an attribute (replacing any existing values)
+*/
+func (feature *Feature) SetAttribute(tag string, values ...string) {
+	for i, attribute := range feature.Attributes {
+		if attribute.Tag == tag {
+			feature.Attributes[i].Values = values
+			return
+		}
+	}
+	feature.Attributes = append(feature.Attributes, Attribute{tag, values})
+}
+
+/*
+  function to get the ID of a feature
+*/
+func (feature *Feature) ID() string {
+	id, _ := feature.Attribute("ID")
+	return id
+}
+
+/*
+  function to get the length of a feature
+*/
+func (feature *Feature) Length() int {
+	return feature.End - feature.Start + 1
+}
+
+/*
+  function to get a readable name for a feature (locus_tag, Name or gene, then the name of its parent, then its ID)
+*/
+func (feature *Feature) Name() string {
+	for _, tag := range []string{"locus_tag", "Name", "gene"} {
+		if value, ok := feature.Attribute(tag); ok {
+			return value
+		}
+	}
+	for _, parent := range feature.Parents {
+		if name := parent.Name(); len(name) != 0 {
+			return name
+		}
+	}
+	return feature.ID()
+}
+
+/*
+  function to check if a feature overlaps an interval (1-based, inclusive)
+*/
+func (feature *Feature) Overlaps(start int, end int) bool {
+	return feature.Start <= end && feature.End >= start
+}
+
+/*
+  function to find the closest ancestor (or the feature itself) of a given type
+*/
+func (feature *Feature) Ancestor(feature_type string) *Feature {
+	if feature.Type == feature_type {
+		return feature
+	}
+	for _, parent := range feature.Parents {
+		if ancestor := parent.Ancestor(feature_type); ancestor != nil {
+			return ancestor
+		}
+	}
+	return nil
+}
+
+/*
+  function to unescape a GFF3 column
+*/
+func unescape(value string) (string, error) {
+	if !strings.Contains(value, "%") {
+		return value, nil
+	}
+	return url.PathUnescape(value)
+}
+
+/*
+  function to escape a GFF3 column (attributes also escape the characters used to separate them)
+*/
+func escape(value string, attribute bool) string {
+	replacements := []string{"%", "%25", "\t", "%09", "\n", "%0A", "\r", "%0D"}
+	if attribute {
+		replacements = append(replacements, ";", "%3B", "=", "%3D", "&", "%26", ",", "%2C")
+	}
+	return strings.NewReplacer(replacements...).Replace(value)
+}
+
+/*
+  function to parse a GFF3 feature line
+*/
+func parseFeature(line string) (*Feature, error) {
+	columns := strings.Split(line, "\t")
+	if len(columns) != 9 {
+		return nil, fmt.Errorf("expected 9 tab separated columns, found %d", len(columns))
+	}
+	feature := &Feature{Source: columns[1], Type: columns[2], Score: columns[5], Phase: -1}
+	var err error
+	if feature.Seqid, err = unescape(columns[0]); err != nil {
+		return nil, err
+	}
+	if feature.Start, err = strconv.Atoi(columns[3]); err != nil {
+		return nil, fmt.Errorf("bad start position: %v", columns[3])
+	}
+	if feature.End, err = strconv.Atoi(columns[4]); err != nil {
+		return nil, fmt.Errorf("bad end position: %v", columns[4])
+	}
+	if feature.Start < 1 || feature.End < feature.Start {
+		return nil, fmt.Errorf("bad feature coordinates: %d-%d", feature.Start, feature.End)
+	}
+	if len(columns[6]) != 1 || strings.IndexByte("+-.?", columns[6][0]) == -1 {
+		return nil, fmt.Errorf("bad strand: %v", columns[6])
+	}
+	feature.Strand = columns[6][0]
+	if columns[7] != "." {
+		if feature.Phase, err = strconv.Atoi(columns[7]); err != nil || feature.Phase < 0 || feature.Phase > 2 {
+			return nil, fmt.Errorf("bad phase: %v", columns[7])
+		}
+	} else if feature.Type == "CDS" {
+		return nil, fmt.Errorf("CDS features must have a phase")
+	}
+
+	// parse the attributes
+	if columns[8] != "." {
+		for _, pair := range strings.Split(strings.TrimSuffix(columns[8], ";"), ";") {
+			if len(strings.TrimSpace(pair)) == 0 {
+				continue
+			}
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("bad attribute: %v", pair)
+			}
+			tag, err := unescape(strings.TrimSpace(parts[0]))
+			if err != nil {
+				return nil, err
+			}
+			attribute := Attribute{Tag: tag}
+			for _, value := range strings.Split(parts[1], ",") {
+				if value, err = unescape(value); err != nil {
+					return nil, err
+				}
+				attribute.Values = append(attribute.Values, value)
+			}
+			feature.Attributes = append(feature.Attributes, attribute)
+		}
+	}
+	return feature, nil
+}
+
+/*
+  function to read a GFF3 annotation
+*/
+func Parse(reader io.Reader) (*Annotation, error) {
+	annotation := NewAnnotation()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), max_line)
+	line_number := 0
+	for scanner.Scan() {
+		line_number++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "##") {
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "##gff-version":
+				if len(fields) < 2 || !strings.HasPrefix(fields[1], "3") {
+					return nil, fmt.Errorf("line %d: only GFF version 3 is supported", line_number)
+				}
+			case "##sequence-region":
+				if len(fields) == 4 {
+					if length, err := strconv.Atoi(fields[3]); err == nil {
+						annotation.Lengths[fields[1]] = length
+					}
+				}
+			case "##FASTA":
+				return annotation, annotation.Link()
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		feature, err := parseFeature(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line_number, err)
+		}
+		if circular, ok := feature.Attribute("Is_circular"); ok && circular == "true" {
+			annotation.Circular[feature.Seqid] = true
+		}
+		annotation.Features = append(annotation.Features, feature)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return annotation, annotation.Link()
+}
+
+/*
+  function to read a (possibly gzipped) GFF3 file
+*/
+func Read(file_name string) (*Annotation, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var reader io.Reader = fh
+	if strings.HasSuffix(file_name, ".gz") {
+		gz, err := gzip.NewReader(fh)
+		if err != nil {
+			return nil, fmt.Errorf("can't decompress %v: %v", file_name, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	annotation, err := Parse(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file_name, err)
+	}
+	return annotation, nil
+}
+
+/*
+  function to create an empty annotation
+*/
+func NewAnnotation() *Annotation {
+	return &Annotation{Lengths: make(map[string]int), Circular: make(map[string]bool)}
+}
+
+/*
+  function to link features to their parents and build the position index (needed after features are added to an annotation)
+*/
+func (annotation *Annotation) Link() error {
+	annotation.ids = make(map[string][]*Feature)
+	for _, feature := range annotation.Features {
+		if id := feature.ID(); len(id) != 0 {
+			annotation.ids[id] = append(annotation.ids[id], feature)
+		}
+	}
+	for _, feature := range annotation.Features {
+		feature.Parents, feature.Children = nil, nil
+	}
+	for _, feature := range annotation.Features {
+		for _, parent_id := range feature.AttributeValues("Parent") {
+			parents, ok := annotation.ids[parent_id]
+			if !ok {
+				return fmt.Errorf("feature %v has an unknown Parent: %v", feature.Name(), parent_id)
+			}
+
+			// a parent made up of several lines (e.g. a joined gene) is linked using its first line
+			parent := parents[0]
+			feature.Parents = append(feature.Parents, parent)
+			parent.Children = append(parent.Children, feature)
+		}
+	}
+
+	// index the features on each sequence by start position, keeping track of the furthest end seen so far
+	annotation.index = make(map[string]*feature_index)
+	for _, feature := range annotation.Features {
+		index, ok := annotation.index[feature.Seqid]
+		if !ok {
+			index = &feature_index{}
+			annotation.index[feature.Seqid] = index
+		}
+		index.features = append(index.features, feature)
+	}
+	for _, index := range annotation.index {
+		sort.Stable(by_start(index.features))
+		index.max_end = make([]int, len(index.features))
+		max_end := 0
+		for i, feature := range index.features {
+			if feature.End > max_end {
+				max_end = feature.End
+			}
+			index.max_end[i] = max_end
+		}
+	}
+	return nil
+}
+
+// by_start sorts features by their start position
+type by_start []*Feature
+
+func (features by_start) Len() int           { return len(features) }
+func (features by_start) Swap(i, j int)      { features[i], features[j] = features[j], features[i] }
+func (features by_start) Less(i, j int) bool { return features[i].Start < features[j].Start }
+
+/*
+  function to get the features (all lines) with a given ID
+*/
+func (annotation *Annotation) ByID(id string) []*Feature {
+	return annotation.ids[id]
+}
+
+/*
+  function to find the features of a sequence that overlap an interval (1-based, inclusive)
+*/
+func (annotation *Annotation) overlapping(seqid string, start int, end int) []*Feature {
+	index, ok := annotation.index[seqid]
+	if !ok {
+		return nil
+	}
+	var features []*Feature
+	last := sort.Search(len(index.features), func(i int) bool { return index.features[i].Start > end })
+	for i := last - 1; i >= 0 && index.max_end[i] >= start; i-- {
+		if index.features[i].End >= start {
+			features = append(features, index.features[i])
+		}
+	}
+
+	// return the features in start order
+	for i, j := 0, len(features)-1; i < j; i, j = i+1, j-1 {
+		features[i], features[j] = features[j], features[i]
+	}
+	return features
+}
+
+/*
+  function to find the features that overlap an interval (1-based, inclusive)
+
+  on a circular sequence, features that run past the end of the sequence (e.g. 4685745..4685976 on a 4685848 bp chromosome) also match positions at the start of the sequence
+*/
+func (annotation *Annotation) Overlapping(seqid string, start int, end int, feature_types ...string) []*Feature {
+	features := annotation.overlapping(seqid, start, end)
+	if length, ok := annotation.Lengths[seqid]; ok && annotation.Circular[seqid] && start <= length {
+		seen := make(map[*Feature]bool)
+		for _, feature := range features {
+			seen[feature] = true
+		}
+		for _, feature := range annotation.overlapping(seqid, start+length, end+length) {
+			if !seen[feature] {
+				features = append(features, feature)
+			}
+		}
+	}
+	if len(feature_types) == 0 {
+		return features
+	}
+	var selected []*Feature
+	for _, feature := range features {
+		for _, feature_type := range feature_types {
+			if feature.Type == feature_type {
+				selected = append(selected, feature)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+/*
+  function to find the features that cover a position
+*/
+func (annotation *Annotation) At(seqid string, position int, feature_types ...string) []*Feature {
+	return annotation.Overlapping(seqid, position, position, feature_types...)
+}
+
+/*
+  function to get all the features of the given types
+*/
+func (annotation *Annotation) OfType(feature_types ...string) []*Feature {
+	var selected []*Feature
+	for _, feature := range annotation.Features {
+		for _, feature_type := range feature_types {
+			if feature.Type == feature_type {
+				selected = append(selected, feature)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+/*
+  function to write a feature as a GFF3 line
+*/
+func WriteFeature(writer io.Writer, feature *Feature) error {
+	phase := "."
+	if feature.Phase >= 0 {
+		phase = strconv.Itoa(feature.Phase)
+	}
+	score := feature.Score
+	if len(score) == 0 {
+		score = "."
+	}
+	attributes := "."
+	if len(feature.Attributes) != 0 {
+		pairs := make([]string, len(feature.Attributes))
+		for i, attribute := range feature.Attributes {
+			values := make([]string, len(attribute.Values))
+			for j, value := range attribute.Values {
+				values[j] = escape(value, true)
+			}
+			pairs[i] = escape(attribute.Tag, true) + "=" + strings.Join(values, ",")
+		}
+		attributes = strings.Join(pairs, ";")
+	}
+	_, err := fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%d\t%s\t%c\t%s\t%s\n", escape(feature.Seqid, false), escape(feature.Source, false), escape(feature.Type, false), feature.Start, feature.End, score, feature.Strand, phase, attributes)
+	return err
+}
+
+/*
+  function to write an annotation in GFF3 format
+*/
+func Write(writer io.Writer, annotation *Annotation) error {
+	if _, err := fmt.Fprintf(writer, "##gff-version 3\n"); err != nil {
+		return err
+	}
+	var seqids []string
+	for seqid := range annotation.Lengths {
+		seqids = append(seqids, seqid)
+	}
+	sort.Strings(seqids)
+	for _, seqid := range seqids {
+		fmt.Fprintf(writer, "##sequence-region %s 1 %d\n", seqid, annotation.Lengths[seqid])
+	}
+	for _, feature := range annotation.Features {
+		if err := WriteFeature(writer, feature); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+  function to write an annotation to a GFF3 file
+*/
+func WriteFile(file_name string, annotation *Annotation) error {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(fh)
+	if err := Write(writer, annotation); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
@@ -0,0 +1,116 @@
+package gff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// the RefSeq annotation of Salmonella Enteritidis P125109 used by test/test.sh
+const refseq_gff = "../test/data/RefSeq/NC_011294.gff"
+
+/*
+  function to read the RefSeq annotation, stopping the test if it can't be read
+*/
+func readRefSeq(t *testing.T) *Annotation {
+	annotation, err := Read(refseq_gff)
+	if err != nil {
+		t.Fatalf("could not read %v: %v", refseq_gff, err)
+	}
+	return annotation
+}
+
+func TestReadRefSeq(t *testing.T) {
+	annotation := readRefSeq(t)
+	if len(annotation.Features) != 9673 {
+		t.Errorf("read %d features, expected 9673", len(annotation.Features))
+	}
+	counts := map[string]int{"CDS": 4646, "gene": 4766, "tRNA": 83, "rRNA": 22, "repeat_region": 13, "region": 1}
+	for feature_type, expected := range counts {
+		if found := len(annotation.OfType(feature_type)); found != expected {
+			t.Errorf("read %d %v features, expected %d", found, feature_type, expected)
+		}
+	}
+	if length := annotation.Lengths["NC_011294.1"]; length != 4685848 {
+		t.Errorf("sequence-region length is %d, expected 4685848", length)
+	}
+	if !annotation.Circular["NC_011294.1"] {
+		t.Error("NC_011294.1 should be circular (Is_circular=true)")
+	}
+}
+
+func TestRefSeqFeatures(t *testing.T) {
+	annotation := readRefSeq(t)
+
+	// thrA is the first gene, with its CDS linked to it by the Parent attribute
+	cds := annotation.ByID("cds1")
+	if len(cds) != 1 {
+		t.Fatalf("found %d features with ID cds1", len(cds))
+	}
+	if cds[0].Start != 101 || cds[0].End != 2563 || cds[0].Strand != '+' || cds[0].Phase != 0 {
+		t.Errorf("cds1 is %d-%d (%c, phase %d)", cds[0].Start, cds[0].End, cds[0].Strand, cds[0].Phase)
+	}
+	if len(cds[0].Parents) != 1 || cds[0].Parents[0].ID() != "gene1" || cds[0].Parents[0].Name() != "SEN_RS00010" {
+		t.Errorf("cds1 should belong to gene1 (SEN_RS00010), found %v", cds[0].Parents)
+	}
+	if product, _ := cds[0].Attribute("product"); product != "bifunctional aspartate kinase/homoserine dehydrogenase I" {
+		t.Errorf("cds1 product is %q", product)
+	}
+
+	// escaped attribute values are decoded
+	if note, _ := annotation.ByID("cds21")[0].Attribute("Note"); note != "fimbrial protein chaperone; interacts with BcfD" {
+		t.Errorf("cds21 note is %q", note)
+	}
+
+	// the pseudogene gene0 runs past the end of the circular chromosome, so it covers position 10
+	found := false
+	for _, feature := range annotation.At("NC_011294.1", 10, "gene") {
+		if feature.ID() == "gene0" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("gene0 (4685745-4685976) should cover position 10 of the circular chromosome")
+	}
+	if genes := annotation.At("NC_011294.1", 1000, "gene", "CDS"); len(genes) != 2 {
+		t.Errorf("found %d genes and CDS at position 1000, expected 2", len(genes))
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	annotation := readRefSeq(t)
+	var buffer bytes.Buffer
+	if err := Write(&buffer, annotation); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Parse(strings.NewReader(buffer.String()))
+	if err != nil {
+		t.Fatalf("could not parse the written annotation: %v", err)
+	}
+	if len(again.Features) != len(annotation.Features) {
+		t.Fatalf("wrote %d features but read back %d", len(annotation.Features), len(again.Features))
+	}
+	for i, feature := range annotation.Features {
+		if feature.Name() != again.Features[i].Name() || feature.Start != again.Features[i].Start || feature.End != again.Features[i].End {
+			t.Fatalf("feature %d changed from %v to %v", i, feature.Name(), again.Features[i].Name())
+		}
+	}
+	if note, _ := again.ByID("cds21")[0].Attribute("Note"); note != "fimbrial protein chaperone; interacts with BcfD" {
+		t.Errorf("cds21 note is %q after writing", note)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bad := map[string]string{
+		"columns": "##gff-version 3\nchr\tsrc\tgene\t1\t10\n",
+		"start":   "##gff-version 3\nchr\tsrc\tgene\tone\t10\t.\t+\t.\tID=a\n",
+		"strand":  "##gff-version 3\nchr\tsrc\tgene\t1\t10\t.\tx\t.\tID=a\n",
+		"phase":   "##gff-version 3\nchr\tsrc\tCDS\t1\t10\t.\t+\t.\tID=a\n",
+		"version": "##gff-version 2\n",
+	}
+	for name, text := range bad {
+		if _, err := Parse(strings.NewReader(text)); err == nil {
+			t.Errorf("a GFF with a bad %v was accepted", name)
+		}
+	}
+}
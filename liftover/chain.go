@@ -0,0 +1,245 @@
+package liftover
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Block is an ungapped run of aligned bases, followed by the gaps in the reference (deleted in the sample) and the sample (inserted in the sample)
+type Block struct {
+	Size      int
+	Ref_gap   int
+	Query_gap int
+}
+
+// Chain maps the positions of a reference contig to a sample contig (UCSC chain format, + strand only)
+type Chain struct {
+	Score       int
+	Ref_name    string
+	Ref_size    int
+	Ref_start   int
+	Query_name  string
+	Query_size  int
+	Query_start int
+	ID          int
+	Blocks      []Block
+}
+
+// Chains are the chains for each contig of a reference
+type Chains []*Chain
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the end of the chain on the reference and the sample (0-based, exclusive)
+*/
+func (chain *Chain) ends() (int, int) {
+	ref_end, query_end := chain.Ref_start, chain.Query_start
+	for _, block := range chain.Blocks {
+		ref_end += block.Size + block.Ref_gap
+		query_end += block.Size + block.Query_gap
+	}
+	return ref_end, query_end
+}
+
+/*
+  function to map a (1-based) reference position to the sample (false if the position is deleted or outside the chain)
+*/
+func (chain *Chain) Map(position int) (int, bool) {
+	ref, query := chain.Ref_start, chain.Query_start
+	for _, block := range chain.Blocks {
+		if position-1 >= ref && position-1 < ref+block.Size {
+			return query + position - ref, true
+		}
+		ref += block.Size + block.Ref_gap
+		query += block.Size + block.Query_gap
+	}
+	return 0, false
+}
+
+/*
+  function to swap the reference and sample of a chain (to map sample positions back to the reference)
+*/
+func (chain *Chain) Invert() *Chain {
+	inverted := &Chain{Score: chain.Score, Ref_name: chain.Query_name, Ref_size: chain.Query_size, Ref_start: chain.Query_start, Query_name: chain.Ref_name, Query_size: chain.Ref_size, Query_start: chain.Ref_start, ID: chain.ID}
+	for _, block := range chain.Blocks {
+		inverted.Blocks = append(inverted.Blocks, Block{Size: block.Size, Ref_gap: block.Query_gap, Query_gap: block.Ref_gap})
+	}
+	return inverted
+}
+
+/*
+  function to find the chain for a reference contig
+*/
+func (chains Chains) Find(ref_name string) *Chain {
+	for _, chain := range chains {
+		if chain.Ref_name == ref_name {
+			return chain
+		}
+	}
+	return nil
+}
+
+/*
+  function to map a (1-based) position on a reference contig to the sample, giving the sample contig and position
+*/
+func (chains Chains) Map(ref_name string, position int) (string, int, bool) {
+	for _, chain := range chains {
+		if chain.Ref_name != ref_name {
+			continue
+		}
+		if mapped, ok := chain.Map(position); ok {
+			return chain.Query_name, mapped, true
+		}
+	}
+	return "", 0, false
+}
+
+/*
+  function to swap the reference and sample of all the chains
+*/
+func (chains Chains) Invert() Chains {
+	inverted := make(Chains, len(chains))
+	for i, chain := range chains {
+		inverted[i] = chain.Invert()
+	}
+	return inverted
+}
+
+/*
+  function to parse the chains from a chain file
+*/
+func Parse(reader io.Reader) (Chains, error) {
+	var chains Chains
+	var current *Chain
+	scanner := bufio.NewScanner(reader)
+	line_number := 0
+	for scanner.Scan() {
+		line_number++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		// a chain header line
+		if fields[0] == "chain" {
+			if len(fields) < 12 {
+				return nil, fmt.Errorf("line %d: expected at least 12 columns in the chain header, found %d", line_number, len(fields))
+			}
+			if fields[4] != "+" || fields[9] != "+" {
+				return nil, fmt.Errorf("line %d: only + strand chains are supported", line_number)
+			}
+			var numbers [7]int
+			for i, field := range []string{fields[1], fields[3], fields[5], fields[6], fields[8], fields[10], fields[11]} {
+				number, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: bad number in the chain header: %v", line_number, field)
+				}
+				numbers[i] = number
+			}
+			id := 0
+			if len(fields) > 12 {
+				id, _ = strconv.Atoi(fields[12])
+			}
+			current = &Chain{Score: numbers[0], Ref_name: fields[2], Ref_size: numbers[1], Ref_start: numbers[2], Query_name: fields[7], Query_size: numbers[4], Query_start: numbers[5], ID: id}
+			chains = append(chains, current)
+			continue
+		}
+
+		// an alignment block line
+		if current == nil {
+			return nil, fmt.Errorf("line %d: alignment block before a chain header", line_number)
+		}
+		if len(fields) != 1 && len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected 1 or 3 columns in an alignment block, found %d", line_number, len(fields))
+		}
+		var numbers [3]int
+		for i, field := range fields {
+			number, err := strconv.Atoi(field)
+			if err != nil || number < 0 {
+				return nil, fmt.Errorf("line %d: bad number in the alignment block: %v", line_number, field)
+			}
+			numbers[i] = number
+		}
+		current.Blocks = append(current.Blocks, Block{Size: numbers[0], Ref_gap: numbers[1], Query_gap: numbers[2]})
+		if len(fields) == 1 {
+			current = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
+
+/*
+  function to read a chain file
+*/
+func Read(file_name string) (Chains, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	chains, err := Parse(fh)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file_name, err)
+	}
+	return chains, nil
+}
+
+/*
+  function to write a chain in UCSC chain format
+*/
+func WriteChain(writer io.Writer, chain *Chain) error {
+	ref_end, query_end := chain.ends()
+	if _, err := fmt.Fprintf(writer, "chain %d %s %d + %d %d %s %d + %d %d %d\n", chain.Score, chain.Ref_name, chain.Ref_size, chain.Ref_start, ref_end, chain.Query_name, chain.Query_size, chain.Query_start, query_end, chain.ID); err != nil {
+		return err
+	}
+	for i, block := range chain.Blocks {
+		var err error
+		if i == len(chain.Blocks)-1 {
+			_, err = fmt.Fprintf(writer, "%d\n", block.Size)
+		} else {
+			_, err = fmt.Fprintf(writer, "%d\t%d\t%d\n", block.Size, block.Ref_gap, block.Query_gap)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(writer, "\n")
+	return err
+}
+
+/*
+  function to write chains to a file
+*/
+func WriteFile(file_name string, chains Chains) error {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(fh)
+	for _, chain := range chains {
+		if err := WriteChain(writer, chain); err != nil {
+			fh.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
@@ -0,0 +1,111 @@
+package liftover
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"github.com/will-rowe/gopherSeq/bed"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to map the (1-based) start and end of an interval, giving the sample contig and the new start and end
+
+  both ends have to be mapped to the same sample contig - an end past the end of a circular contig (a feature crossing the origin) is wrapped round
+*/
+func (chains Chains) mapInterval(ref_name string, start int, end int) (string, int, int, bool) {
+	chain := chains.Find(ref_name)
+	if chain == nil {
+		return "", 0, 0, false
+	}
+	name, new_start, ok := chains.Map(ref_name, start)
+	if !ok {
+		return "", 0, 0, false
+	}
+	wrap := 0
+	if end > chain.Ref_size && end-chain.Ref_size < start {
+		end -= chain.Ref_size
+		wrap = chain.Query_size
+	}
+	end_name, new_end, ok := chains.Map(ref_name, end)
+	if !ok || end_name != name {
+		return "", 0, 0, false
+	}
+	new_end += wrap
+	if new_end < new_start {
+		return "", 0, 0, false
+	}
+	return name, new_start, new_end, true
+}
+
+/*
+  function to lift the features of an annotation from the reference to the sample
+
+  features where either end is deleted (and their children, so that every Parent is still there) are returned as unmapped
+*/
+func (chains Chains) LiftFeatures(annotation *gff.Annotation) (*gff.Annotation, []*gff.Feature, error) {
+	type lifted_feature struct {
+		name       string
+		start, end int
+		ok         bool
+	}
+	mapped := make(map[*gff.Feature]*lifted_feature)
+	for _, feature := range annotation.Features {
+		name, start, end, ok := chains.mapInterval(feature.Seqid, feature.Start, feature.End)
+		mapped[feature] = &lifted_feature{name, start, end, ok}
+	}
+	var isMapped func(feature *gff.Feature, depth int) bool
+	isMapped = func(feature *gff.Feature, depth int) bool {
+		if !mapped[feature].ok || depth > len(annotation.Features) {
+			return false
+		}
+		for _, parent := range feature.Parents {
+			if !isMapped(parent, depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+	lifted := gff.NewAnnotation()
+	var unmapped []*gff.Feature
+	for _, feature := range annotation.Features {
+		if !isMapped(feature, 0) {
+			unmapped = append(unmapped, feature)
+			continue
+		}
+		copied := *feature
+		copied.Seqid, copied.Start, copied.End = mapped[feature].name, mapped[feature].start, mapped[feature].end
+		copied.Attributes = append([]gff.Attribute(nil), feature.Attributes...)
+		lifted.Features = append(lifted.Features, &copied)
+		if annotation.Circular[feature.Seqid] {
+			lifted.Circular[copied.Seqid] = true
+		}
+	}
+	for seqid := range annotation.Lengths {
+		if chain := chains.Find(seqid); chain != nil {
+			lifted.Lengths[chain.Query_name] = chain.Query_size
+		}
+	}
+	return lifted, unmapped, lifted.Link()
+}
+
+/*
+  function to lift BED regions from the reference to the sample (regions where either end is deleted are returned as unmapped)
+*/
+func (chains Chains) LiftRegions(regions []*bed.Region) ([]*bed.Region, []*bed.Region) {
+	var lifted, unmapped []*bed.Region
+	for _, region := range regions {
+		name, start, end, ok := chains.mapInterval(region.Chrom, region.Start+1, region.End)
+		if !ok {
+			unmapped = append(unmapped, region)
+			continue
+		}
+		copied := *region
+		copied.Chrom, copied.Start, copied.End = name, start-1, end
+		lifted = append(lifted, &copied)
+	}
+	return lifted, unmapped
+}
@@ -0,0 +1,198 @@
+/*
+
+This package maps positions between a reference and a sample consensus (made with gopherSeq consensus --indels), using a chain file.
+
+GFF3 features and BED regions can be lifted from the reference to the sample, or back from the sample to the reference (--reverse). Features or regions with an end that falls in a deletion can't be mapped and are reported.
+
+Single positions can also be lifted over (--position chrom:pos). The sample contigs are named as in the consensus (_ + the reference contig name).
+
+*/
+
+package liftover
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/bed"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up command line arguments
+var args struct {
+	Input    string   `arg:"positional,help:features to lift over (in GFF3 or BED format - can be .gz)"`
+	Chain    string   `arg:"-c,required,help:chain file from the reference to the sample (from gopherSeq consensus --chain)"`
+	Reverse  bool     `arg:"-r,help:lift from the sample back to the reference [default: false]"`
+	Position []string `arg:"-p,help:positions to lift over instead of an input file (chrom:pos - the sample contigs are named _ + the reference contig)"`
+	Output   string   `arg:"-o,help:output file [default: STDOUT]"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tmaps GFF3 features, BED regions and positions between a reference and a sample consensus\n\nusage:\n\tgopherSeq liftover [options] -c CHAIN INPUT\n\tgopherSeq liftover [options] -c CHAIN --position chrom:pos\n\nhelp:\n\tgopherSeq liftover --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to check if a file is BED (by its extension)
+*/
+func isBED(file_name string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(file_name, ".gz"), ".bed")
+}
+
+/*
+  function to lift a GFF3 or BED file, returning the number of features lifted and the labels of those that couldn't be
+*/
+func (chains Chains) LiftFile(input_file string, writer io.Writer) (int, []string, error) {
+	var labels []string
+	if isBED(input_file) {
+		regions, err := bed.Read(input_file)
+		if err != nil {
+			return 0, nil, err
+		}
+		lifted, unmapped := chains.LiftRegions(regions)
+		for _, region := range lifted {
+			if err := bed.WriteRegion(writer, region); err != nil {
+				return 0, nil, err
+			}
+		}
+		for _, region := range unmapped {
+			labels = append(labels, region.Label())
+		}
+		return len(lifted), labels, nil
+	}
+	annotation, err := gff.Read(input_file)
+	if err != nil {
+		return 0, nil, err
+	}
+	lifted, unmapped, err := chains.LiftFeatures(annotation)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := gff.Write(writer, lifted); err != nil {
+		return 0, nil, err
+	}
+	for _, feature := range unmapped {
+		labels = append(labels, fmt.Sprintf("%s %s:%d-%d", feature.Name(), feature.Seqid, feature.Start, feature.End))
+	}
+	return len(lifted.Features), labels, nil
+}
+
+/*
+  function to parse a position query (chrom:pos, with a 1-based position)
+*/
+func parsePosition(query string) (string, int, error) {
+	colon := strings.LastIndex(query, ":")
+	if colon < 1 {
+		return "", 0, fmt.Errorf("expected chrom:pos, found %v", query)
+	}
+	position, err := strconv.Atoi(query[colon+1:])
+	if err != nil || position < 1 {
+		return "", 0, fmt.Errorf("bad position in %v", query)
+	}
+	return query[:colon], position, nil
+}
+
+/*
+  function to lift positions (chrom:pos), writing each query and its position in the sample (. if it can't be mapped) and returning the number lifted and the queries that couldn't be
+*/
+func (chains Chains) LiftPositions(queries []string, writer io.Writer) (int, []string, error) {
+	var labels []string
+	lifted := 0
+	for _, query := range queries {
+		name, position, err := parsePosition(query)
+		if err != nil {
+			return 0, nil, err
+		}
+		mapped := "."
+		if query_name, query_position, ok := chains.Map(name, position); ok {
+			mapped = fmt.Sprintf("%s:%d", query_name, query_position)
+			lifted++
+		} else {
+			labels = append(labels, query)
+		}
+		if _, err := fmt.Fprintf(writer, "%s\t%s\n", query, mapped); err != nil {
+			return 0, nil, err
+		}
+	}
+	return lifted, labels, nil
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	arg.MustParse(&args)
+	if (len(args.Input) == 0) == (len(args.Position) == 0) {
+		fmt.Fprintf(os.Stderr, "give either an input file or --position\n")
+		os.Exit(1)
+	}
+	chains, err := Read(args.Chain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read chain file: %v\n", err)
+		os.Exit(1)
+	}
+	if args.Reverse {
+		chains = chains.Invert()
+	}
+
+	// lift the features or positions (to STDOUT if there is no output file)
+	fh := os.Stdout
+	if len(args.Output) != 0 {
+		if fh, err = os.Create(args.Output); err != nil {
+			fmt.Fprintf(os.Stderr, "could not create output file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	writer := bufio.NewWriter(fh)
+	var lifted int
+	var unmapped []string
+	if len(args.Position) != 0 {
+		lifted, unmapped, err = chains.LiftPositions(args.Position, writer)
+	} else {
+		lifted, unmapped, err = chains.LiftFile(args.Input, writer)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not lift over: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writer.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write output: %v\n", err)
+		os.Exit(1)
+	}
+	if fh != os.Stdout {
+		fh.Close()
+	}
+	for _, label := range unmapped {
+		fmt.Fprintf(os.Stderr, " * couldn't map --> %s\n", label)
+	}
+	if len(args.Position) != 0 {
+		fmt.Fprintf(os.Stderr, " * lifted %d positions (%d couldn't be mapped)\n", lifted, len(unmapped))
+	} else {
+		fmt.Fprintf(os.Stderr, " * lifted %d features (%d couldn't be mapped)\n", lifted, len(unmapped))
+	}
+}
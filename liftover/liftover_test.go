@@ -0,0 +1,132 @@
+package liftover
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/bed"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+// a 100 bp reference contig with a 5 bp deletion after position 20 (21-25 are deleted) and a 3 bp insertion after position 60
+const test_chain = `chain 92 chr 100 + 0 100 _chr 98 + 0 98 1
+20	5	0
+35	0	3
+40
+
+`
+
+/*
+  function to parse the test chain
+*/
+func testChains(t *testing.T) Chains {
+	chains, err := Parse(strings.NewReader(test_chain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 1 {
+		t.Fatalf("read %d chains, expected 1", len(chains))
+	}
+	return chains
+}
+
+func TestChainRoundTrip(t *testing.T) {
+	chains := testChains(t)
+	var buffer bytes.Buffer
+	if err := WriteChain(&buffer, chains[0]); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != test_chain {
+		t.Errorf("the chain changed when it was written back out:\n%v", buffer.String())
+	}
+	bad := []string{
+		"chain 1 chr 100 + 0 100 _chr 100 - 0 100 1\n100\n",
+		"chain 1 chr 100 + 0 100\n",
+		"10\t0\t0\n",
+		"chain 1 chr 100 + 0 100 _chr 100 + 0 100 1\n10\t0\n",
+	}
+	for _, text := range bad {
+		if _, err := Parse(strings.NewReader(text)); err == nil {
+			t.Errorf("a bad chain was accepted:\n%v", text)
+		}
+	}
+}
+
+func TestMapPositions(t *testing.T) {
+	chains := testChains(t)
+	tests := []struct {
+		position, expected int
+		ok                 bool
+	}{{1, 1, true}, {20, 20, true}, {21, 0, false}, {25, 0, false}, {26, 21, true}, {60, 55, true}, {61, 59, true}, {100, 98, true}, {101, 0, false}}
+	for _, test := range tests {
+		name, mapped, ok := chains.Map("chr", test.position)
+		if ok != test.ok || mapped != test.expected || (ok && name != "_chr") {
+			t.Errorf("chr:%d mapped to %v:%d (%v), expected _chr:%d (%v)", test.position, name, mapped, ok, test.expected, test.ok)
+		}
+	}
+
+	// and back again, the inserted bases don't map to the reference
+	inverted := chains.Invert()
+	if name, mapped, ok := inverted.Map("_chr", 59); !ok || name != "chr" || mapped != 61 {
+		t.Errorf("_chr:59 mapped back to %v:%d (%v)", name, mapped, ok)
+	}
+	if _, _, ok := inverted.Map("_chr", 56); ok {
+		t.Error("an inserted base was mapped back to the reference")
+	}
+	if _, _, ok := chains.Map("other", 1); ok {
+		t.Error("a contig without a chain was mapped")
+	}
+}
+
+func TestLiftPositions(t *testing.T) {
+	chains := testChains(t)
+	var buffer bytes.Buffer
+	lifted, unmapped, err := chains.LiftPositions([]string{"chr:10", "chr:22", "chr:70"}, &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lifted != 2 || len(unmapped) != 1 || unmapped[0] != "chr:22" {
+		t.Errorf("lifted %d positions (unmapped %v)", lifted, unmapped)
+	}
+	if buffer.String() != "chr:10\t_chr:10\nchr:22\t.\nchr:70\t_chr:68\n" {
+		t.Errorf("unexpected output:\n%v", buffer.String())
+	}
+	for _, query := range []string{"chr", "chr:", "chr:0", ":10", "chr:ten"} {
+		if _, _, err := chains.LiftPositions([]string{query}, &buffer); err == nil {
+			t.Errorf("the query %q was accepted", query)
+		}
+	}
+}
+
+func TestLiftFeatures(t *testing.T) {
+	chains := testChains(t)
+	annotation, err := gff.Parse(strings.NewReader("##gff-version 3\n##sequence-region chr 1 100\n" +
+		"chr\ttest\tgene\t5\t15\t.\t+\t.\tID=gene1\n" +
+		"chr\ttest\tgene\t18\t23\t.\t+\t.\tID=gene2\n" +
+		"chr\ttest\tCDS\t18\t23\t.\t+\t0\tID=cds2;Parent=gene2\n" +
+		"chr\ttest\tgene\t50\t70\t.\t-\t.\tID=gene3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lifted, unmapped, err := chains.LiftFeatures(annotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lifted.Features) != 2 || len(unmapped) != 2 || unmapped[0].ID() != "gene2" {
+		t.Fatalf("lifted %d features (%d unmapped)", len(lifted.Features), len(unmapped))
+	}
+	gene3 := lifted.ByID("gene3")
+	if len(gene3) != 1 || gene3[0].Seqid != "_chr" || gene3[0].Start != 45 || gene3[0].End != 68 {
+		t.Errorf("gene3 was lifted to %v", gene3)
+	}
+	if lifted.Lengths["_chr"] != 98 {
+		t.Errorf("the lifted sequence-region has length %d", lifted.Lengths["_chr"])
+	}
+
+	// the BED regions are 0-based
+	regions, unmapped_regions := chains.LiftRegions([]*bed.Region{{Chrom: "chr", Start: 49, End: 70}, {Chrom: "chr", Start: 15, End: 22}})
+	if len(regions) != 1 || len(unmapped_regions) != 1 || regions[0].Start != 44 || regions[0].End != 68 {
+		t.Errorf("unexpected lifted regions: %v (unmapped %v)", regions, unmapped_regions)
+	}
+}
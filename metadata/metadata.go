@@ -0,0 +1,143 @@
+/*
+
+This package reads sample metadata tables (TSV or CSV, with a header line).
+
+*/
+
+package metadata
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Table is a metadata table - the first column is the sample ID unless another ID column is chosen
+type Table struct {
+	Columns []string
+	Rows    [][]string
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to parse a metadata table (comma separated if the header has no tabs)
+*/
+func Parse(reader io.Reader) (*Table, error) {
+	buffered := bufio.NewReader(reader)
+	header, err := buffered.Peek(64 * 1024)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	first_line := string(header)
+	if i := strings.IndexByte(first_line, '\n'); i != -1 {
+		first_line = first_line[:i]
+	}
+	records := csv.NewReader(buffered)
+	records.Comment = '#'
+	if strings.Contains(first_line, "\t") {
+		records.Comma = '\t'
+		records.LazyQuotes = true
+	}
+	table := &Table{}
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if table.Columns == nil {
+			table.Columns = record
+			continue
+		}
+		table.Rows = append(table.Rows, record)
+	}
+	if len(table.Columns) == 0 {
+		return nil, fmt.Errorf("no header line found")
+	}
+	return table, nil
+}
+
+/*
+  function to read a metadata table
+*/
+func Read(file_name string) (*Table, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	table, err := Parse(fh)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file_name, err)
+	}
+	return table, nil
+}
+
+/*
+  function to get the index of a column (the first column if the name is empty)
+*/
+func (table *Table) Column(name string) (int, error) {
+	if len(name) == 0 {
+		return 0, nil
+	}
+	for i, column := range table.Columns {
+		if column == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("there is no %v column in the metadata (the columns are: %v)", name, strings.Join(table.Columns, ", "))
+}
+
+/*
+  function to get the names of every column except the ID column (the first column if the name is empty)
+*/
+func (table *Table) Others(id_column string) []string {
+	id, err := table.Column(id_column)
+	if err != nil {
+		id = -1
+	}
+	var others []string
+	for i, column := range table.Columns {
+		if i != id {
+			others = append(others, column)
+		}
+	}
+	return others
+}
+
+/*
+  function to get a column of the table for each sample (by the ID column, or the first column if it is empty)
+*/
+func (table *Table) Lookup(id_column string, value_column string) (map[string]string, error) {
+	id, err := table.Column(id_column)
+	if err != nil {
+		return nil, err
+	}
+	value, err := table.Column(value_column)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, row := range table.Rows {
+		if id < len(row) && value < len(row) {
+			values[row[id]] = row[value]
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// tabs are used if the header has one, otherwise commas (and comment lines are skipped)
+	tables := map[string]string{
+		"tsv": "sample\tcountry\tyear\n# a comment\ns1\tUK\t2017\ns2 \tViet Nam\t2018\n",
+		"csv": "sample,country,year\ns1,UK,2017\ns2,\"Viet Nam\",2018\n",
+	}
+	for name, text := range tables {
+		table, err := Parse(strings.NewReader(text))
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if !reflect.DeepEqual(table.Columns, []string{"sample", "country", "year"}) || len(table.Rows) != 2 {
+			t.Errorf("%v: unexpected table %+v", name, table)
+		}
+		if table.Rows[1][0] != "s2" || table.Rows[1][1] != "Viet Nam" {
+			t.Errorf("%v: unexpected row %q", name, table.Rows[1])
+		}
+	}
+	if _, err := Parse(strings.NewReader("")); err == nil {
+		t.Error("a table without a header was accepted")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	table, err := Parse(strings.NewReader("id,country,year\ns1,UK,2017\ns2,France,2018\ns3,Spain,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	years, err := table.Lookup("", "year")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(years, map[string]string{"s1": "2017", "s2": "2018", "s3": ""}) {
+		t.Errorf("unexpected years: %v", years)
+	}
+	if others := table.Others("country"); !reflect.DeepEqual(others, []string{"id", "year"}) {
+		t.Errorf("unexpected columns: %v", others)
+	}
+	if _, err := table.Lookup("id", "host"); err == nil {
+		t.Error("a column that isn't in the table was found")
+	}
+}
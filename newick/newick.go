@@ -0,0 +1,332 @@
+/*
+
+This package reads and writes phylogenetic trees in Newick format.
+
+*/
+
+package newick
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Node is a node of a tree - the branch length and support are for the branch to its parent
+type Node struct {
+	Name     string
+	Length   float64
+	Support  float64
+	Children []*Node
+	Parent   *Node
+}
+
+// NoSupport is the support of a branch without a support value
+const NoSupport = -1
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to make a new node
+*/
+func NewNode(name string, length float64) *Node {
+	return &Node{Name: name, Length: length, Support: NoSupport}
+}
+
+/*
+  function to add a child to a node
+*/
+func (node *Node) AddChild(child *Node) {
+	child.Parent = node
+	node.Children = append(node.Children, child)
+}
+
+/*
+  function to remove a child from a node
+*/
+func (node *Node) RemoveChild(child *Node) {
+	for i, current := range node.Children {
+		if current == child {
+			node.Children = append(node.Children[:i], node.Children[i+1:]...)
+			child.Parent = nil
+			return
+		}
+	}
+}
+
+/*
+  function to check if a node is a leaf
+*/
+func (node *Node) IsLeaf() bool {
+	return len(node.Children) == 0
+}
+
+/*
+  function to get the leaves below a node (in the order they are in the tree)
+*/
+func (node *Node) Leaves() []*Node {
+	if node.IsLeaf() {
+		return []*Node{node}
+	}
+	var leaves []*Node
+	for _, child := range node.Children {
+		leaves = append(leaves, child.Leaves()...)
+	}
+	return leaves
+}
+
+/*
+  function to get every node below a node (including itself), parents before children
+*/
+func (node *Node) Nodes() []*Node {
+	nodes := []*Node{node}
+	for _, child := range node.Children {
+		nodes = append(nodes, child.Nodes()...)
+	}
+	return nodes
+}
+
+/*
+  function to format a label, quoting it if it has characters that mean something in Newick
+*/
+func quote(label string) string {
+	if strings.ContainsAny(label, " \t\n()[]':;,") {
+		return "'" + strings.Replace(label, "'", "''", -1) + "'"
+	}
+	return label
+}
+
+/*
+  function to format a number to 10 decimal places, without trailing zeros
+*/
+func formatFloat(value float64) string {
+	formatted := strconv.FormatFloat(value, 'f', 10, 64)
+	formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	if formatted == "-0" {
+		return "0"
+	}
+	return formatted
+}
+
+/*
+  function to write a node (and the nodes below it) in Newick format
+*/
+func (node *Node) format(buffer *bytes.Buffer, root bool) {
+	if !node.IsLeaf() {
+		buffer.WriteString("(")
+		for i, child := range node.Children {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			child.format(buffer, false)
+		}
+		buffer.WriteString(")")
+	}
+	switch {
+	case len(node.Name) != 0:
+		buffer.WriteString(quote(node.Name))
+	case node.Support != NoSupport:
+		buffer.WriteString(formatFloat(node.Support))
+	}
+	if !root {
+		buffer.WriteString(":" + formatFloat(node.Length))
+	}
+}
+
+/*
+  function to get a tree in Newick format
+*/
+func (node *Node) String() string {
+	var buffer bytes.Buffer
+	node.format(&buffer, true)
+	buffer.WriteString(";")
+	return buffer.String()
+}
+
+/*
+  function to write a tree in Newick format
+*/
+func Write(writer io.Writer, root *Node) error {
+	_, err := fmt.Fprintf(writer, "%s\n", root.String())
+	return err
+}
+
+/*
+  function to write a tree to a file (or STDOUT if there is no file name)
+*/
+func WriteFile(file_name string, root *Node) error {
+	fh := os.Stdout
+	if len(file_name) != 0 {
+		var err error
+		if fh, err = os.Create(file_name); err != nil {
+			return err
+		}
+		defer fh.Close()
+	}
+	writer := bufio.NewWriter(fh)
+	if err := Write(writer, root); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+/*
+  function to parse a tree in Newick format
+
+  labels of internal nodes that are numbers are read as support values, and comments in square brackets are skipped
+*/
+func Parse(text string) (*Node, error) {
+	parser := &parser{text: text}
+	parser.skip()
+	root, err := parser.node()
+	if err != nil {
+		return nil, err
+	}
+	parser.skip()
+	if parser.pos >= len(parser.text) || parser.text[parser.pos] != ';' {
+		return nil, parser.errorf("expected ; at the end of the tree")
+	}
+	return root, nil
+}
+
+/*
+  function to read a tree from a Newick file
+*/
+func Read(file_name string) (*Node, error) {
+	data, err := ioutil.ReadFile(file_name)
+	if err != nil {
+		return nil, err
+	}
+	root, err := Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file_name, err)
+	}
+	return root, nil
+}
+
+// parser holds the position in the Newick text being parsed
+type parser struct {
+	text string
+	pos  int
+}
+
+/*
+  function to make a parse error that gives the position
+*/
+func (parser *parser) errorf(format string, values ...interface{}) error {
+	return fmt.Errorf("character %d: %s", parser.pos+1, fmt.Sprintf(format, values...))
+}
+
+/*
+  function to skip whitespace and comments
+*/
+func (parser *parser) skip() {
+	for parser.pos < len(parser.text) {
+		switch parser.text[parser.pos] {
+		case ' ', '\t', '\n', '\r':
+			parser.pos++
+		case '[':
+			end := strings.IndexByte(parser.text[parser.pos:], ']')
+			if end < 0 {
+				parser.pos = len(parser.text)
+				return
+			}
+			parser.pos += end + 1
+		default:
+			return
+		}
+	}
+}
+
+/*
+  function to parse a label (quoted or not)
+*/
+func (parser *parser) label() (string, error) {
+	parser.skip()
+	if parser.pos < len(parser.text) && parser.text[parser.pos] == '\'' {
+		var label bytes.Buffer
+		parser.pos++
+		for parser.pos < len(parser.text) {
+			if parser.text[parser.pos] == '\'' {
+				if parser.pos+1 < len(parser.text) && parser.text[parser.pos+1] == '\'' {
+					label.WriteByte('\'')
+					parser.pos += 2
+					continue
+				}
+				parser.pos++
+				return label.String(), nil
+			}
+			label.WriteByte(parser.text[parser.pos])
+			parser.pos++
+		}
+		return "", parser.errorf("unterminated quoted label")
+	}
+	start := parser.pos
+	for parser.pos < len(parser.text) && !strings.ContainsRune("()[]:;, \t\n\r", rune(parser.text[parser.pos])) {
+		parser.pos++
+	}
+	return parser.text[start:parser.pos], nil
+}
+
+/*
+  function to parse a node (and the nodes below it)
+*/
+func (parser *parser) node() (*Node, error) {
+	node := NewNode("", 0)
+	if parser.pos < len(parser.text) && parser.text[parser.pos] == '(' {
+		for {
+			parser.pos++
+			parser.skip()
+			child, err := parser.node()
+			if err != nil {
+				return nil, err
+			}
+			node.AddChild(child)
+			parser.skip()
+			if parser.pos >= len(parser.text) {
+				return nil, parser.errorf("unexpected end of the tree")
+			}
+			if parser.text[parser.pos] == ')' {
+				parser.pos++
+				break
+			}
+			if parser.text[parser.pos] != ',' {
+				return nil, parser.errorf("expected , or ) but found %q", parser.text[parser.pos])
+			}
+		}
+	}
+	label, err := parser.label()
+	if err != nil {
+		return nil, err
+	}
+	if support, err := strconv.ParseFloat(label, 64); err == nil && !node.IsLeaf() {
+		node.Support = support
+	} else {
+		node.Name = label
+	}
+	parser.skip()
+	if parser.pos < len(parser.text) && parser.text[parser.pos] == ':' {
+		parser.pos++
+		parser.skip()
+		start := parser.pos
+		for parser.pos < len(parser.text) && strings.ContainsRune("0123456789.eE+-", rune(parser.text[parser.pos])) {
+			parser.pos++
+		}
+		if node.Length, err = strconv.ParseFloat(parser.text[start:parser.pos], 64); err != nil {
+			return nil, parser.errorf("bad branch length: %v", parser.text[start:parser.pos])
+		}
+	}
+	return node, nil
+}
@@ -0,0 +1,74 @@
+package newick
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tree, err := Parse("((a:1,'b c':2.5)95:0.5,(d:1, e:1e-3)[a comment]:2,'f''s':0.1000);\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Children) != 3 || len(tree.Leaves()) != 5 || len(tree.Nodes()) != 8 {
+		t.Fatalf("the tree has %d children, %d leaves and %d nodes", len(tree.Children), len(tree.Leaves()), len(tree.Nodes()))
+	}
+	clade := tree.Children[0]
+	if clade.Support != 95 || len(clade.Name) != 0 || clade.Length != 0.5 || clade.Children[1].Name != "b c" || clade.Children[1].Parent != clade {
+		t.Errorf("unexpected clade: %+v", clade)
+	}
+	if tree.Children[1].Support != NoSupport || tree.Children[2].Name != "f's" {
+		t.Errorf("unexpected nodes: %+v and %+v", tree.Children[1], tree.Children[2])
+	}
+
+	// the labels are quoted again and the numbers are written without trailing zeros
+	if tree.String() != "((a:1,'b c':2.5)95:0.5,(d:1,e:0.001):2,'f''s':0.1);" {
+		t.Errorf("unexpected tree: %v", tree)
+	}
+
+	// a numeric leaf name is a name, not a support value
+	if leaf, err := Parse("(1:1,2:1)x;"); err != nil || leaf.Children[0].Name != "1" || leaf.Name != "x" {
+		t.Errorf("unexpected names: %v (%v)", leaf, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{"(a,b)", "(a,b", "(a b);", "(a:x,b);", "('a,b);"} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("the tree %q was accepted", text)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "newick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := NewNode("", 0)
+	root.AddChild(NewNode("a", 1))
+	root.AddChild(NewNode("b", 2))
+	file_name := path.Join(dir, "tree.nwk")
+	if err := WriteFile(file_name, root); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := Read(file_name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if err := Write(&buffer, tree); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "(a:1,b:2);\n" {
+		t.Errorf("the tree changed when it was read back in: %v", buffer.String())
+	}
+	root.RemoveChild(root.Children[0])
+	if len(root.Children) != 1 || root.Children[0].Name != "b" {
+		t.Errorf("unexpected children after removing a: %v", root)
+	}
+}
@@ -0,0 +1,217 @@
+package newick
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the splits of a tree - the leaves on one side of each internal branch, and the node below the branch
+
+  each split is a key with a 1 for each leaf (in the order of names) on the side without the first leaf, so the key is the same however the tree is rooted. Branches to a leaf (or with only one leaf on the other side) are not included
+*/
+func Splits(root *Node, names []string) map[string]*Node {
+	index := make(map[string]int)
+	for i, name := range names {
+		index[name] = i
+	}
+	splits := make(map[string]*Node)
+	for _, node := range root.Nodes() {
+		if node == root || node.IsLeaf() {
+			continue
+		}
+		side := make([]byte, len(names))
+		for i := range side {
+			side[i] = '0'
+		}
+		for _, leaf := range node.Leaves() {
+			side[index[leaf.Name]] = '1'
+		}
+		if side[0] == '1' {
+			for i := range side {
+				side[i] = '0' + '1' - side[i]
+			}
+		}
+		key := string(side)
+		if ones := strings.Count(key, "1"); ones > 1 && ones < len(names)-1 {
+			splits[key] = node
+		}
+	}
+	return splits
+}
+
+/*
+  function to get the (sorted) leaf names of a tree, checking there is only one leaf with each name
+*/
+func (node *Node) LeafNames() ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, leaf := range node.Leaves() {
+		if seen[leaf.Name] {
+			return nil, fmt.Errorf("there is more than one leaf called %v", leaf.Name)
+		}
+		seen[leaf.Name] = true
+		names = append(names, leaf.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+/*
+  function to remove a node that has only one child, joining the child to the node's parent (if the node is the root, the child becomes the root)
+*/
+func splice(node *Node) *Node {
+	only := node.Children[0]
+	node.RemoveChild(only)
+	parent := node.Parent
+	if parent == nil {
+		only.Length, only.Support = 0, NoSupport
+		return only
+	}
+	only.Length += node.Length
+	for i, child := range parent.Children {
+		if child == node {
+			parent.Children[i] = only
+			only.Parent = parent
+		}
+	}
+	node.Parent = nil
+	return only
+}
+
+/*
+  function to remove leaves from a tree, giving the new root (internal nodes left with one child are removed)
+*/
+func Prune(root *Node, names []string) (*Node, error) {
+	leaves, err := root.FindLeaves(names)
+	if err != nil {
+		return nil, err
+	}
+	for _, leaf := range leaves {
+		if leaf == root || leaf.Parent == nil {
+			return nil, fmt.Errorf("can't remove every leaf from the tree")
+		}
+		node := leaf
+		for node.Parent != nil && len(node.Parent.Children) == 1 {
+			node = node.Parent
+		}
+		parent := node.Parent
+		if parent == nil {
+			return nil, fmt.Errorf("can't remove every leaf from the tree")
+		}
+		parent.RemoveChild(node)
+		if len(parent.Children) == 1 {
+			if spliced := splice(parent); spliced.Parent == nil {
+				root = spliced
+			}
+		}
+	}
+	return root, nil
+}
+
+/*
+  function to rename the leaves of a tree, giving the number of leaves that were renamed
+*/
+func Rename(root *Node, names map[string]string) int {
+	renamed := 0
+	for _, leaf := range root.Leaves() {
+		if name, ok := names[leaf.Name]; ok {
+			leaf.Name = name
+			renamed++
+		}
+	}
+	return renamed
+}
+
+/*
+  function to sort the children of every node by the number of leaves below them (the smallest clades first, or the largest with descending set)
+*/
+func Ladderize(root *Node, descending bool) {
+	sizes := make(map[*Node]int)
+	var count func(node *Node) int
+	count = func(node *Node) int {
+		size := 1
+		if !node.IsLeaf() {
+			size = 0
+			for _, child := range node.Children {
+				size += count(child)
+			}
+		}
+		sizes[node] = size
+		return size
+	}
+	count(root)
+	for _, node := range root.Nodes() {
+		sort.SliceStable(node.Children, func(a, b int) bool {
+			if descending {
+				return sizes[node.Children[a]] > sizes[node.Children[b]]
+			}
+			return sizes[node.Children[a]] < sizes[node.Children[b]]
+		})
+	}
+}
+
+/*
+  function to collapse the internal branches with a support value below a minimum (their children are joined to the node above), giving the number collapsed
+*/
+func Collapse(root *Node, min_support float64) int {
+	collapsed := 0
+	for _, node := range root.Nodes() {
+		if node == root || node.IsLeaf() || node.Support == NoSupport || node.Support >= min_support {
+			continue
+		}
+		parent := node.Parent
+		var children []*Node
+		for _, child := range parent.Children {
+			if child != node {
+				children = append(children, child)
+				continue
+			}
+			for _, grandchild := range node.Children {
+				grandchild.Parent = parent
+				children = append(children, grandchild)
+			}
+		}
+		parent.Children = children
+		node.Parent, node.Children = nil, nil
+		collapsed++
+	}
+	return collapsed
+}
+
+/*
+  function to get the Robinson-Foulds distance between two (unrooted) trees with the same leaves - the number of splits in one tree but not the other - and the number of splits in the two trees (the largest the distance could be)
+*/
+func RobinsonFoulds(a *Node, b *Node) (int, int, error) {
+	names, err := a.LeafNames()
+	if err != nil {
+		return 0, 0, err
+	}
+	other_names, err := b.LeafNames()
+	if err != nil {
+		return 0, 0, err
+	}
+	if strings.Join(names, "\n") != strings.Join(other_names, "\n") {
+		return 0, 0, fmt.Errorf("the trees don't have the same leaves (%d and %d leaves)", len(names), len(other_names))
+	}
+	splits_a, splits_b := Splits(a, names), Splits(b, names)
+	distance := 0
+	for key := range splits_a {
+		if _, ok := splits_b[key]; !ok {
+			distance++
+		}
+	}
+	for key := range splits_b {
+		if _, ok := splits_a[key]; !ok {
+			distance++
+		}
+	}
+	return distance, len(splits_a) + len(splits_b), nil
+}
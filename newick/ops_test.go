@@ -0,0 +1,87 @@
+package newick
+
+import (
+	"testing"
+)
+
+const test_ops_tree = "((a:1,b:2)90:1,(c:1,(d:1,e:1)40:2)70:1,f:3);"
+
+/*
+  function to parse a tree for a test
+*/
+func mustParse(t *testing.T, text string) *Node {
+	tree, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestPrune(t *testing.T) {
+	// the parent of a is left with one child, so b is joined to the root (adding the branch lengths)
+	tree, err := Prune(mustParse(t, test_ops_tree), []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.String() != "(b:3,(c:1,(d:1,e:1)40:2)70:1,f:3);" {
+		t.Errorf("unexpected tree without a: %v", tree)
+	}
+	if tree, err = Prune(mustParse(t, test_ops_tree), []string{"a", "b", "f"}); err != nil {
+		t.Fatal(err)
+	}
+	if tree.String() != "(c:1,(d:1,e:1)40:2);" {
+		t.Errorf("unexpected tree without a, b and f: %v", tree)
+	}
+	for _, names := range [][]string{{"x"}, {"a", "b", "c", "d", "e", "f"}} {
+		if _, err := Prune(mustParse(t, test_ops_tree), names); err == nil {
+			t.Errorf("%v were pruned", names)
+		}
+	}
+}
+
+func TestRenameAndLadderize(t *testing.T) {
+	tree := mustParse(t, test_ops_tree)
+	if renamed := Rename(tree, map[string]string{"a": "A", "x": "X"}); renamed != 1 {
+		t.Errorf("renamed %d leaves", renamed)
+	}
+	Ladderize(tree, false)
+	if tree.String() != "(f:3,(A:1,b:2)90:1,(c:1,(d:1,e:1)40:2)70:1);" {
+		t.Errorf("unexpected ladderized tree: %v", tree)
+	}
+	Ladderize(tree, true)
+	if tree.String() != "(((d:1,e:1)40:2,c:1)70:1,(A:1,b:2)90:1,f:3);" {
+		t.Errorf("unexpected ladderized tree (descending): %v", tree)
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	tree := mustParse(t, test_ops_tree)
+	if collapsed := Collapse(tree, 50); collapsed != 1 || tree.String() != "((a:1,b:2)90:1,(c:1,d:1,e:1)70:1,f:3);" {
+		t.Errorf("collapsed %d branches: %v", collapsed, tree)
+	}
+	if collapsed := Collapse(tree, 50); collapsed != 0 {
+		t.Errorf("collapsed %d branches again", collapsed)
+	}
+}
+
+func TestRobinsonFoulds(t *testing.T) {
+	tree := mustParse(t, test_ops_tree)
+	distance, total, err := RobinsonFoulds(tree, mustParse(t, "((a,c),(b,(d,e)),f);"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if distance != 4 || total != 6 {
+		t.Errorf("the Robinson-Foulds distance is %d of %d", distance, total)
+	}
+
+	// the splits don't depend on the root
+	leaves, _ := tree.FindLeaves([]string{"d"})
+	if distance, _, err := RobinsonFoulds(mustParse(t, test_ops_tree), Reroot(leaves[0], 0.5)); err != nil || distance != 0 {
+		t.Errorf("the rerooted tree is %d from the tree (%v)", distance, err)
+	}
+	for _, other := range []string{"((a,b),(c,d),(e,g));", "((a,b),(c,d),(e,f),a);"} {
+		if _, _, err := RobinsonFoulds(mustParse(t, test_ops_tree), mustParse(t, other)); err == nil {
+			t.Errorf("the trees were compared with %v", other)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package newick
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"strings"
+)
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to make a node the child of a new parent, reversing the branches on the way up to the old root
+
+  the old root is removed if it's left with one child (i.e. the tree was rooted on a branch)
+*/
+func reverse(node *Node, new_parent *Node, length float64, support float64) {
+	if old_parent := node.Parent; old_parent != nil {
+		old_length, old_support := node.Length, node.Support
+		old_parent.RemoveChild(node)
+		reverse(old_parent, node, old_length, old_support)
+	}
+	node.Length, node.Support = length, support
+	new_parent.AddChild(node)
+	if len(node.Children) == 1 {
+		only := node.Children[0]
+		node.RemoveChild(only)
+		new_parent.RemoveChild(node)
+		only.Length += node.Length
+		if only.Support == NoSupport {
+			only.Support = node.Support
+		}
+		new_parent.AddChild(only)
+	}
+}
+
+/*
+  function to root a tree on the branch above a node, at a distance from the node, giving the new root
+*/
+func Reroot(node *Node, distance float64) *Node {
+	parent := node.Parent
+	if parent == nil {
+		return node
+	}
+	if distance < 0 {
+		distance = 0
+	}
+	if distance > node.Length {
+		distance = node.Length
+	}
+	root := NewNode("", 0)
+	length, support := node.Length, node.Support
+	parent.RemoveChild(node)
+	reverse(parent, root, length-distance, support)
+	node.Length = distance
+	root.AddChild(node)
+	return root
+}
+
+/*
+  function to get the nodes next to a node (its children and parent) and the length of the branch to each
+*/
+func (node *Node) neighbours() ([]*Node, []float64) {
+	var neighbours []*Node
+	var lengths []float64
+	for _, child := range node.Children {
+		neighbours = append(neighbours, child)
+		lengths = append(lengths, child.Length)
+	}
+	if node.Parent != nil {
+		neighbours = append(neighbours, node.Parent)
+		lengths = append(lengths, node.Length)
+	}
+	return neighbours, lengths
+}
+
+/*
+  function to get the distance from a node to every other node, and the node before each one on the path from it
+*/
+func (node *Node) distances() (map[*Node]float64, map[*Node]*Node) {
+	distances := map[*Node]float64{node: 0}
+	previous := make(map[*Node]*Node)
+	stack := []*Node{node}
+	for len(stack) != 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		neighbours, lengths := current.neighbours()
+		for i, neighbour := range neighbours {
+			if _, ok := distances[neighbour]; ok {
+				continue
+			}
+			distances[neighbour] = distances[current] + lengths[i]
+			previous[neighbour] = current
+			stack = append(stack, neighbour)
+		}
+	}
+	return distances, previous
+}
+
+/*
+  function to get the leaf furthest from a node
+*/
+func (node *Node) furthestLeaf(distances map[*Node]float64) *Node {
+	var furthest *Node
+	for current, distance := range distances {
+		if current.IsLeaf() && (furthest == nil || distance > distances[furthest] || (distance == distances[furthest] && current.Name < furthest.Name)) {
+			furthest = current
+		}
+	}
+	return furthest
+}
+
+/*
+  function to root a tree at the midpoint of the longest path between two leaves, giving the new root
+*/
+func MidpointRoot(root *Node) *Node {
+	leaves := root.Leaves()
+	if len(leaves) < 2 {
+		return root
+	}
+	distances, _ := leaves[0].distances()
+	a := leaves[0].furthestLeaf(distances)
+	distances, previous := a.distances()
+	b := a.furthestLeaf(distances)
+	midpoint := distances[b] / 2
+
+	// walk back from b to a until the midpoint is passed
+	for current := b; current != a; current = previous[current] {
+		next := previous[current]
+		if distances[next] > midpoint {
+			continue
+		}
+		if current.Parent == next {
+			return Reroot(current, distances[current]-midpoint)
+		}
+		return Reroot(next, midpoint-distances[next])
+	}
+	return root
+}
+
+/*
+  function to get the most recent common ancestor of a set of nodes
+*/
+func MRCA(nodes []*Node) *Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+	ancestors := make(map[*Node]int)
+	for _, node := range nodes {
+		for current := node; current != nil; current = current.Parent {
+			ancestors[current]++
+		}
+	}
+	for current := nodes[0]; current != nil; current = current.Parent {
+		if ancestors[current] == len(nodes) {
+			return current
+		}
+	}
+	return nil
+}
+
+/*
+  function to find the leaves with the given names
+*/
+func (node *Node) FindLeaves(names []string) ([]*Node, error) {
+	leaves := make(map[string]*Node)
+	for _, leaf := range node.Leaves() {
+		leaves[leaf.Name] = leaf
+	}
+	var found []*Node
+	for _, name := range names {
+		leaf, ok := leaves[name]
+		if !ok {
+			return nil, fmt.Errorf("there is no leaf called %v in the tree", name)
+		}
+		found = append(found, leaf)
+	}
+	return found, nil
+}
+
+/*
+  function to root a tree on the branch to an outgroup (halfway along it), giving the new root
+
+  the outgroup has to be a clade when the tree is unrooted - i.e. there is a branch separating it from the rest of the tree
+*/
+func OutgroupRoot(root *Node, names []string) (*Node, error) {
+	outgroup, err := root.FindLeaves(names)
+	if err != nil {
+		return nil, err
+	}
+	in_outgroup := make(map[*Node]bool)
+	for _, leaf := range outgroup {
+		in_outgroup[leaf] = true
+	}
+
+	// root on a leaf that isn't in the outgroup first, so the outgroup is below the root
+	var ingroup *Node
+	for _, leaf := range root.Leaves() {
+		if !in_outgroup[leaf] {
+			ingroup = leaf
+			break
+		}
+	}
+	if ingroup == nil {
+		return nil, fmt.Errorf("the outgroup can't be every leaf in the tree")
+	}
+	root = Reroot(ingroup, 0)
+	mrca := MRCA(outgroup)
+	if len(mrca.Leaves()) != len(in_outgroup) {
+		return nil, fmt.Errorf("the outgroup (%v) is not a clade in the tree", strings.Join(names, ", "))
+	}
+	return Reroot(mrca, mrca.Length/2), nil
+}
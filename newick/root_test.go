@@ -0,0 +1,64 @@
+package newick
+
+import (
+	"testing"
+)
+
+// an unrooted tree - the longest path is from d to b (10)
+const test_tree = "(a:1,b:2,(c:3,d:7)80:1);"
+
+/*
+  function to parse the test tree
+*/
+func testTree(t *testing.T) *Node {
+	tree, err := Parse(test_tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestReroot(t *testing.T) {
+	tree := testTree(t)
+	leaves, err := tree.FindLeaves([]string{"c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the support of the branch is kept when it's reversed
+	if rooted := Reroot(leaves[0], 1); rooted.String() != "((d:7,(a:1,b:2)80:1):2,c:1);" {
+		t.Errorf("unexpected rerooted tree: %v", rooted)
+	}
+	if _, err := tree.FindLeaves([]string{"x"}); err == nil {
+		t.Error("a leaf that isn't in the tree was found")
+	}
+}
+
+func TestMidpointRoot(t *testing.T) {
+	rooted := MidpointRoot(testTree(t))
+	if rooted.String() != "((c:3,(a:1,b:2)80:1):2,d:5);" {
+		t.Errorf("unexpected midpoint rooted tree: %v", rooted)
+	}
+	if leaf := NewNode("a", 0); MidpointRoot(leaf) != leaf {
+		t.Error("a tree of one leaf was rerooted")
+	}
+}
+
+func TestOutgroupRoot(t *testing.T) {
+	rooted, err := OutgroupRoot(testTree(t), []string{"c", "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rooted.String() != "((b:2,a:1)80:0.5,(c:3,d:7)80:0.5);" {
+		t.Errorf("unexpected outgroup rooted tree: %v", rooted)
+	}
+	leaves, _ := rooted.FindLeaves([]string{"c", "d"})
+	if MRCA(leaves) != rooted.Children[1] {
+		t.Error("the outgroup isn't below the root")
+	}
+	for _, outgroup := range [][]string{{"a", "c"}, {"a", "b", "c", "d"}, {"x"}} {
+		if _, err := OutgroupRoot(testTree(t), outgroup); err == nil {
+			t.Errorf("the tree was rooted on %v", outgroup)
+		}
+	}
+}
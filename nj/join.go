@@ -0,0 +1,138 @@
+package nj
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/will-rowe/gopherSeq/distance"
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to build a neighbour-joining tree from a distance matrix (Saitou and Nei, 1987)
+
+  the tree is unrooted, so the last three nodes are joined to the root - negative branch lengths are set to 0
+*/
+func Join(matrix *distance.Matrix) (*newick.Node, error) {
+	n := len(matrix.Names)
+	if n < 2 {
+		return nil, fmt.Errorf("at least 2 samples are needed for a tree")
+	}
+	nodes := make([]*newick.Node, n)
+	distances := make([][]float64, n)
+	for i, name := range matrix.Names {
+		nodes[i] = newick.NewNode(name, 0)
+		distances[i] = make([]float64, n)
+		for j := range matrix.Names {
+			distances[i][j] = float64(matrix.Distances[i][j])
+		}
+	}
+	join := func(parent *newick.Node, child *newick.Node, length float64) {
+		if length < 0 {
+			length = 0
+		}
+		child.Length = length
+		parent.AddChild(child)
+	}
+
+	// join the pair with the smallest Q until there are three nodes left (the matrix is shrunk by moving the last node into the gap)
+	for n > 3 {
+		sums := make([]float64, n)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				sums[i] += distances[i][j]
+			}
+		}
+		a, b := 0, 1
+		best := 0.0
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				q := float64(n-2)*distances[i][j] - sums[i] - sums[j]
+				if (i == 0 && j == 1) || q < best {
+					a, b, best = i, j, q
+				}
+			}
+		}
+		parent := newick.NewNode("", 0)
+		length_a := distances[a][b]/2 + (sums[a]-sums[b])/float64(2*(n-2))
+		join(parent, nodes[a], length_a)
+		join(parent, nodes[b], distances[a][b]-length_a)
+
+		// the new node replaces a, and the last node replaces b
+		for k := 0; k < n; k++ {
+			if k != a && k != b {
+				distances[a][k] = (distances[a][k] + distances[b][k] - distances[a][b]) / 2
+				distances[k][a] = distances[a][k]
+			}
+		}
+		distances[a][a] = 0
+		nodes[a] = parent
+		last := n - 1
+		if b != last {
+			nodes[b] = nodes[last]
+			for k := 0; k < n; k++ {
+				distances[b][k], distances[k][b] = distances[last][k], distances[k][last]
+			}
+			distances[b][b] = 0
+		}
+		n--
+	}
+
+	// join the last nodes to the root
+	root := newick.NewNode("", 0)
+	if n == 2 {
+		join(root, nodes[0], distances[0][1]/2)
+		join(root, nodes[1], distances[0][1]/2)
+		return root, nil
+	}
+	join(root, nodes[0], (distances[0][1]+distances[0][2]-distances[1][2])/2)
+	join(root, nodes[1], (distances[0][1]+distances[1][2]-distances[0][2])/2)
+	join(root, nodes[2], (distances[0][2]+distances[1][2]-distances[0][1])/2)
+	return root, nil
+}
+
+/*
+  function to give each internal branch of a tree its bootstrap support - the percentage (rounded) of trees built from alignments of resampled sites that have the same split
+*/
+func Bootstrap(tree *newick.Node, sequences []*fasta.Sequence, columns []int, replicates int, seed int64, threads int) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("there are no sites to resample")
+	}
+	var names []string
+	for _, sequence := range sequences {
+		names = append(names, sequence.Name)
+	}
+	tree_splits := newick.Splits(tree, names)
+	counts := make(map[string]int)
+	random := rand.New(rand.NewSource(seed))
+	resampled := make([]int, len(columns))
+	for replicate := 0; replicate < replicates; replicate++ {
+		for i := range resampled {
+			resampled[i] = columns[random.Intn(len(columns))]
+		}
+		matrix, err := distance.CalculateColumns(sequences, resampled, threads)
+		if err != nil {
+			return err
+		}
+		replicate_tree, err := Join(matrix)
+		if err != nil {
+			return err
+		}
+		for key := range newick.Splits(replicate_tree, names) {
+			if _, ok := tree_splits[key]; ok {
+				counts[key]++
+			}
+		}
+	}
+	for key, node := range tree_splits {
+		node.Support = float64((200*counts[key] + replicates) / (2 * replicates))
+	}
+	return nil
+}
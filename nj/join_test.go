@@ -0,0 +1,91 @@
+package nj
+
+import (
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/distance"
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+// the worked example from the neighbour-joining Wikipedia page - the distances are additive, so the tree is recovered exactly
+var test_matrix = &distance.Matrix{
+	Names: []string{"a", "b", "c", "d", "e"},
+	Distances: [][]int{
+		{0, 5, 9, 9, 8},
+		{5, 0, 10, 10, 9},
+		{9, 10, 0, 8, 7},
+		{9, 10, 8, 0, 3},
+		{8, 9, 7, 3, 0},
+	},
+}
+
+func TestJoin(t *testing.T) {
+	tree, err := Join(test_matrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.String() != "(((a:2,b:3):3,c:4):2,e:1,d:2);" {
+		t.Errorf("unexpected tree: %v", tree)
+	}
+
+	// two samples are joined at the midpoint
+	pair := &distance.Matrix{Names: []string{"a", "b"}, Distances: [][]int{{0, 4}, {4, 0}}}
+	if tree, err := Join(pair); err != nil || tree.String() != "(a:2,b:2);" {
+		t.Errorf("unexpected tree of two samples: %v (%v)", tree, err)
+	}
+	if _, err := Join(&distance.Matrix{Names: []string{"a"}, Distances: [][]int{{0}}}); err == nil {
+		t.Error("a tree of one sample was built")
+	}
+}
+
+func TestRootedJoin(t *testing.T) {
+	tree, err := Join(test_matrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rooted := newick.MidpointRoot(tree); rooted.String() != "((c:4,(e:1,d:2):2):1,(a:2,b:3):2);" {
+		t.Errorf("unexpected midpoint rooted tree: %v", rooted)
+	}
+	tree, _ = Join(test_matrix)
+	rooted, err := newick.OutgroupRoot(tree, []string{"d", "e"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rooted.String() != "((c:4,(b:3,a:2):3):1,(e:1,d:2):1);" {
+		t.Errorf("unexpected outgroup rooted tree: %v", rooted)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	// six of the ten sites split a,b from c,d, so every replicate has that split
+	sequences := []*fasta.Sequence{
+		{Name: "a", Seq: []byte("AAAAAAAAAA")},
+		{Name: "b", Seq: []byte("AAAAAAAAAT")},
+		{Name: "c", Seq: []byte("TTTTTTAACC")},
+		{Name: "d", Seq: []byte("TTTTTTCCAA")},
+	}
+	matrix, err := distance.Calculate(sequences, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := Join(matrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Bootstrap(tree, sequences, distance.Columns(sequences, false), 100, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	splits := newick.Splits(tree, matrix.Names)
+	if len(splits) != 1 {
+		t.Fatalf("the tree has %d splits", len(splits))
+	}
+	for _, node := range splits {
+		if node.Support != 100 {
+			t.Errorf("the split has %v%% support, expected 100", node.Support)
+		}
+	}
+	if err := Bootstrap(tree, sequences, nil, 100, 1, 2); err == nil {
+		t.Error("bootstrap replicates were run without any sites")
+	}
+}
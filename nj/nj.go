@@ -0,0 +1,143 @@
+/*
+
+This package builds a neighbour-joining tree from SNP distances, and writes it in Newick format.
+
+The input can be a distance matrix (from gopherSeq distance - a .tsv or .csv file), an alignment (e.g. the SNP alignment from gopherSeq snpalign) or a set of pseudogenomes. For an alignment or pseudogenomes, the branches can be given bootstrap support values by building trees from alignments of resampled sites. Only the variable sites are resampled (pseudogenomes are reduced to their SNP alignment) and the constant sites aren't weighted in, so the support values are inflated compared to resampling the whole genome.
+
+The tree is unrooted, unless it is rooted at the midpoint (--midpoint) or on an outgroup (--outgroup).
+
+*/
+
+package nj
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/distance"
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// the default random seed for the bootstrap replicates
+const default_seed int64 = 42
+
+// set up command line arguments
+var args struct {
+	Input     []string `arg:"positional,required,help:distance matrix (.tsv or .csv) or a single alignment or pseudogenomes (in FASTA format - can be .gz)"`
+	Output    string   `arg:"-o,help:output Newick file [default: STDOUT]"`
+	Bootstrap int      `arg:"-b,help:number of bootstrap replicates (not for a distance matrix) - only variable sites are resampled, so the support is higher than for a whole genome alignment [default: 0]"`
+	Seed      int64    `arg:"help:random seed for the bootstrap replicates [default: 42]"`
+	Core      bool     `arg:"-c,help:only compare sites where every sample has a base (core only deletion) [default: false]"`
+	Midpoint  bool     `arg:"-m,help:root the tree at its midpoint [default: false]"`
+	Outgroup  string   `arg:"help:root the tree on these samples - separated by commas"`
+	Threads   int      `arg:"-t,help:number of processors to use [default: maximum]"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tbuilds a neighbour-joining tree from SNP distances\n\nusage:\n\tgopherSeq nj [options] DISTANCE_MATRIX | ALIGNMENT | PSEUDOGENOME...\n\nhelp:\n\tgopherSeq nj --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to check if an input is a distance matrix (by its extension)
+*/
+func isMatrix(file_name string) bool {
+	return strings.HasSuffix(file_name, ".tsv") || strings.HasSuffix(file_name, ".csv")
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	args.Seed = default_seed
+	arg.MustParse(&args)
+	if args.Threads <= 0 || args.Threads > runtime.NumCPU() {
+		args.Threads = runtime.NumCPU()
+	}
+	if args.Midpoint && len(args.Outgroup) != 0 {
+		fmt.Fprintf(os.Stderr, "--midpoint and --outgroup can't be used together\n")
+		os.Exit(1)
+	}
+	matrix_input := len(args.Input) == 1 && isMatrix(args.Input[0])
+	if matrix_input && args.Bootstrap > 0 {
+		fmt.Fprintf(os.Stderr, "bootstrap replicates need an alignment or pseudogenomes, not a distance matrix\n")
+		os.Exit(1)
+	}
+
+	// get the distances
+	var matrix *distance.Matrix
+	var sequences []*fasta.Sequence
+	var columns []int
+	var err error
+	switch {
+	case matrix_input:
+		matrix, err = distance.ReadMatrix(args.Input[0])
+	case len(args.Input) == 1:
+		sequences, err = distance.ReadAlignment(args.Input[0])
+	default:
+		sequences, err = distance.ReadPseudogenomes(args.Input)
+	}
+	if err == nil && matrix == nil {
+		if matrix, err = distance.Calculate(sequences, args.Core, args.Threads); err == nil {
+			columns = distance.Columns(sequences, args.Core)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not get the distances: %v\n", err)
+		os.Exit(1)
+	}
+
+	// build the tree and get the support values
+	tree, err := Join(matrix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not build tree: %v\n", err)
+		os.Exit(1)
+	}
+	if args.Bootstrap > 0 {
+		if err := Bootstrap(tree, sequences, columns, args.Bootstrap, args.Seed, args.Threads); err != nil {
+			fmt.Fprintf(os.Stderr, "could not run bootstrap replicates: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// root and write the tree
+	switch {
+	case args.Midpoint:
+		tree = newick.MidpointRoot(tree)
+	case len(args.Outgroup) != 0:
+		if tree, err = newick.OutgroupRoot(tree, strings.Split(args.Outgroup, ",")); err != nil {
+			fmt.Fprintf(os.Stderr, "could not root tree: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := newick.WriteFile(args.Output, tree); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write tree: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, " * neighbour-joining tree of %d samples (%d bootstrap replicates)\n", len(matrix.Names), args.Bootstrap)
+}
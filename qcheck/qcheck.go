@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/downsample"
 	"github.com/will-rowe/gopherSeq/envtest"
 )
 
@@ -41,11 +42,14 @@ var trimmed_samples []string
 
 // set up command line arguments
 var args struct {
-	Input      []string `arg:"positional"`
-	Output_dir string   `arg:"-o,help:specify output directory "`
-	Threads    int      `arg:"-t,help:number of processors to use [default: maximum]"`
-	Align      bool     `arg:"-a,help:run align pipeline after the QC check finishes [default: false]"`
-	Reference  string   `arg:"-r,help:specify a reference sequence (required if --align selected)"`
+	Input       []string `arg:"positional"`
+	Output_dir  string   `arg:"-o,help:specify output directory "`
+	Threads     int      `arg:"-t,help:number of processors to use [default: maximum]"`
+	Align       bool     `arg:"-a,help:run align pipeline after the QC check finishes [default: false]"`
+	Reference   string   `arg:"-r,help:specify a reference sequence in fasta or GenBank format (required if --align selected)"`
+	Coverage    float64  `arg:"-c,help:downsample reads to this fold-coverage before QC [default: off]"`
+	Genome_size int      `arg:"-g,help:genome size used for downsampling (required if --coverage selected without --reference)"`
+	Seed        int64    `arg:"help:random seed used when downsampling [default: 42]"`
 }
 
 ///////////////
@@ -69,6 +73,7 @@ func printInfo() {
 func argCheck() {
 	var my_writer io.Writer = os.Stdout
 	args.Output_dir = "./gopherSeq-qcheck-" + string(stamp)
+	args.Seed = downsample.DefaultSeed
 
 	// parse the ARGs
 	arg.MustParse(&args)
@@ -120,6 +125,20 @@ func argCheck() {
 		}
 	}
 
+	// if downsampling selected, make sure we can get a genome size
+	if args.Coverage > 0 && args.Genome_size <= 0 {
+		if len(args.Reference) == 0 {
+			fmt.Fprintf(my_writer, "downsampling needs either --reference or --genome_size\n")
+			os.Exit(1)
+		}
+		genome_size, err := downsample.GenomeSize(args.Reference)
+		if err != nil {
+			fmt.Fprintf(my_writer, "can't get genome size from reference: %v\n", err)
+			os.Exit(1)
+		}
+		args.Genome_size = genome_size
+	}
+
 	// create the output directories
 	if _, err := os.Stat(args.Output_dir); os.IsNotExist(err) {
 		if err := os.Mkdir(args.Output_dir, 0700); err != nil {
@@ -134,6 +153,50 @@ func argCheck() {
 
 }
 
+/*
+  function to downsample the input files to the target coverage (paired files are downsampled together)
+*/
+func downsampleReads() {
+	out_dir := args.Output_dir + "/QC_files/downsampled"
+	if err := os.Mkdir(out_dir, 0700); err != nil {
+		fmt.Printf("can't make downsampled directory in %v\n", args.Output_dir)
+		os.Exit(1)
+	}
+
+	// group the input files by sample, keeping the input order
+	var sample_names []string
+	sample_files := make(map[string][]string)
+	for _, input_file := range args.Input {
+		sample := strings.TrimSuffix(path.Base(input_file), ".gz")
+		sample = strings.TrimSuffix(strings.TrimSuffix(sample, ".fastq"), ".fq")
+		if strings.HasSuffix(sample, "_1") || strings.HasSuffix(sample, "_2") {
+			sample = sample[:len(sample)-2]
+		}
+		if _, ok := sample_files[sample]; !ok {
+			sample_names = append(sample_names, sample)
+		}
+		sample_files[sample] = append(sample_files[sample], input_file)
+	}
+
+	// downsample each sample
+	var downsampled_input []string
+	for _, sample := range sample_names {
+		fmt.Printf(" * downsampling %v\n", sample)
+		reads, coverage, err := downsample.Sample(sample_files[sample], out_dir, args.Genome_size, args.Coverage, args.Seed)
+		if err != nil {
+			fmt.Printf("could not downsample %v: %v\n", sample, err)
+			os.Exit(1)
+		}
+		if coverage <= args.Coverage {
+			fmt.Printf("\t- sample is at %.1fx, no downsampling needed\n", coverage)
+		} else {
+			fmt.Printf("\t- downsampled from %.1fx to %.1fx\n", coverage, args.Coverage)
+		}
+		downsampled_input = append(downsampled_input, reads...)
+	}
+	args.Input = downsampled_input
+}
+
 /*
   function to run QC
 */
@@ -241,6 +304,12 @@ func Main() {
 		os.Exit(1)
 	}
 
+	// downsample reads
+	if args.Coverage > 0 {
+		fmt.Println("downsampling reads . . .")
+		downsampleReads()
+	}
+
 	// perform QC
 	fmt.Println("running QC programs . . .")
 	qcData()
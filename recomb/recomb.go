@@ -0,0 +1,135 @@
+/*
+
+This package screens a whole genome alignment (e.g. core.full.aln from gopherSeq snpalign) for recombination, which inflates the SNP distances between samples.
+
+Each pair of sequences is scanned for regions with more SNPs than expected from the SNP density of the pair. These blocks are put down to the sample that differs most from the other samples in the block, masked in that sample, and written as a GFF3 file.
+
+The masked alignment can be used with gopherSeq distance and nj in place of the original alignment.
+
+*/
+
+package recomb
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up command line arguments
+var args struct {
+	Alignment string  `arg:"positional,required,help:whole genome alignment (in FASTA format - can be .gz)"`
+	Reference string  `arg:"-r,help:reference the alignment was built on (to give the blocks in the GFF3 file positions on its contigs)"`
+	Output    string  `arg:"-o,help:prefix for the output files [default: ./recomb]"`
+	Window    int     `arg:"-w,help:size of the window scanned for SNPs (in bases) [default: 1000]"`
+	Min_snps  int     `arg:"help:fewest SNPs in a recombinant block [default: 3]"`
+	P_value   float64 `arg:"-p,help:P-value for a block (corrected for the number of windows and pairs of sequences) [default: 0.05]"`
+	Mask      string  `arg:"-m,help:character used to mask the blocks [default: N]"`
+	Threads   int     `arg:"-t,help:number of processors to use [default: maximum]"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tmasks recombinant blocks in a whole genome alignment\n\nusage:\n\tgopherSeq recomb [options] ALIGNMENT\n\nhelp:\n\tgopherSeq recomb --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	options := DefaultOptions()
+	args.Output, args.Window, args.Min_snps, args.P_value, args.Mask = "./recomb", options.Window, options.Min_snps, options.P_value, "N"
+	arg.MustParse(&args)
+	if args.Threads <= 0 || args.Threads > runtime.NumCPU() {
+		args.Threads = runtime.NumCPU()
+	}
+	if len(args.Mask) != 1 {
+		fmt.Fprintf(os.Stderr, "--mask must be a single character\n")
+		os.Exit(1)
+	}
+	options.Window, options.Min_snps, options.P_value, options.Threads = args.Window, args.Min_snps, args.P_value, args.Threads
+
+	// read the alignment (and the reference contigs)
+	sequences, err := fasta.Read(args.Alignment)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read alignment: %v\n", err)
+		os.Exit(1)
+	}
+	if len(sequences) == 0 {
+		fmt.Fprintf(os.Stderr, "there are no sequences in %v\n", args.Alignment)
+		os.Exit(1)
+	}
+	length := len(sequences[0].Seq)
+	var contigs []*fasta.Sequence
+	if len(args.Reference) != 0 {
+		if contigs, err = fasta.Read(args.Reference); err != nil {
+			fmt.Fprintf(os.Stderr, "could not read reference: %v\n", err)
+			os.Exit(1)
+		}
+		total := 0
+		for _, contig := range contigs {
+			total += len(contig.Seq)
+		}
+		if total != length {
+			fmt.Fprintf(os.Stderr, "the reference is %d bases but the alignment is %d columns - is it a whole genome alignment built on this reference?\n", total, length)
+			os.Exit(1)
+		}
+	}
+
+	// find and mask the blocks
+	blocks, err := options.Scan(sequences)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not scan alignment: %v\n", err)
+		os.Exit(1)
+	}
+	masked := Mask(sequences, blocks, strings.ToUpper(args.Mask)[0])
+
+	// write the masked alignment and the blocks
+	if err := fasta.WriteFile(args.Output+".masked.aln", sequences); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write alignment: %v\n", err)
+		os.Exit(1)
+	}
+	seqid := strings.TrimSuffix(path.Base(args.Alignment), ".gz")
+	if err := gff.WriteFile(args.Output+".gff", Annotation(blocks, contigs, seqid, length)); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write GFF3: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, " * found %d recombinant blocks in %d samples\n", len(blocks), len(masked))
+	var samples []string
+	for sample := range masked {
+		samples = append(samples, sample)
+	}
+	sort.Strings(samples)
+	for _, sample := range samples {
+		fmt.Fprintf(os.Stderr, " * %s: masked %d bases\n", sample, masked[sample])
+	}
+	fmt.Fprintf(os.Stderr, " * masked alignment --> %s.masked.aln\n * recombinant blocks --> %s.gff\n", args.Output, args.Output)
+}
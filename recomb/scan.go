@@ -0,0 +1,418 @@
+package recomb
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the most times the background SNP density of a pair is re-estimated without the blocks found so far
+const max_iterations = 5
+
+///////////////
+// STRUCTS
+//////////////
+// Options control how recombinant blocks are found
+type Options struct {
+	// the size of the window scanned along each pair of sequences (in bases)
+	Window int
+
+	// the fewest SNPs in a block
+	Min_snps int
+
+	// the P-value for a block (corrected for the number of windows in the alignment and the number of pairs of sequences)
+	P_value float64
+
+	// the number of goroutines used to compare the pairs of sequences
+	Threads int
+}
+
+// Block is a region of the alignment that looks recombinant in some of the samples
+type Block struct {
+	// the first and last columns of the alignment in the block (1-based)
+	Start int
+	End   int
+
+	// the samples with the block, and the number of SNPs in the block for each of them (sites where they differ from the most common base)
+	Samples []string
+	Snps    []int
+
+	// the smallest P-value of the pairwise comparisons that found the block
+	P_value float64
+}
+
+// interval is part of the alignment (0-based columns, inclusive) found in a comparison
+type interval struct {
+	start, end, snps int
+	p_value          float64
+}
+
+// hit is an interval of a pairwise comparison that has been put down to one of the samples
+type hit struct {
+	sample int
+	interval
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the default options
+*/
+func DefaultOptions() *Options {
+	return &Options{Window: 1000, Min_snps: 3, P_value: 0.05, Threads: 1}
+}
+
+/*
+  function to check if a base is called (N, gaps and IUPAC codes are not)
+*/
+func called(base byte) bool {
+	switch base {
+	case 'A', 'C', 'G', 'T':
+		return true
+	}
+	return false
+}
+
+/*
+  function to get the chance of seeing at least a number of SNPs when a number are expected (the upper tail of the Poisson distribution)
+*/
+func poissonTail(count int, expected float64) float64 {
+	if count <= 0 {
+		return 1
+	}
+	if expected <= 0 {
+		return 0
+	}
+	log_factorial, _ := math.Lgamma(float64(count + 1))
+	term := math.Exp(-expected + float64(count)*math.Log(expected) - log_factorial)
+	sum := 0.0
+	for x := count; x < count+10000; x++ {
+		sum += term
+		if float64(x+1) > expected && term < sum*1e-12 {
+			break
+		}
+		term *= expected / float64(x+1)
+	}
+	if sum > 1 {
+		return 1
+	}
+	return sum
+}
+
+/*
+  function to find the windows with more SNPs than expected from the background density, merge them into blocks and keep the blocks that are still significant over their own length
+*/
+func (options *Options) windows(positions []int, rate float64, threshold float64) []interval {
+	var found []interval
+	last := 0
+	for first := range positions {
+		if last < first {
+			last = first
+		}
+		for last+1 < len(positions) && positions[last+1] < positions[first]+options.Window {
+			last++
+		}
+		count := last - first + 1
+		if count < options.Min_snps || poissonTail(count, rate*float64(options.Window)) >= threshold {
+			continue
+		}
+		if n := len(found); n != 0 && positions[first] <= found[n-1].end {
+			if positions[last] > found[n-1].end {
+				found[n-1].end = positions[last]
+			}
+			continue
+		}
+		found = append(found, interval{start: positions[first], end: positions[last]})
+	}
+	var blocks []interval
+	for _, block := range found {
+		block.snps = sort.SearchInts(positions, block.end+1) - sort.SearchInts(positions, block.start)
+		block.p_value = poissonTail(block.snps, rate*float64(block.end-block.start+1))
+		if block.snps >= options.Min_snps && block.p_value < threshold {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+/*
+  function to find the recombinant blocks between a pair of sequences from the columns where they differ - the background density is re-estimated without the blocks until they don't change
+*/
+func (options *Options) scanPair(positions []int, length int, threshold float64) []interval {
+	var blocks []interval
+	for iteration := 0; iteration < max_iterations; iteration++ {
+		snps, sites := len(positions), length
+		for _, block := range blocks {
+			snps -= block.snps
+			sites -= block.end - block.start + 1
+		}
+		if sites <= 0 {
+			break
+		}
+		if snps < 1 {
+			snps = 1
+		}
+		found := options.windows(positions, float64(snps)/float64(sites), threshold)
+		same := len(found) == len(blocks)
+		for i := 0; same && i < len(found); i++ {
+			same = found[i].start == blocks[i].start && found[i].end == blocks[i].end
+		}
+		blocks = found
+		if same {
+			break
+		}
+	}
+	return blocks
+}
+
+/*
+  function to merge the overlapping intervals of a sample
+*/
+func merge(intervals []interval) []interval {
+	sort.Slice(intervals, func(a, b int) bool { return intervals[a].start < intervals[b].start })
+	var merged []interval
+	for _, current := range intervals {
+		if n := len(merged); n != 0 && current.start <= merged[n-1].end {
+			if current.end > merged[n-1].end {
+				merged[n-1].end = current.end
+			}
+			if current.p_value < merged[n-1].p_value {
+				merged[n-1].p_value = current.p_value
+			}
+			continue
+		}
+		merged = append(merged, current)
+	}
+	return merged
+}
+
+/*
+  function to scan an alignment for recombinant blocks
+
+  each pair of sequences is scanned for windows with more SNPs than expected from the SNP density of the pair (a Poisson test, corrected for the number of windows in the alignment and the number of pairs). A block is put down to the sample of the pair with more bases in it that differ from the most common base at the site (or to both, if they have the same number), then the blocks of each sample are merged
+*/
+func (options *Options) Scan(sequences []*fasta.Sequence) ([]*Block, error) {
+	if len(sequences) < 2 {
+		return nil, fmt.Errorf("the alignment needs at least two sequences")
+	}
+	if options.Window < 1 || options.Min_snps < 2 {
+		return nil, fmt.Errorf("the window must be at least 1 base and a block needs at least 2 SNPs")
+	}
+	length := len(sequences[0].Seq)
+	for _, sequence := range sequences {
+		if len(sequence.Seq) != length {
+			return nil, fmt.Errorf("the sequences in the alignment are not all the same length (%v)", sequence.Name)
+		}
+	}
+	windows := length / options.Window
+	if windows < 1 {
+		windows = 1
+	}
+	pairs := len(sequences) * (len(sequences) - 1) / 2
+	threshold := options.P_value / float64(windows) / float64(pairs)
+
+	// find the variable columns, the most common base at each of them and the number of missing sites in each sequence
+	var columns []int
+	var majority []byte
+	missing := make([]int, len(sequences))
+	for column := 0; column < length; column++ {
+		var counts [256]int
+		distinct := 0
+		for i, sequence := range sequences {
+			base := sequence.Seq[column]
+			if !called(base) {
+				missing[i]++
+				continue
+			}
+			if counts[base] == 0 {
+				distinct++
+			}
+			counts[base]++
+		}
+		if distinct < 2 {
+			continue
+		}
+		common := byte('A')
+		for _, base := range []byte("CGT") {
+			if counts[base] > counts[common] {
+				common = base
+			}
+		}
+		columns = append(columns, column)
+		majority = append(majority, common)
+	}
+
+	// scan each pair of sequences (a row at a time)
+	threads := options.Threads
+	if threads < 1 {
+		threads = 1
+	}
+	hits := make([][]hit, len(sequences))
+	rows := make(chan int, len(sequences))
+	var wg sync.WaitGroup
+	wg.Add(threads)
+	for worker := 0; worker < threads; worker++ {
+		go func() {
+			defer wg.Done()
+			var positions, differences []int
+			for i := range rows {
+				a := sequences[i].Seq
+				for j := i + 1; j < len(sequences); j++ {
+					b := sequences[j].Seq
+					positions, differences = positions[:0], differences[:0]
+					for k, column := range columns {
+						if called(a[column]) && called(b[column]) && a[column] != b[column] {
+							positions = append(positions, column)
+							differences = append(differences, k)
+						}
+					}
+					for _, block := range options.scanPair(positions, length-missing[i]-missing[j], threshold) {
+						odd_a, odd_b := 0, 0
+						first := sort.SearchInts(positions, block.start)
+						for _, k := range differences[first : first+block.snps] {
+							if a[columns[k]] != majority[k] {
+								odd_a++
+							}
+							if b[columns[k]] != majority[k] {
+								odd_b++
+							}
+						}
+						if odd_a >= odd_b {
+							hits[i] = append(hits[i], hit{i, block})
+						}
+						if odd_b >= odd_a {
+							hits[i] = append(hits[i], hit{j, block})
+						}
+					}
+				}
+			}
+		}()
+	}
+	for i := range sequences {
+		rows <- i
+	}
+	close(rows)
+	wg.Wait()
+
+	// merge the blocks of each sample, then join the blocks with the same start and end
+	intervals := make([][]interval, len(sequences))
+	for _, row := range hits {
+		for _, found := range row {
+			intervals[found.sample] = append(intervals[found.sample], found.interval)
+		}
+	}
+	joined := make(map[[2]int]*Block)
+	var blocks []*Block
+	for i, sequence := range sequences {
+		for _, current := range merge(intervals[i]) {
+			snps := 0
+			for k := sort.SearchInts(columns, current.start); k < len(columns) && columns[k] <= current.end; k++ {
+				if base := sequence.Seq[columns[k]]; called(base) && base != majority[k] {
+					snps++
+				}
+			}
+			key := [2]int{current.start, current.end}
+			block, ok := joined[key]
+			if !ok {
+				block = &Block{Start: current.start + 1, End: current.end + 1, P_value: current.p_value}
+				joined[key] = block
+				blocks = append(blocks, block)
+			}
+			block.Samples = append(block.Samples, sequence.Name)
+			block.Snps = append(block.Snps, snps)
+			if current.p_value < block.P_value {
+				block.P_value = current.p_value
+			}
+		}
+	}
+	sort.Slice(blocks, func(a, b int) bool {
+		if blocks[a].Start != blocks[b].Start {
+			return blocks[a].Start < blocks[b].Start
+		}
+		return blocks[a].End < blocks[b].End
+	})
+	return blocks, nil
+}
+
+/*
+  function to mask the blocks in the sequences of their samples, giving the number of called bases masked in each sample
+*/
+func Mask(sequences []*fasta.Sequence, blocks []*Block, mask byte) map[string]int {
+	index := make(map[string]*fasta.Sequence)
+	for _, sequence := range sequences {
+		index[sequence.Name] = sequence
+	}
+	masked := make(map[string]int)
+	for _, block := range blocks {
+		for _, sample := range block.Samples {
+			sequence := index[sample]
+			for column := block.Start - 1; column < block.End; column++ {
+				if called(sequence.Seq[column]) {
+					masked[sample]++
+				}
+				sequence.Seq[column] = mask
+			}
+		}
+	}
+	return masked
+}
+
+/*
+  function to convert the blocks to GFF3 features - the columns of the alignment are numbered along the contigs of the reference (in order), or along a single sequence if there are no contigs
+*/
+func Annotation(blocks []*Block, contigs []*fasta.Sequence, seqid string, length int) *gff.Annotation {
+	annotation := gff.NewAnnotation()
+	type region struct {
+		name        string
+		offset, end int
+	}
+	regions := []region{{seqid, 0, length}}
+	if len(contigs) != 0 {
+		regions = regions[:0]
+		offset := 0
+		for _, contig := range contigs {
+			regions = append(regions, region{contig.Name, offset, offset + len(contig.Seq)})
+			offset += len(contig.Seq)
+		}
+	}
+	for _, current := range regions {
+		annotation.Lengths[current.name] = current.end - current.offset
+	}
+	for i, block := range blocks {
+		snps := make([]string, len(block.Snps))
+		for j, count := range block.Snps {
+			snps[j] = strconv.Itoa(count)
+		}
+		for _, current := range regions {
+			start, end := block.Start, block.End
+			if start <= current.offset {
+				start = current.offset + 1
+			}
+			if end > current.end {
+				end = current.end
+			}
+			if start > end {
+				continue
+			}
+			feature := &gff.Feature{Seqid: current.name, Source: "gopherSeq", Type: "recombination", Start: start - current.offset, End: end - current.offset, Strand: '.', Phase: -1}
+			feature.SetAttribute("ID", fmt.Sprintf("recombination_%d", i+1))
+			feature.SetAttribute("taxa", block.Samples...)
+			feature.SetAttribute("snps", snps...)
+			feature.SetAttribute("p_value", strconv.FormatFloat(block.P_value, 'g', 3, 64))
+			annotation.Features = append(annotation.Features, feature)
+		}
+	}
+	return annotation
+}
@@ -0,0 +1,129 @@
+package recomb
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+)
+
+/*
+  function to make a synthetic alignment of 5 samples (10 kb) - each sample has a SNP every 500 bases (outside columns 3400-5100), and with recombination set, s3 has a block of 40 SNPs (every 12 bases from column 4001 to 4469)
+*/
+func testAlignment(recombination bool) []*fasta.Sequence {
+	random := rand.New(rand.NewSource(1))
+	reference := make([]byte, 10000)
+	for i := range reference {
+		reference[i] = "ACGT"[random.Intn(4)]
+	}
+	mutate := func(seq []byte, column int) {
+		seq[column] = "ACGT"[(strings.IndexByte("ACGT", seq[column])+1)%4]
+	}
+	var sequences []*fasta.Sequence
+	for i, name := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		seq := append([]byte(nil), reference...)
+		for k := 0; k < 20; k++ {
+			if column := 500*k + 37*i + 11; column < 3400 || column > 5100 {
+				mutate(seq, column)
+			}
+		}
+		if recombination && name == "s3" {
+			for k := 0; k < 40; k++ {
+				mutate(seq, 4000+12*k)
+			}
+		}
+		sequences = append(sequences, &fasta.Sequence{Name: name, Seq: seq})
+	}
+	return sequences
+}
+
+func TestPoissonTail(t *testing.T) {
+	if poissonTail(0, 2) != 1 || poissonTail(3, 0) != 0 {
+		t.Errorf("unexpected tails: %v %v", poissonTail(0, 2), poissonTail(3, 0))
+	}
+	if tail := poissonTail(1, 1); math.Abs(tail-(1-math.Exp(-1))) > 1e-9 {
+		t.Errorf("P(X >= 1) for a mean of 1 is %v", tail)
+	}
+	if tail := poissonTail(40, 2); tail <= 0 || tail > 1e-30 {
+		t.Errorf("P(X >= 40) for a mean of 2 is %v", tail)
+	}
+}
+
+func TestScan(t *testing.T) {
+	options := &Options{Window: 500, Min_snps: 3, P_value: 0.05, Threads: 2}
+	blocks, err := options.Scan(testAlignment(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 {
+		t.Fatalf("found %d blocks, expected 1", len(blocks))
+	}
+	block := blocks[0]
+	if block.Start != 4001 || block.End != 4469 || !reflect.DeepEqual(block.Samples, []string{"s3"}) || !reflect.DeepEqual(block.Snps, []int{40}) || block.P_value > 1e-10 {
+		t.Errorf("unexpected block: %+v", block)
+	}
+
+	// the background SNPs alone aren't recombination
+	if blocks, err := options.Scan(testAlignment(false)); err != nil || len(blocks) != 0 {
+		t.Errorf("found %d blocks without recombination (%v)", len(blocks), err)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	sequences := testAlignment(false)
+	if _, err := DefaultOptions().Scan(sequences[:1]); err == nil {
+		t.Error("an alignment of one sequence was scanned")
+	}
+	if _, err := (&Options{Window: 500, Min_snps: 1}).Scan(sequences); err == nil {
+		t.Error("blocks of 1 SNP were allowed")
+	}
+	sequences[1].Seq = sequences[1].Seq[:100]
+	if _, err := DefaultOptions().Scan(sequences); err == nil {
+		t.Error("sequences of different lengths were scanned")
+	}
+}
+
+func TestMask(t *testing.T) {
+	sequences := testAlignment(true)
+	sequences[2].Seq[4100] = 'N'
+	blocks := []*Block{{Start: 4001, End: 4469, Samples: []string{"s3"}, Snps: []int{40}}}
+
+	// the N in the block was already missing
+	masked := Mask(sequences, blocks, 'N')
+	if !reflect.DeepEqual(masked, map[string]int{"s3": 468}) {
+		t.Errorf("unexpected masked bases: %v", masked)
+	}
+	if strings.Trim(string(sequences[2].Seq[4000:4469]), "N") != "" || sequences[2].Seq[3999] == 'N' || sequences[2].Seq[4469] == 'N' || sequences[1].Seq[4000] == 'N' {
+		t.Error("the wrong columns were masked")
+	}
+}
+
+func TestAnnotation(t *testing.T) {
+	contigs := []*fasta.Sequence{{Name: "chrA", Seq: make([]byte, 6000)}, {Name: "chrB", Seq: make([]byte, 4000)}}
+	blocks := []*Block{
+		{Start: 4001, End: 4469, Samples: []string{"s3"}, Snps: []int{40}, P_value: 1e-20},
+		{Start: 5991, End: 6010, Samples: []string{"s1", "s2"}, Snps: []int{5, 4}, P_value: 0.001},
+	}
+
+	// a block crossing the end of a contig is split between the contigs
+	annotation := Annotation(blocks, contigs, "", 10000)
+	if len(annotation.Features) != 3 || annotation.Lengths["chrA"] != 6000 || annotation.Lengths["chrB"] != 4000 {
+		t.Fatalf("unexpected annotation: %d features and lengths %v", len(annotation.Features), annotation.Lengths)
+	}
+	first, end, start := annotation.Features[0], annotation.Features[1], annotation.Features[2]
+	if first.Seqid != "chrA" || first.Start != 4001 || first.End != 4469 || first.ID() != "recombination_1" {
+		t.Errorf("unexpected feature: %+v", first)
+	}
+	if end.Seqid != "chrA" || end.Start != 5991 || end.End != 6000 || start.Seqid != "chrB" || start.Start != 1 || start.End != 10 || start.ID() != "recombination_2" {
+		t.Errorf("unexpected features: %+v and %+v", end, start)
+	}
+	if taxa := start.AttributeValues("taxa"); strings.Join(taxa, ",") != "s1,s2" {
+		t.Errorf("unexpected taxa: %v", taxa)
+	}
+	if single := Annotation(blocks[:1], nil, "alignment", 10000); len(single.Features) != 1 || single.Features[0].Seqid != "alignment" {
+		t.Errorf("unexpected annotation of the alignment: %+v", single.Features)
+	}
+}
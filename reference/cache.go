@@ -0,0 +1,286 @@
+package reference
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/genbank"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// CacheEnv is the environment variable that can be used to point at a reference cache
+const CacheEnv = "gopherSeq_cache"
+
+// the name of the prepared reference (and its indices) inside a cache entry
+const cached_fasta = "reference.fa"
+
+// the sequence dictionary of the prepared reference
+const cached_dict = "reference.dict"
+
+// the GFF3 annotation kept in a cache entry (for GenBank references)
+const cached_annotation = "reference.gff3"
+
+// the file written once a cache entry is complete
+const complete_marker = "complete"
+
+// the command used to make the BWA index
+var bwa_command = "bwa"
+
+// how long to wait between checks of a lock and when a lock is considered abandoned (if its owner can't be checked)
+var (
+	lock_poll  = 5 * time.Second
+	lock_stale = 12 * time.Hour
+)
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the reference cache directory (gopherSeq_cache env variable, or ~/.gopherSeq_cache)
+*/
+func CacheDir() (string, error) {
+	if cache_dir := os.Getenv(CacheEnv); len(cache_dir) != 0 {
+		return cache_dir, nil
+	}
+	homeDir, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".gopherSeq_cache"), nil
+}
+
+/*
+  function to get the checksum of a reference file (used as the cache key)
+*/
+func Checksum(reference string) (string, error) {
+	fh, err := os.Open(reference)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fh); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+  function to check if a cache entry has been completed
+*/
+func isComplete(entry string) bool {
+	_, err := os.Stat(filepath.Join(entry, complete_marker))
+	return err == nil
+}
+
+/*
+  function to get the owner written to a lock file (PID and hostname)
+*/
+func lockOwner() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%d %v", os.Getpid(), hostname)
+}
+
+/*
+  function to check if a lock was abandoned by a run on this host that has exited
+
+  locks from other hosts (or without an owner) can't be checked, so they are left for lock_stale
+*/
+func lockAbandoned(lock_file string) bool {
+	data, err := ioutil.ReadFile(lock_file)
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return false
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || pid <= 0 {
+		return false
+	}
+	if hostname, _ := os.Hostname(); fields[1] != hostname {
+		return false
+	}
+	return syscall.Kill(pid, 0) == syscall.ESRCH
+}
+
+/*
+  function to take the lock for a cache entry, waiting for any other run that holds it
+*/
+func takeLock(lock_file string, entry string) (bool, error) {
+	for {
+		fh, err := os.OpenFile(lock_file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(fh, "%v\n", lockOwner())
+			fh.Close()
+			return true, nil
+		}
+		if !os.IsExist(err) {
+			return false, err
+		}
+
+		// another run is building this entry - stop waiting if it finished, or clear the lock if it has been abandoned
+		if isComplete(entry) {
+			return false, nil
+		}
+		if lockAbandoned(lock_file) {
+			os.Remove(lock_file)
+			continue
+		}
+		if info, err := os.Stat(lock_file); err == nil && time.Since(info.ModTime()) > lock_stale {
+			os.Remove(lock_file)
+			continue
+		}
+		time.Sleep(lock_poll)
+	}
+}
+
+/*
+  function to get the GFF3 annotation that was converted from a GenBank reference (empty if the reference was a fasta file)
+*/
+func Annotation(fasta_file string) string {
+	annotation := filepath.Join(filepath.Dir(fasta_file), cached_annotation)
+	if _, err := os.Stat(annotation); err != nil {
+		return ""
+	}
+	return annotation
+}
+
+/*
+  function to build the indices for a reference in a directory (the reference is validated while the faidx and fasta dict are made)
+
+  GenBank references are converted to a fasta sequence and a GFF3 annotation first
+  the dict records the reference at entry (where the directory is moved to once built), so the UR field points at the cached fasta
+*/
+func buildIndex(reference string, dir string, entry string) error {
+	fasta_file := filepath.Join(dir, cached_fasta)
+	if genbank.IsGenBank(reference) {
+		records, err := genbank.Convert(reference, fasta_file, filepath.Join(dir, cached_annotation))
+		if err != nil {
+			return fmt.Errorf("couldn't convert the GenBank reference: %v", err)
+		}
+		for _, record := range records {
+			for _, skipped := range record.Skipped {
+				fmt.Fprintf(os.Stderr, "warning: %v: %v\n", record.Name(), skipped)
+			}
+		}
+	} else if err := copyFile(reference, fasta_file); err != nil {
+		return fmt.Errorf("couldn't copy the reference: %v", err)
+	}
+	contigs, err := fasta.Index(fasta_file)
+	if err == nil {
+		err = fasta.WriteIndex(fasta_file+".fai", contigs)
+	}
+	if err == nil {
+		err = fasta.WriteDict(filepath.Join(dir, cached_dict), filepath.Join(entry, cached_fasta), contigs)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't create the faidx index and sequence dictionary: %v", err)
+	}
+	if err := exec.Command(bwa_command, "index", fasta_file).Run(); err != nil {
+		return fmt.Errorf("couldn't create the BWA index! Check the reference sequence")
+	}
+	return nil
+}
+
+/*
+  function to copy a file
+*/
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+/*
+  function to prepare a reference in the cache (copy, validate, faidx, sequence dictionary and BWA index)
+
+  the cache is keyed by the checksum of the reference so an entry is only built once, and a lock file stops concurrent runs building the same entry
+  returns the path to the cached reference fasta and whether it was built by this call
+*/
+func Prepare(reference string, cache_dir string) (string, bool, error) {
+	checksum, err := Checksum(reference)
+	if err != nil {
+		return "", false, err
+	}
+	entry := filepath.Join(cache_dir, checksum)
+	fasta_file := filepath.Join(entry, cached_fasta)
+	if isComplete(entry) {
+		return fasta_file, false, nil
+	}
+	if err := os.MkdirAll(cache_dir, 0755); err != nil {
+		return "", false, fmt.Errorf("can't make reference cache: %v", err)
+	}
+
+	// wait for the lock (if another run completed the entry while we waited, just use it)
+	lock_file := entry + ".lock"
+	locked, err := takeLock(lock_file, entry)
+	if err != nil {
+		return "", false, fmt.Errorf("can't lock reference cache: %v", err)
+	}
+	if !locked {
+		return fasta_file, false, nil
+	}
+	defer os.Remove(lock_file)
+	if isComplete(entry) {
+		return fasta_file, false, nil
+	}
+
+	// build in a temporary directory and move it into place once finished
+	build_dir := entry + ".tmp-" + strconv.Itoa(os.Getpid())
+	os.RemoveAll(build_dir)
+	if err := os.Mkdir(build_dir, 0755); err != nil {
+		return "", false, err
+	}
+	if err := buildIndex(reference, build_dir, entry); err != nil {
+		os.RemoveAll(build_dir)
+		return "", false, err
+	}
+	source := filepath.Join(build_dir, "source.txt")
+	if abs, err := filepath.Abs(reference); err == nil {
+		reference = abs
+	}
+	if err := ioutil.WriteFile(source, []byte(reference+"\n"), 0644); err != nil {
+		os.RemoveAll(build_dir)
+		return "", false, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(build_dir, complete_marker), []byte(time.Now().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		os.RemoveAll(build_dir)
+		return "", false, err
+	}
+	os.RemoveAll(entry)
+	if err := os.Rename(build_dir, entry); err != nil {
+		os.RemoveAll(build_dir)
+		return "", false, err
+	}
+	return fasta_file, true, nil
+}
@@ -0,0 +1,140 @@
+package reference
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+  function to make a reference and an empty cache in a temporary directory (the BWA index is skipped)
+*/
+func testCache(t *testing.T) (string, string, string) {
+	dir, err := ioutil.TempDir("", "reference")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference := filepath.Join(dir, "ref.fa")
+	if err := ioutil.WriteFile(reference, []byte(">chrA\nACGTACGT\nACGT\n>chrB\nGGCC\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	bwa_command, lock_poll = "true", 10*time.Millisecond
+	return dir, reference, filepath.Join(dir, "cache")
+}
+
+/*
+  function to get the PID of a process that has exited
+*/
+func exitedPid(t *testing.T) int {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("can't run true")
+	}
+	return cmd.Process.Pid
+}
+
+func TestPrepare(t *testing.T) {
+	dir, reference, cache_dir := testCache(t)
+	defer os.RemoveAll(dir)
+
+	// the first run builds the entry and the second uses it
+	fasta_file, built, err := Prepare(reference, cache_dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !built || filepath.Base(fasta_file) != cached_fasta {
+		t.Errorf("unexpected entry: %v (built %v)", fasta_file, built)
+	}
+	for _, file_name := range []string{fasta_file + ".fai", filepath.Join(filepath.Dir(fasta_file), "source.txt")} {
+		if _, err := os.Stat(file_name); err != nil {
+			t.Error(err)
+		}
+	}
+	dict, err := ioutil.ReadFile(filepath.Join(filepath.Dir(fasta_file), cached_dict))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dict), "UR:file://"+fasta_file+"\n") {
+		t.Errorf("the dict doesn't point at the cached reference:\n%v", string(dict))
+	}
+	again, built, err := Prepare(reference, cache_dir)
+	if err != nil || built || again != fasta_file {
+		t.Errorf("the entry was built again: %v (built %v, %v)", again, built, err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(cache_dir, "*.lock")); len(matches) != 0 {
+		t.Errorf("the lock was left behind: %v", matches)
+	}
+
+	// a reference that can't be indexed leaves no entry
+	bad := filepath.Join(dir, "bad.fa")
+	ioutil.WriteFile(bad, []byte(">chrA\nAC!T\n"), 0600)
+	if _, _, err := Prepare(bad, cache_dir); err == nil {
+		t.Error("a bad reference was prepared")
+	}
+	if checksum, _ := Checksum(bad); isComplete(filepath.Join(cache_dir, checksum)) {
+		t.Error("a bad reference was cached")
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	dir, reference, cache_dir := testCache(t)
+	defer os.RemoveAll(dir)
+	hostname, _ := os.Hostname()
+	lock_file := filepath.Join(dir, "entry.lock")
+
+	// only a lock from a run on this host that has exited is abandoned
+	tests := map[string]bool{
+		lockOwner(): false,
+		fmt.Sprintf("%d %v", exitedPid(t), hostname):       true,
+		fmt.Sprintf("%d %v-other", exitedPid(t), hostname): false,
+		fmt.Sprintf("%d", exitedPid(t)):                    false,
+		"":                                                 false,
+	}
+	for owner, abandoned := range tests {
+		ioutil.WriteFile(lock_file, []byte(owner+"\n"), 0600)
+		if lockAbandoned(lock_file) != abandoned {
+			t.Errorf("a lock owned by %q was abandoned: %v", owner, !abandoned)
+		}
+	}
+
+	// a run can prepare the reference after another was killed while holding the lock
+	checksum, err := Checksum(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(cache_dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	lock_file = filepath.Join(cache_dir, checksum) + ".lock"
+	ioutil.WriteFile(lock_file, []byte(fmt.Sprintf("%d %v\n", exitedPid(t), hostname)), 0600)
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := Prepare(reference, cache_dir)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the stale lock wasn't cleared")
+	}
+
+	// an old lock that can't be checked is cleared after lock_stale
+	os.RemoveAll(cache_dir)
+	os.MkdirAll(cache_dir, 0755)
+	ioutil.WriteFile(lock_file, []byte("unknown\n"), 0600)
+	old := time.Now().Add(-2 * lock_stale)
+	if err := os.Chtimes(lock_file, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, built, err := Prepare(reference, cache_dir); err != nil || !built {
+		t.Errorf("the old lock wasn't cleared (built %v, %v)", built, err)
+	}
+}
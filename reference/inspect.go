@@ -0,0 +1,172 @@
+package reference
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// DefaultPlasmidSize is the contig length below which a contig is flagged as a likely plasmid
+const DefaultPlasmidSize = 500000
+
+///////////////
+// STRUCTS
+//////////////
+// ContigStats holds the summary information for one contig of a reference
+type ContigStats struct {
+	Name        string
+	Length      int
+	GC          float64
+	N_count     int
+	Soft_masked float64
+	Plasmid     bool
+	MD5         string
+}
+
+// ContigComparison describes how a contig from one reference matches up to another
+type ContigComparison struct {
+	A      *ContigStats
+	B      *ContigStats
+	Status string
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the summary information for each contig in a reference
+
+  a contig is flagged as a likely plasmid if it is shorter than plasmid_size and is not the largest contig in the reference
+*/
+func Inspect(sequences []*fasta.Sequence, plasmid_size int) []*ContigStats {
+	var stats []*ContigStats
+	longest := 0
+	for _, sequence := range sequences {
+		if len(sequence.Seq) > longest {
+			longest = len(sequence.Seq)
+		}
+	}
+	for _, sequence := range sequences {
+		gc, acgt, lower := 0, 0, 0
+		contig := &ContigStats{Name: sequence.Name, Length: len(sequence.Seq)}
+		for _, base := range sequence.Seq {
+			if base >= 'a' && base <= 'z' {
+				lower++
+			}
+			switch base {
+			case 'G', 'C', 'g', 'c':
+				gc++
+				acgt++
+			case 'A', 'T', 'a', 't':
+				acgt++
+			case 'N', 'n':
+				contig.N_count++
+			}
+		}
+		if acgt > 0 {
+			contig.GC = 100 * float64(gc) / float64(acgt)
+		}
+		if contig.Length > 0 {
+			contig.Soft_masked = 100 * float64(lower) / float64(contig.Length)
+		}
+		contig.Plasmid = contig.Length < plasmid_size && contig.Length < longest
+		checksum := md5.Sum(bytes.ToUpper(sequence.Seq))
+		contig.MD5 = hex.EncodeToString(checksum[:])
+		stats = append(stats, contig)
+	}
+	return stats
+}
+
+/*
+  function to compare the contigs of two references
+
+  contigs are matched by name first, then any remaining contigs are matched by sequence (to catch renamed contigs)
+*/
+func Compare(a []*ContigStats, b []*ContigStats) []*ContigComparison {
+	var comparisons []*ContigComparison
+	b_names := make(map[string]*ContigStats)
+	b_checksums := make(map[string]*ContigStats)
+	for _, contig := range b {
+		b_names[contig.Name] = contig
+		if _, ok := b_checksums[contig.MD5]; !ok {
+			b_checksums[contig.MD5] = contig
+		}
+	}
+	matched := make(map[*ContigStats]bool)
+	for _, contig := range a {
+		comparison := &ContigComparison{A: contig}
+		if other, ok := b_names[contig.Name]; ok && !matched[other] {
+			comparison.B = other
+			if other.MD5 == contig.MD5 {
+				comparison.Status = "identical"
+			} else {
+				comparison.Status = "different sequence"
+			}
+		} else if other, ok := b_checksums[contig.MD5]; ok && !matched[other] {
+			comparison.B = other
+			comparison.Status = "renamed"
+		} else {
+			comparison.Status = "only in first reference"
+		}
+		if comparison.B != nil {
+			matched[comparison.B] = true
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	for _, contig := range b {
+		if !matched[contig] {
+			comparisons = append(comparisons, &ContigComparison{B: contig, Status: "only in second reference"})
+		}
+	}
+	return comparisons
+}
+
+/*
+  function to print the summary information for a reference
+*/
+func PrintStats(writer io.Writer, stats []*ContigStats) {
+	fmt.Fprintf(writer, "contig\tlength\tgc(%%)\tN_count\tsoft_masked(%%)\ttype\n")
+	total_length, total_n := 0, 0
+	for _, contig := range stats {
+		contig_type := "chromosome"
+		if contig.Plasmid {
+			contig_type = "plasmid?"
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%.2f\t%d\t%.2f\t%s\n", contig.Name, contig.Length, contig.GC, contig.N_count, contig.Soft_masked, contig_type)
+		total_length += contig.Length
+		total_n += contig.N_count
+	}
+	fmt.Fprintf(writer, "total\t%d\t\t%d\t\t%d contigs\n", total_length, total_n, len(stats))
+}
+
+/*
+  function to print the comparison of two references
+*/
+func PrintComparison(writer io.Writer, comparisons []*ContigComparison) {
+	fmt.Fprintf(writer, "contig_1\tcontig_2\tlength_1\tlength_2\tlength_difference\tgc_1(%%)\tgc_2(%%)\tstatus\n")
+	for _, comparison := range comparisons {
+		a_name, a_length, a_gc := "-", "-", "-"
+		b_name, b_length, b_gc := "-", "-", "-"
+		difference := "-"
+		if comparison.A != nil {
+			a_name, a_length, a_gc = comparison.A.Name, fmt.Sprint(comparison.A.Length), fmt.Sprintf("%.2f", comparison.A.GC)
+		}
+		if comparison.B != nil {
+			b_name, b_length, b_gc = comparison.B.Name, fmt.Sprint(comparison.B.Length), fmt.Sprintf("%.2f", comparison.B.GC)
+		}
+		if comparison.A != nil && comparison.B != nil {
+			difference = fmt.Sprint(comparison.B.Length - comparison.A.Length)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a_name, b_name, a_length, b_length, difference, a_gc, b_gc, comparison.Status)
+	}
+}
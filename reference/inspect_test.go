@@ -0,0 +1,89 @@
+package reference
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+)
+
+func TestInspect(t *testing.T) {
+	sequences := []*fasta.Sequence{
+		{Name: "chr", Seq: []byte("GGCCAATTNNggccaattnn")},
+		{Name: "small", Seq: []byte("ACGTACGTAC")},
+		{Name: "large", Seq: []byte("GGGGGGGGGGGGGGGGGGGGGGGG")},
+		{Name: "empty", Seq: []byte("")},
+	}
+
+	// the GC excludes Ns, and only a short contig that isn't the largest is a plasmid
+	tests := []struct {
+		gc          float64
+		n_count     int
+		soft_masked float64
+		plasmid     bool
+	}{
+		{50, 4, 50, false},
+		{50, 0, 0, true},
+		{100, 0, 0, false},
+		{0, 0, 0, true},
+	}
+	stats := Inspect(sequences, 15)
+	if len(stats) != len(tests) {
+		t.Fatalf("found %d contigs, expected %d", len(stats), len(tests))
+	}
+	for i, test := range tests {
+		contig := stats[i]
+		if contig.Name != sequences[i].Name || contig.Length != len(sequences[i].Seq) || contig.GC != test.gc || contig.N_count != test.n_count || contig.Soft_masked != test.soft_masked || contig.Plasmid != test.plasmid {
+			t.Errorf("unexpected stats for %v: %+v", sequences[i].Name, contig)
+		}
+	}
+
+	// a soft-masked copy of a contig has the same checksum
+	masked := Inspect([]*fasta.Sequence{{Name: "chr", Seq: []byte(strings.ToLower(string(sequences[0].Seq)))}}, 21)
+	if masked[0].MD5 != stats[0].MD5 || masked[0].Soft_masked != 100 || masked[0].Plasmid {
+		t.Errorf("unexpected stats for the masked contig: %+v", masked[0])
+	}
+	if single := Inspect(sequences[1:2], DefaultPlasmidSize); single[0].Plasmid {
+		t.Error("the only contig was flagged as a plasmid")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a := Inspect([]*fasta.Sequence{
+		{Name: "chr", Seq: []byte("ACGTACGTAC")},
+		{Name: "p1", Seq: []byte("GGCC")},
+		{Name: "p2", Seq: []byte("AATT")},
+		{Name: "p3", Seq: []byte("CCCC")},
+	}, DefaultPlasmidSize)
+	b := Inspect([]*fasta.Sequence{
+		{Name: "chr", Seq: []byte("acgtacgtac")},
+		{Name: "p1", Seq: []byte("GGCCA")},
+		{Name: "plasmid2", Seq: []byte("AATT")},
+		{Name: "p4", Seq: []byte("TTTT")},
+	}, DefaultPlasmidSize)
+	expected := []string{"chr chr identical", "p1 p1 different sequence", "p2 plasmid2 renamed", "p3 - only in first reference", "- p4 only in second reference"}
+	comparisons := Compare(a, b)
+	if len(comparisons) != len(expected) {
+		t.Fatalf("found %d comparisons, expected %d", len(comparisons), len(expected))
+	}
+	for i, comparison := range comparisons {
+		a_name, b_name := "-", "-"
+		if comparison.A != nil {
+			a_name = comparison.A.Name
+		}
+		if comparison.B != nil {
+			b_name = comparison.B.Name
+		}
+		if got := a_name + " " + b_name + " " + comparison.Status; got != expected[i] {
+			t.Errorf("unexpected comparison: %v (expected %v)", got, expected[i])
+		}
+	}
+
+	// the length difference is from the first reference to the second
+	var buffer bytes.Buffer
+	PrintComparison(&buffer, comparisons)
+	if lines := strings.Split(buffer.String(), "\n"); len(lines) != 7 || lines[2] != "p1\tp1\t4\t5\t1\t100.00\t80.00\tdifferent sequence" {
+		t.Errorf("unexpected comparison table:\n%v", buffer.String())
+	}
+}
@@ -0,0 +1,344 @@
+/*
+
+This package prepares and manages reference sequences.
+
+The subcommands included are:
+
+ * annotation - summarise a GFF3 annotation and query its features by position
+ * convert - convert a GenBank reference to a fasta sequence and a GFF3 annotation
+ * prepare - build the indices for a reference (faidx, fasta dict, BWA) in the shared reference cache
+ * inspect - report per-contig length, GC content, N count and soft-masking, flag likely plasmids and compare two references
+ * validate - check a reference for duplicate contig names, illegal characters, empty contigs and inconsistent line lengths
+
+References can be fasta or GenBank files. The reference cache is keyed by the checksum of the reference, so the align pipeline can reuse the indices across runs.
+
+*/
+
+package reference
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/fasta"
+	"github.com/will-rowe/gopherSeq/genbank"
+	"github.com/will-rowe/gopherSeq/gff"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// subcommand holds the help and main function for each reference subcommand
+type subcommand struct {
+	help          string
+	main_function func()
+}
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up a map for all the subcommands
+var subcommands = map[string]subcommand{
+	"annotation": subcommand{"summarise a GFF3 annotation (or find the features at a position)", annotationMain},
+	"convert":    subcommand{"convert a GenBank reference to fasta and GFF3", convertMain},
+	"inspect":    subcommand{"summarise the contigs of a reference (or compare two references)", inspectMain},
+	"prepare":    subcommand{"build the indices for a reference in the reference cache", prepareMain},
+	"validate":   subcommand{"check a reference sequence for problems", validateMain},
+}
+
+// set up command line arguments for the prepare subcommand
+var prepare_args struct {
+	Reference string `arg:"positional,required,help:reference sequence (in fasta or GenBank format)"`
+	Cache_dir string `arg:"-c,help:reference cache directory [default: $gopherSeq_cache or ~/.gopherSeq_cache]"`
+}
+
+// set up command line arguments for the annotation subcommand
+var annotation_args struct {
+	Annotation string `arg:"positional,required,help:annotation (in GFF3 format)"`
+	Region     string `arg:"-r,help:report the features overlapping a region (seqid:position or seqid:start-end)"`
+}
+
+// set up command line arguments for the convert subcommand
+var convert_args struct {
+	Reference string `arg:"positional,required,help:reference sequence (in GenBank format)"`
+	Output    string `arg:"-o,help:output prefix for the .fa and .gff3 files [default: the reference file name]"`
+}
+
+// set up command line arguments for the inspect subcommand
+var inspect_args struct {
+	Reference    string `arg:"positional,required,help:reference sequence (in fasta or GenBank format)"`
+	Compare      string `arg:"-c,help:a second reference to compare against at the contig-level"`
+	Plasmid_size int    `arg:"-p,help:contigs shorter than this are flagged as likely plasmids [default: 500000]"`
+}
+
+// set up command line arguments for the validate subcommand
+var validate_args struct {
+	Reference string `arg:"positional,required,help:reference sequence (in fasta format)"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tprepares and manages reference sequences\n\nusage:\n\tgopherSeq reference <subcommand> [options]\n\nsubcommands:\n", border, border)
+	var names []string
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(my_writer, "\t%s    \t%s\n", name, subcommands[name].help)
+	}
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to read the sequences from a fasta or GenBank reference
+*/
+func Sequences(reference string) ([]*fasta.Sequence, error) {
+	if genbank.IsGenBank(reference) {
+		records, err := genbank.Read(reference)
+		if err != nil {
+			return nil, err
+		}
+		return genbank.Sequences(records), nil
+	}
+	return fasta.Read(reference)
+}
+
+/*
+  function to parse a region string (seqid:position or seqid:start-end)
+*/
+func parseRegion(region string) (string, int, int, error) {
+	i := strings.LastIndex(region, ":")
+	if i == -1 {
+		return "", 0, 0, fmt.Errorf("region should be seqid:position or seqid:start-end: %v", region)
+	}
+	seqid, coordinates := region[:i], strings.Replace(region[i+1:], ",", "", -1)
+	var start, end int
+	var err error
+	if j := strings.Index(coordinates, "-"); j != -1 {
+		if start, err = strconv.Atoi(coordinates[:j]); err == nil {
+			end, err = strconv.Atoi(coordinates[j+1:])
+		}
+	} else {
+		start, err = strconv.Atoi(coordinates)
+		end = start
+	}
+	if err != nil || start < 1 || end < start {
+		return "", 0, 0, fmt.Errorf("can't read region: %v", region)
+	}
+	return seqid, start, end, nil
+}
+
+/*
+  function to summarise a GFF3 annotation
+*/
+func annotationMain() {
+	arg.MustParse(&annotation_args)
+	annotation, err := gff.Read(annotation_args.Annotation)
+	if err != nil {
+		fmt.Printf("could not read annotation: %v\n", err)
+		os.Exit(1)
+	}
+
+	// report the features at the requested regions
+	if len(annotation_args.Region) != 0 {
+		seqid, start, end, err := parseRegion(annotation_args.Region)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("features overlapping %s:%d-%d\n", seqid, start, end)
+		for _, feature := range annotation.Overlapping(seqid, start, end) {
+			product, _ := feature.Attribute("product")
+			fmt.Printf("%s\t%d\t%d\t%c\t%s\t%s\n", feature.Type, feature.Start, feature.End, feature.Strand, feature.Name(), product)
+		}
+		return
+	}
+
+	// report the sequences and the feature counts
+	fmt.Printf("annotation: %v\n", annotation_args.Annotation)
+	var seqids []string
+	for seqid := range annotation.Lengths {
+		seqids = append(seqids, seqid)
+	}
+	sort.Strings(seqids)
+	for _, seqid := range seqids {
+		topology := "linear"
+		if annotation.Circular[seqid] {
+			topology = "circular"
+		}
+		fmt.Printf(" * sequence --> %s (%d bp, %s)\n", seqid, annotation.Lengths[seqid], topology)
+	}
+	counts := make(map[string]int)
+	var feature_types []string
+	for _, feature := range annotation.Features {
+		if _, ok := counts[feature.Type]; !ok {
+			feature_types = append(feature_types, feature.Type)
+		}
+		counts[feature.Type]++
+	}
+	sort.Strings(feature_types)
+	fmt.Printf("feature_type\tcount\n")
+	for _, feature_type := range feature_types {
+		fmt.Printf("%s\t%d\n", feature_type, counts[feature_type])
+	}
+
+	// check the gene structure
+	genes, coding, pseudo, wrapped := 0, 0, 0, 0
+	for _, feature := range annotation.OfType("gene") {
+		genes++
+		for _, child := range feature.Children {
+			if child.Type == "CDS" {
+				coding++
+				break
+			}
+		}
+		if value, ok := feature.Attribute("pseudo"); ok && value == "true" {
+			pseudo++
+		}
+		if length, ok := annotation.Lengths[feature.Seqid]; ok && feature.End > length {
+			wrapped++
+		}
+	}
+	fmt.Printf(" * genes --> %d (%d with a CDS, %d pseudogenes, %d crossing the origin)\n", genes, coding, pseudo, wrapped)
+}
+
+/*
+  function to convert a GenBank reference to fasta and GFF3
+*/
+func convertMain() {
+	arg.MustParse(&convert_args)
+	if len(convert_args.Output) == 0 {
+		convert_args.Output = strings.TrimSuffix(convert_args.Reference, ".gz")
+		convert_args.Output = strings.TrimSuffix(convert_args.Output, filepath.Ext(convert_args.Output))
+	}
+	fasta_file, gff_file := convert_args.Output+".fa", convert_args.Output+".gff3"
+	records, err := genbank.Convert(convert_args.Reference, fasta_file, gff_file)
+	if err != nil {
+		fmt.Printf("could not convert reference: %v\n", err)
+		os.Exit(1)
+	}
+	for _, record := range records {
+		fmt.Printf(" * converted %v (%d bp, %d features)\n", record.Name(), len(record.Seq), len(record.Features))
+		for _, skipped := range record.Skipped {
+			fmt.Printf("\t%v\n", skipped)
+		}
+	}
+	fmt.Printf(" * fasta --> %v\n * GFF3 --> %v\n", fasta_file, gff_file)
+}
+
+/*
+  function to prepare a reference in the cache
+*/
+func prepareMain() {
+	arg.MustParse(&prepare_args)
+	if len(prepare_args.Cache_dir) == 0 {
+		cache_dir, err := CacheDir()
+		if err != nil {
+			fmt.Printf("can't find the reference cache: %v\n", err)
+			os.Exit(1)
+		}
+		prepare_args.Cache_dir = cache_dir
+	}
+	if _, err := os.Stat(prepare_args.Reference); err != nil {
+		fmt.Printf("can't access file: %v\n", prepare_args.Reference)
+		os.Exit(1)
+	}
+	fmt.Printf("preparing reference . . .\n")
+	fasta_file, built, err := Prepare(prepare_args.Reference, prepare_args.Cache_dir)
+	if err != nil {
+		fmt.Printf("could not prepare reference: %v\n", err)
+		os.Exit(1)
+	}
+	if built {
+		fmt.Printf(" * built indices --> %v\n", fasta_file)
+	} else {
+		fmt.Printf(" * already in the reference cache --> %v\n", fasta_file)
+	}
+}
+
+/*
+  function to inspect a reference (and optionally compare it to a second reference)
+*/
+func inspectMain() {
+	inspect_args.Plasmid_size = DefaultPlasmidSize
+	arg.MustParse(&inspect_args)
+	sequences, err := Sequences(inspect_args.Reference)
+	if err != nil {
+		fmt.Printf("could not read reference: %v\n", err)
+		os.Exit(1)
+	}
+	stats := Inspect(sequences, inspect_args.Plasmid_size)
+	fmt.Printf("reference: %v\n", inspect_args.Reference)
+	PrintStats(os.Stdout, stats)
+
+	// compare the references
+	if len(inspect_args.Compare) != 0 {
+		other_sequences, err := Sequences(inspect_args.Compare)
+		if err != nil {
+			fmt.Printf("could not read reference: %v\n", err)
+			os.Exit(1)
+		}
+		other_stats := Inspect(other_sequences, inspect_args.Plasmid_size)
+		fmt.Printf("\nreference: %v\n", inspect_args.Compare)
+		PrintStats(os.Stdout, other_stats)
+		fmt.Printf("\ncomparison (1: %v, 2: %v)\n", inspect_args.Reference, inspect_args.Compare)
+		PrintComparison(os.Stdout, Compare(stats, other_stats))
+	}
+}
+
+/*
+  function to validate a reference
+*/
+func validateMain() {
+	arg.MustParse(&validate_args)
+	contigs, err := fasta.Index(validate_args.Reference)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	length := 0
+	for _, contig := range contigs {
+		length += contig.Length
+	}
+	fmt.Printf("reference is valid: %d contigs, %d bases\n", len(contigs), length)
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage if no subcommand was provided
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+
+	// check that the supplied subcommand is recognised
+	selected, ok := subcommands[os.Args[1]]
+	if !ok {
+		fmt.Printf("unrecognised subcommand: %s\n\n", os.Args[1])
+		printInfo()
+	}
+
+	// remove the subcommand name from the program call
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+	selected.main_function()
+}
@@ -0,0 +1,314 @@
+/*
+
+This package draws trees as standalone SVG figures, with the tip labels, support values, a scale bar and coloured metadata columns.
+
+*/
+
+package render
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/metadata"
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the sizes used to lay out the figure (in pixels)
+const (
+	margin       = 20
+	row_height   = 16
+	tree_width   = 500
+	char_width   = 7
+	column_width = 16
+	header_space = 80
+	legend_gap   = 30
+)
+
+// the colours given to the values of a metadata column (colours are reused if there are more values)
+var palette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf", "#aec7e8", "#ffbb78", "#98df8a", "#ff9896", "#c5b0d5", "#c49c94"}
+
+// the colour for samples without a value
+const missing_colour = "#ffffff"
+
+///////////////
+// STRUCTS
+//////////////
+// Options control what is drawn
+type Options struct {
+	// a metadata table and the columns of it to draw next to the tips (matched to the tips by the ID column, or the first column if it is empty)
+	Metadata *metadata.Table
+	Id       string
+	Columns  []string
+
+	// draw the support values of the branches
+	Support bool
+
+	// the units of the branch lengths, for the scale bar (e.g. SNPs)
+	Units string
+
+	// a title for the figure
+	Title string
+}
+
+// layout is the position of each node in the figure
+type layout struct {
+	x, y map[*newick.Node]float64
+}
+
+// column is a metadata column drawn next to the tips, with the colour of each value
+type column struct {
+	name    string
+	values  map[string]string
+	colours map[string]string
+	order   []string
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to escape text for SVG
+*/
+func escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;").Replace(text)
+}
+
+/*
+  function to get a round length for the scale bar (1, 2 or 5 times a power of 10), about a fifth of the tree depth
+*/
+func scaleLength(depth float64) float64 {
+	if depth <= 0 {
+		return 0
+	}
+	target := depth / 5
+	power := math.Pow(10, math.Floor(math.Log10(target)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if step*power >= target {
+			return step * power
+		}
+	}
+	return 10 * power
+}
+
+/*
+  function to format a number for a label
+*/
+func formatNumber(value float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.6f", value), "0"), ".")
+}
+
+/*
+  function to place the nodes of a tree - tips are spread down the figure in order, and each node is at its distance from the root (or its depth, if there are no branch lengths)
+*/
+func place(root *newick.Node) (*layout, float64, bool) {
+	positions := &layout{x: make(map[*newick.Node]float64), y: make(map[*newick.Node]float64)}
+	depth, lengths := 0.0, false
+	for _, node := range root.Nodes() {
+		if node != root && node.Length > 0 {
+			lengths = true
+		}
+	}
+	var walk func(node *newick.Node, x float64)
+	row := 0
+	walk = func(node *newick.Node, x float64) {
+		positions.x[node] = x
+		if x > depth {
+			depth = x
+		}
+		if node.IsLeaf() {
+			positions.y[node] = float64(row)
+			row++
+			return
+		}
+		for _, child := range node.Children {
+			step := 1.0
+			if lengths {
+				step = child.Length
+			}
+			walk(child, x+step)
+		}
+		positions.y[node] = (positions.y[node.Children[0]] + positions.y[node.Children[len(node.Children)-1]]) / 2
+	}
+	walk(root, 0)
+	return positions, depth, lengths
+}
+
+/*
+  function to get the metadata columns to draw, giving each value a colour
+*/
+func (options *Options) columns(tips []*newick.Node) ([]*column, error) {
+	var columns []*column
+	if options.Metadata == nil {
+		return nil, nil
+	}
+	for _, name := range options.Columns {
+		values, err := options.Metadata.Lookup(options.Id, name)
+		if err != nil {
+			return nil, err
+		}
+		current := &column{name: name, values: values, colours: make(map[string]string)}
+		for _, tip := range tips {
+			if value := values[tip.Name]; len(value) != 0 {
+				current.colours[value] = ""
+			}
+		}
+		for value := range current.colours {
+			current.order = append(current.order, value)
+		}
+		sort.Strings(current.order)
+		for i, value := range current.order {
+			current.colours[value] = palette[i%len(palette)]
+		}
+		columns = append(columns, current)
+	}
+	return columns, nil
+}
+
+/*
+  function to draw a tree as an SVG figure
+*/
+func SVG(writer io.Writer, root *newick.Node, options *Options) error {
+	tips := root.Leaves()
+	positions, depth, lengths := place(root)
+	columns, err := options.columns(tips)
+	if err != nil {
+		return err
+	}
+
+	// work out the size of the figure
+	label_width := 0
+	for _, tip := range tips {
+		if len(tip.Name)*char_width > label_width {
+			label_width = len(tip.Name) * char_width
+		}
+	}
+	top := margin
+	if len(options.Title) != 0 {
+		top += 2 * row_height
+	}
+	if len(columns) != 0 {
+		top += header_space
+	}
+	scale := float64(tree_width)
+	if depth > 0 {
+		scale = tree_width / depth
+	}
+	columns_x := margin + tree_width + 8 + label_width + 10
+	legend_x := columns_x + len(columns)*column_width + legend_gap
+	legend_rows := 0
+	for _, current := range columns {
+		legend_rows += len(current.order) + 2
+	}
+	width := legend_x + margin
+	if len(columns) != 0 {
+		width += 200
+	}
+	height := top + len(tips)*row_height + 3*row_height + margin
+	if legend_height := top + legend_rows*row_height + margin; legend_height > height {
+		height = legend_height
+	}
+	x := func(node *newick.Node) float64 { return float64(margin) + positions.x[node]*scale }
+	y := func(node *newick.Node) float64 { return float64(top) + positions.y[node]*row_height + row_height/2 }
+
+	// start the figure
+	svg := bufio.NewWriter(writer)
+	fmt.Fprintf(svg, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(svg, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" font-family=\"Helvetica, Arial, sans-serif\">\n", width, height, width, height)
+	fmt.Fprintf(svg, "<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	if len(options.Title) != 0 {
+		fmt.Fprintf(svg, "<text x=\"%d\" y=\"%d\" font-size=\"16\" font-weight=\"bold\">%s</text>\n", margin, margin+row_height, escape(options.Title))
+	}
+
+	// draw the branches, with the support values
+	fmt.Fprintf(svg, "<g stroke=\"black\" stroke-width=\"1.5\" fill=\"none\" stroke-linecap=\"square\">\n")
+	for _, node := range root.Nodes() {
+		if node.Parent != nil {
+			fmt.Fprintf(svg, "<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\"/>\n", x(node.Parent), y(node), x(node), y(node))
+		}
+		if !node.IsLeaf() {
+			first, last := node.Children[0], node.Children[len(node.Children)-1]
+			fmt.Fprintf(svg, "<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\"/>\n", x(node), y(first), x(node), y(last))
+		}
+	}
+	fmt.Fprintf(svg, "</g>\n")
+	if options.Support {
+		fmt.Fprintf(svg, "<g font-size=\"8\" fill=\"#555555\" text-anchor=\"end\">\n")
+		for _, node := range root.Nodes() {
+			if node != root && !node.IsLeaf() && node.Support != newick.NoSupport {
+				fmt.Fprintf(svg, "<text x=\"%.2f\" y=\"%.2f\">%s</text>\n", x(node)-2, y(node)-3, formatNumber(node.Support))
+			}
+		}
+		fmt.Fprintf(svg, "</g>\n")
+	}
+
+	// draw the tip labels (and dotted lines out to them, so the metadata lines up)
+	fmt.Fprintf(svg, "<g font-size=\"11\">\n")
+	for _, tip := range tips {
+		fmt.Fprintf(svg, "<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%d\" y2=\"%.2f\" stroke=\"#bbbbbb\" stroke-width=\"0.5\" stroke-dasharray=\"2,2\"/>\n", x(tip), y(tip), margin+tree_width+4, y(tip))
+		fmt.Fprintf(svg, "<text x=\"%d\" y=\"%.2f\" dominant-baseline=\"middle\">%s</text>\n", margin+tree_width+8, y(tip), escape(tip.Name))
+	}
+	fmt.Fprintf(svg, "</g>\n")
+
+	// draw the metadata columns and their legends
+	legend_y := top
+	for i, current := range columns {
+		column_x := columns_x + i*column_width
+		fmt.Fprintf(svg, "<text transform=\"translate(%d,%d) rotate(-60)\" font-size=\"11\">%s</text>\n", column_x+column_width/2, top-6, escape(current.name))
+		for _, tip := range tips {
+			colour := missing_colour
+			if value := current.values[tip.Name]; len(value) != 0 {
+				colour = current.colours[value]
+			}
+			fmt.Fprintf(svg, "<rect x=\"%d\" y=\"%.2f\" width=\"%d\" height=\"%d\" fill=\"%s\" stroke=\"#dddddd\" stroke-width=\"0.5\"/>\n", column_x, y(tip)-row_height/2, column_width, row_height, colour)
+		}
+		fmt.Fprintf(svg, "<text x=\"%d\" y=\"%d\" font-size=\"11\" font-weight=\"bold\">%s</text>\n", legend_x, legend_y+row_height-4, escape(current.name))
+		legend_y += row_height
+		for _, value := range current.order {
+			fmt.Fprintf(svg, "<rect x=\"%d\" y=\"%d\" width=\"10\" height=\"10\" fill=\"%s\"/>\n", legend_x, legend_y+2, current.colours[value])
+			fmt.Fprintf(svg, "<text x=\"%d\" y=\"%d\" font-size=\"10\">%s</text>\n", legend_x+14, legend_y+11, escape(value))
+			legend_y += row_height
+		}
+		legend_y += row_height
+	}
+
+	// draw the scale bar
+	if lengths {
+		bar := scaleLength(depth)
+		bar_y := top + len(tips)*row_height + 2*row_height
+		label := formatNumber(bar)
+		if len(options.Units) != 0 {
+			label += " " + options.Units
+		}
+		fmt.Fprintf(svg, "<line x1=\"%d\" y1=\"%d\" x2=\"%.2f\" y2=\"%d\" stroke=\"black\" stroke-width=\"1.5\"/>\n", margin, bar_y, float64(margin)+bar*scale, bar_y)
+		fmt.Fprintf(svg, "<text x=\"%d\" y=\"%d\" font-size=\"10\">%s</text>\n", margin, bar_y+row_height, escape(label))
+	}
+	fmt.Fprintf(svg, "</svg>\n")
+	return svg.Flush()
+}
+
+/*
+  function to draw a tree as an SVG file
+*/
+func WriteFile(file_name string, root *newick.Node, options *Options) error {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return err
+	}
+	if err := SVG(fh, root, options); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
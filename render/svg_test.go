@@ -0,0 +1,91 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/will-rowe/gopherSeq/metadata"
+	"github.com/will-rowe/gopherSeq/newick"
+)
+
+/*
+  function to draw a tree for a test
+*/
+func drawTest(t *testing.T, text string, options *Options) string {
+	tree, err := newick.Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if err := SVG(&buffer, tree, options); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func TestScaleLength(t *testing.T) {
+	tests := map[float64]float64{0: 0, 4: 1, 0.03: 0.01, 120: 50, 7: 2}
+	for depth, expected := range tests {
+		if length := scaleLength(depth); length != expected {
+			t.Errorf("the scale bar for a depth of %v is %v, expected %v", depth, length, expected)
+		}
+	}
+	if formatNumber(0.5) != "0.5" || formatNumber(20) != "20" || formatNumber(0.0000001) != "0" {
+		t.Errorf("unexpected numbers: %v %v %v", formatNumber(0.5), formatNumber(20), formatNumber(0.0000001))
+	}
+}
+
+func TestPlace(t *testing.T) {
+	tree, err := newick.Parse("((a:1,b:2)90:1,c:4);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions, depth, lengths := place(tree)
+	if depth != 4 || !lengths {
+		t.Errorf("the tree has a depth of %v (branch lengths: %v)", depth, lengths)
+	}
+	clade, leaves := tree.Children[0], tree.Leaves()
+	if positions.x[clade] != 1 || positions.y[clade] != 0.5 || positions.x[leaves[1]] != 3 || positions.y[leaves[2]] != 2 || positions.y[tree] != 1.25 {
+		t.Errorf("unexpected positions: %v %v", positions.x, positions.y)
+	}
+
+	// without branch lengths, each node is at its depth
+	if tree, err = newick.Parse("((a,b),c);"); err != nil {
+		t.Fatal(err)
+	}
+	if positions, depth, lengths = place(tree); depth != 2 || lengths || positions.x[tree.Leaves()[2]] != 1 {
+		t.Errorf("the tree without branch lengths has a depth of %v (branch lengths: %v)", depth, lengths)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	figure := drawTest(t, "((a:1,b:2)90:1,c:4);", &Options{Support: true, Units: "SNPs", Title: "x < y"})
+	for _, expected := range []string{"<svg xmlns=\"http://www.w3.org/2000/svg\"", ">x &lt; y</text>", ">90</text>", ">1 SNPs</text>", ">c</text>", "</svg>\n"} {
+		if !strings.Contains(figure, expected) {
+			t.Errorf("the figure doesn't have %q:\n%v", expected, figure)
+		}
+	}
+	if figure := drawTest(t, "((a,b)90,c);", &Options{}); strings.Contains(figure, ">90</text>") || strings.Contains(figure, "stroke-width=\"1.5\"/>\n<text") {
+		t.Errorf("the support values or a scale bar were drawn:\n%v", figure)
+	}
+}
+
+func TestMetadataColumns(t *testing.T) {
+	table, err := metadata.Parse(strings.NewReader("sample,country\na,UK\nb,France\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the values are given colours in order, and c has no value
+	figure := drawTest(t, "((a:1,b:2):1,c:4);", &Options{Metadata: table, Columns: []string{"country"}})
+	for _, expected := range []string{">country</text>", "fill=\"#1f77b4\"/>\n<text x=\"", ">France</text>", ">UK</text>", "fill=\"" + missing_colour + "\""} {
+		if !strings.Contains(figure, expected) {
+			t.Errorf("the figure doesn't have %q:\n%v", expected, figure)
+		}
+	}
+	tree, _ := newick.Parse("(a,c);")
+	if err := SVG(&bytes.Buffer{}, tree, &Options{Metadata: table, Columns: []string{"host"}}); err == nil {
+		t.Error("a column that isn't in the metadata was drawn")
+	}
+}
@@ -0,0 +1,279 @@
+package snpalign
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/will-rowe/gopherSeq/fasta"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// the bit for each base when recording the bases seen at a site
+var base_bits = map[byte]byte{'A': 1, 'C': 2, 'G': 4, 'T': 8}
+
+///////////////
+// STRUCTS
+//////////////
+// Options control which sites go in the SNP alignment
+type Options struct {
+	// sites where more than this percentage of the samples are N or a gap are dropped (100 keeps every variable site)
+	Max_missing float64
+
+	// a reference sequence to add to the alignment (optional)
+	Reference string
+}
+
+// Stats summarises a SNP alignment
+type Stats struct {
+	Samples  int
+	Length   int
+	Variable int
+	Sites    int
+
+	// the number of constant sites with each base (A, C, G and T) - for ascertainment bias correction when building a tree from the SNP alignment
+	Constant [4]int
+}
+
+// site is a column of the SNP alignment and where it is on the reference
+type site struct {
+	contig   string
+	position int
+	alleles  string
+	missing  int
+}
+
+// contig is a sequence in the whole genome alignment
+type contig struct {
+	name   string
+	length int
+}
+
+// sample is a sequence in the alignment and the file it comes from (the reference is a plain FASTA rather than a pseudogenome)
+type sample struct {
+	name      string
+	file      string
+	reference bool
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to get the default options
+*/
+func DefaultOptions() *Options {
+	return &Options{Max_missing: 100}
+}
+
+/*
+  function to get the contig name from a sequence header (vcf2fa adds a leading underscore to the pseudogenome headers, the reference headers are used as they are)
+*/
+func contigName(name string, reference bool) string {
+	if reference {
+		return name
+	}
+	return strings.TrimPrefix(name, "_")
+}
+
+/*
+  function to read a sample and concatenate its contigs in the order of the alignment
+*/
+func readSample(current sample, contigs []contig) ([]byte, error) {
+	records, err := fasta.Read(current.file)
+	if err != nil {
+		return nil, err
+	}
+	sequences := make(map[string][]byte)
+	for _, record := range records {
+		sequences[contigName(record.Name, current.reference)] = record.Seq
+	}
+	if len(sequences) != len(contigs) {
+		return nil, fmt.Errorf("%v has %d sequences, expected %d", current.file, len(sequences), len(contigs))
+	}
+	var seq []byte
+	for _, contig := range contigs {
+		contig_seq, ok := sequences[contig.name]
+		if !ok {
+			return nil, fmt.Errorf("%v has no sequence called %v", current.file, contig.name)
+		}
+		if len(contig_seq) != contig.length {
+			return nil, fmt.Errorf("%v: %v is %d bases, expected %d (the pseudogenomes must be in reference coordinates)", current.file, contig.name, len(contig_seq), contig.length)
+		}
+		seq = append(seq, strings.ToUpper(string(contig_seq))...)
+	}
+	return seq, nil
+}
+
+/*
+  function to get the samples (and check there is only one of each)
+*/
+func (options *Options) samples(inputs []string) ([]sample, error) {
+	var samples []sample
+	seen := make(map[string]string)
+	if len(options.Reference) != 0 {
+		samples = append(samples, sample{"Reference", options.Reference, true})
+		seen["Reference"] = options.Reference
+	}
+	for _, input := range inputs {
+		name := fasta.SampleName(input)
+		if previous, ok := seen[name]; ok {
+			return nil, fmt.Errorf("sample %v is in both %v and %v", name, previous, input)
+		}
+		seen[name] = input
+		samples = append(samples, sample{name, input, false})
+	}
+	if len(samples) < 2 {
+		return nil, fmt.Errorf("at least 2 sequences are needed for an alignment")
+	}
+	return samples, nil
+}
+
+/*
+  function to build the whole genome alignment, the SNP alignment and the position map from a set of pseudogenomes
+
+  samples are read twice (the first time to find the variable sites and write the whole genome alignment, the second to write the SNP alignment), so only one is in memory at a time:
+
+   * a variable site has at least two different bases (A, C, G or T) - N, gaps and IUPAC codes are counted as missing
+   * sites where more than Max_missing percent of the samples are missing are dropped
+*/
+func (options *Options) Build(inputs []string, full_writer io.Writer, snp_writer io.Writer, map_writer io.Writer) (*Stats, error) {
+	samples, err := options.samples(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the contigs (and their order) come from the first sample
+	records, err := fasta.Read(samples[0].file)
+	if err != nil {
+		return nil, err
+	}
+	var contigs []contig
+	length := 0
+	for _, record := range records {
+		contigs = append(contigs, contig{contigName(record.Name, samples[0].reference), len(record.Seq)})
+		length += len(record.Seq)
+	}
+	records = nil
+
+	// find the bases seen at each site and how many samples are missing, writing the whole genome alignment
+	seen := make([]byte, length)
+	missing := make([]int32, length)
+	for _, current := range samples {
+		seq, err := readSample(current, contigs)
+		if err != nil {
+			return nil, err
+		}
+		for i, base := range seq {
+			if bit, ok := base_bits[base]; ok {
+				seen[i] |= bit
+			} else {
+				missing[i]++
+			}
+		}
+		if full_writer != nil {
+			if err := fasta.Write(full_writer, &fasta.Sequence{Name: current.name, Seq: seq}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// pick the variable sites that pass the core threshold
+	stats := &Stats{Samples: len(samples), Length: length}
+	var columns []int
+	var sites []*site
+	contig_index, contig_start := 0, 0
+	for i := range seen {
+		for i >= contig_start+contigs[contig_index].length {
+			contig_start += contigs[contig_index].length
+			contig_index++
+		}
+		if seen[i]&(seen[i]-1) == 0 {
+			for j, base := range []byte("ACGT") {
+				if seen[i] == base_bits[base] {
+					stats.Constant[j]++
+				}
+			}
+			continue
+		}
+		stats.Variable++
+		if 100*float64(missing[i])/float64(len(samples)) > options.Max_missing {
+			continue
+		}
+		alleles := ""
+		for _, base := range []byte("ACGT") {
+			if seen[i]&base_bits[base] != 0 {
+				alleles += string(base)
+			}
+		}
+		columns = append(columns, i)
+		sites = append(sites, &site{contigs[contig_index].name, i - contig_start + 1, alleles, int(missing[i])})
+	}
+	stats.Sites = len(columns)
+	seen, missing = nil, nil
+
+	// write the SNP alignment
+	if snp_writer != nil {
+		for _, current := range samples {
+			seq, err := readSample(current, contigs)
+			if err != nil {
+				return nil, err
+			}
+			snps := make([]byte, len(columns))
+			for j, column := range columns {
+				snps[j] = seq[column]
+				if _, ok := base_bits[snps[j]]; !ok && snps[j] != '-' {
+					snps[j] = 'N'
+				}
+			}
+			if err := fasta.Write(snp_writer, &fasta.Sequence{Name: current.name, Seq: snps}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// write the position map
+	if map_writer != nil {
+		if _, err := fmt.Fprintf(map_writer, "column\tcontig\tposition\talleles\tmissing\n"); err != nil {
+			return nil, err
+		}
+		for j, column := range sites {
+			if _, err := fmt.Fprintf(map_writer, "%d\t%s\t%d\t%s\t%d\n", j+1, column.contig, column.position, column.alleles, column.missing); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+/*
+  function to build the alignments and write them to files (<prefix>.full.aln, <prefix>.snps.aln and <prefix>.positions.tsv)
+*/
+func (options *Options) BuildFiles(inputs []string, prefix string) (*Stats, error) {
+	var writers []*bufio.Writer
+	for _, suffix := range []string{".full.aln", ".snps.aln", ".positions.tsv"} {
+		fh, err := os.Create(prefix + suffix)
+		if err != nil {
+			return nil, err
+		}
+		defer fh.Close()
+		writers = append(writers, bufio.NewWriter(fh))
+	}
+	stats, err := options.Build(inputs, writers[0], writers[1], writers[2])
+	if err != nil {
+		return nil, err
+	}
+	for _, writer := range writers {
+		if err := writer.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
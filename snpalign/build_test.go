@@ -0,0 +1,100 @@
+package snpalign
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+// a reference with two contigs (one with a name starting with an underscore) and three pseudogenomes (vcf2fa adds an underscore to each header)
+var test_files = map[string]string{
+	"ref.fa":             ">chrA\nACGTACGTAC\n>_p\nGGGG\n",
+	"s1.pseudogenome.fa": ">_chrA\nACGTACGTAC\n>__p\nGGGG\n",
+	"s2.pseudogenome.fa": ">_chrA\nACTTACGTAN\n>__p\nGGGA\n",
+	"s3.pseudogenome.fa": ">_chrA\nACNTNCGTAC\n>__p\nGGGA\n",
+}
+
+/*
+  function to write the test files to a temporary directory
+*/
+func writeTestFiles(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "snpalign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range test_files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBuild(t *testing.T) {
+	dir := writeTestFiles(t)
+	defer os.RemoveAll(dir)
+	options := DefaultOptions()
+	options.Reference = path.Join(dir, "ref.fa")
+	inputs := []string{path.Join(dir, "s1.pseudogenome.fa"), path.Join(dir, "s2.pseudogenome.fa"), path.Join(dir, "s3.pseudogenome.fa")}
+	var full, snps, positions bytes.Buffer
+	stats, err := options.Build(inputs, &full, &snps, &positions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Samples != 4 || stats.Length != 14 || stats.Variable != 2 || stats.Sites != 2 || stats.Constant != [4]int{3, 3, 4, 2} {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if full.String() != ">Reference\nACGTACGTACGGGG\n>s1\nACGTACGTACGGGG\n>s2\nACTTACGTANGGGA\n>s3\nACNTNCGTACGGGA\n" {
+		t.Errorf("unexpected whole genome alignment:\n%v", full.String())
+	}
+	if snps.String() != ">Reference\nGG\n>s1\nGG\n>s2\nTA\n>s3\nNA\n" {
+		t.Errorf("unexpected SNP alignment:\n%v", snps.String())
+	}
+
+	// the reference contig names are kept as they are, only the pseudogenome underscore is dropped
+	if positions.String() != "column\tcontig\tposition\talleles\tmissing\n1\tchrA\t3\tGT\t1\n2\t_p\t4\tAG\t0\n" {
+		t.Errorf("unexpected positions:\n%v", positions.String())
+	}
+}
+
+func TestCoreSites(t *testing.T) {
+	dir := writeTestFiles(t)
+	defer os.RemoveAll(dir)
+
+	// chrA:3 is missing in 1 of the 3 samples
+	options := &Options{Max_missing: 30}
+	inputs := []string{path.Join(dir, "s1.pseudogenome.fa"), path.Join(dir, "s2.pseudogenome.fa"), path.Join(dir, "s3.pseudogenome.fa")}
+	var snps bytes.Buffer
+	stats, err := options.Build(inputs, nil, &snps, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Variable != 2 || stats.Sites != 1 || snps.String() != ">s1\nG\n>s2\nA\n>s3\nA\n" {
+		t.Errorf("%d of %d variable sites kept:\n%v", stats.Sites, stats.Variable, snps.String())
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	dir := writeTestFiles(t)
+	defer os.RemoveAll(dir)
+	short := path.Join(dir, "short.pseudogenome.fa")
+	if err := ioutil.WriteFile(short, []byte(">_chrA\nACGT\n>__p\nGGGG\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s1 := path.Join(dir, "s1.pseudogenome.fa")
+	bad := map[string][]string{
+		"a single sample":                  {s1},
+		"the same sample twice":            {s1, path.Join(dir, "s1.fa")},
+		"a pseudogenome of the wrong size": {s1, short},
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "s1.fa"), []byte(test_files["s1.pseudogenome.fa"]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for name, inputs := range bad {
+		if _, err := DefaultOptions().Build(inputs, nil, nil, nil); err == nil {
+			t.Errorf("an alignment of %v was built", name)
+		}
+	}
+}
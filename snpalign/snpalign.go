@@ -0,0 +1,81 @@
+/*
+
+This package builds a multiple sequence alignment from a set of pseudogenomes (for phylogenetics).
+
+The pseudogenomes must all be in reference coordinates (i.e. built without --indels). Three files are written:
+
+ * <prefix>.full.aln - the whole genome alignment (the contigs of each sample concatenated)
+ * <prefix>.snps.aln - the variable sites only
+ * <prefix>.positions.tsv - the reference contig and position of each column of the SNP alignment
+
+Sites where too many samples are N or a gap can be dropped to give a core alignment (--core).
+
+*/
+
+package snpalign
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexflint/go-arg"
+)
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up command line arguments
+var args struct {
+	Input     []string `arg:"positional,required,help:pseudogenomes to align (in FASTA format - can be .gz)"`
+	Output    string   `arg:"-o,help:prefix for the output files [default: ./snpalign]"`
+	Core      float64  `arg:"-c,help:drop sites where more than this percentage of the samples are N or a gap (e.g. 5 for a 95% core alignment) [default: 100]"`
+	Reference string   `arg:"-r,help:reference FASTA to add to the alignment"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\tbuilds whole genome and core SNP alignments from pseudogenomes\n\nusage:\n\tgopherSeq snpalign [options] PSEUDOGENOME...\n\nhelp:\n\tgopherSeq snpalign --help\n", border, border)
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage or parse arguments
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+	options := DefaultOptions()
+	args.Output, args.Core = "./snpalign", options.Max_missing
+	arg.MustParse(&args)
+	if args.Core < 0 || args.Core > 100 {
+		fmt.Println("--core must be a percentage (0-100)")
+		os.Exit(1)
+	}
+	options.Max_missing, options.Reference = args.Core, args.Reference
+
+	// build the alignments
+	stats, err := options.BuildFiles(args.Input, args.Output)
+	if err != nil {
+		fmt.Printf("could not build alignment: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(" * whole genome alignment --> %v.full.aln (%d sequences of %d bases)\n", args.Output, stats.Samples, stats.Length)
+	fmt.Printf(" * SNP alignment --> %v.snps.aln (%d of %d variable sites)\n", args.Output, stats.Sites, stats.Variable)
+	fmt.Printf(" * position map --> %v.positions.tsv\n", args.Output)
+	fmt.Printf(" * constant sites (A C G T) --> %d %d %d %d\n", stats.Constant[0], stats.Constant[1], stats.Constant[2], stats.Constant[3])
+}
@@ -4,5 +4,34 @@ go build -o ./gopherSeq ../cmd/gopherSeq/main.go
 export gopherSeq_bin=../bin
 
 ./gopherSeq version
+./gopherSeq reference annotation ./data/RefSeq/NC_011294.gff
+./gopherSeq reference annotation --region NC_011294.1:10 ./data/RefSeq/NC_011294.gff
+./gopherSeq reference inspect ./data/RefSeq/NC_004741.fasta
+./gopherSeq reference validate ./data/RefSeq/NC_004741.fasta
+./gopherSeq reference prepare --cache_dir ./gopherSeq_cache ./data/RefSeq/NC_004741.fasta
 ./gopherSeq qcheck ./data/reads/ERR1107833_downsampled_singletons.fastq.gz
-./gopherSeq align --reference ./data/RefSeq/NC_004741.fasta ./data/reads/ERR1107833_downsampled_pass*.fastq.gz
+./gopherSeq align -o ./gopherSeq-align --cache_dir ./gopherSeq_cache --reference ./data/RefSeq/NC_004741.fasta ./data/reads/ERR1107833_downsampled_pass*.fastq.gz
+./gopherSeq consensus --min_depth 5 -o ./consensus.fa ../consensus/testdata/sample.vcf
+perl ../bin/vcfutils.pl vcf2fa -d 5 ../consensus/testdata/sample.vcf 2> /dev/null | diff - ./consensus.fa
+./gopherSeq filter -o ./sample.filtered.vcf ../consensus/testdata/sample.vcf
+printf 'chrA\t0\t10\n' > ./mask.bed
+./gopherSeq consensus --min_depth 5 --mask_bed ./mask.bed -o ./masked.fa ../consensus/testdata/sample.vcf
+./gopherSeq consensus --min_depth 5 --indels --chain ./sample.chain -o ./sample.consensus.fa ../consensus/testdata/sample.vcf
+./gopherSeq liftover --chain ./sample.chain --position chrA:44 chrA:46 chrA:60
+./gopherSeq liftover --chain ./sample.chain --reverse --position _chrA:50
+./gopherSeq consensus --min_depth 5 --fastq ./sample.pseudogenome.fq --histogram ./sample.quality_histogram.tsv -o ./sample.pseudogenome.fa ../consensus/testdata/sample.vcf
+./gopherSeq snpalign --reference ./data/RefSeq/NC_004741.fasta -o ./core ./gopherSeq-align/pseudogenomes/*.pseudogenome.fa
+./gopherSeq distance -p ./pairs.tsv -o ./distances.tsv ./gopherSeq-align/pseudogenomes/*.pseudogenome.fa
+./gopherSeq cluster -t 25,10,5 -o ./clusters ./distances.tsv
+./gopherSeq cluster -t 25,10,5 --history ./clusters.history.json -o ./clusters ./distances.tsv
+./gopherSeq nj --midpoint -b 100 -o ./nj.nwk ./gopherSeq-align/pseudogenomes/*.pseudogenome.fa
+./gopherSeq nj -o ./nj.matrix.nwk ./distances.tsv
+./gopherSeq tree reroot --midpoint -o ./nj.rerooted.nwk ./nj.matrix.nwk
+./gopherSeq tree collapse -s 70 -o ./nj.collapsed.nwk ./nj.nwk
+./gopherSeq tree ladderize -o ./nj.ladderized.nwk ./nj.nwk
+./gopherSeq tree rf ./nj.nwk ./nj.rerooted.nwk
+printf 'sample,group\nReference,reference\n' > ./samples.csv
+./gopherSeq align -o ./gopherSeq-align-tree --cache_dir ./gopherSeq_cache --metadata ./samples.csv --reference ./data/RefSeq/NC_004741.fasta ./data/reads/ERR1107833_downsampled_pass*.fastq.gz
+./gopherSeq tree draw --units SNPs --metadata ./samples.csv -o ./nj.svg ./nj.nwk
+./gopherSeq export -m ./samples.csv -c ./clusters.membership.tsv -a ./core.snps.aln -r ./data/RefSeq/NC_004741.fasta -o ./export ./nj.nwk
+./gopherSeq recomb -r ./data/RefSeq/NC_004741.fasta -o ./recomb ./core.full.aln
@@ -0,0 +1,348 @@
+/*
+
+This package has tools for working with trees in Newick format (e.g. from gopherSeq nj or align --phylogeny).
+
+The subcommands are:
+
+ * reroot - root a tree at its midpoint or on an outgroup
+ * prune - remove samples from a tree (or keep only some samples)
+ * rename - rename the tips of a tree from a metadata table
+ * ladderize - sort the clades of a tree by size
+ * collapse - collapse branches with low support
+ * rf - the Robinson-Foulds distance between two trees
+ * draw - draw a tree as an SVG figure, with coloured metadata columns
+
+*/
+
+package tree
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/will-rowe/gopherSeq/metadata"
+	"github.com/will-rowe/gopherSeq/newick"
+	"github.com/will-rowe/gopherSeq/render"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// subcommand holds the help and main function for each tree subcommand
+type subcommand struct {
+	help          string
+	main_function func()
+}
+
+///////////////
+// GLOBALS
+//////////////
+const border string = "-----------------------------------------------"
+
+// set up a map for all the subcommands
+var subcommands = map[string]subcommand{
+	"collapse":  subcommand{"collapse branches with low support", collapseMain},
+	"draw":      subcommand{"draw a tree as an SVG figure", drawMain},
+	"ladderize": subcommand{"sort the clades of a tree by size", ladderizeMain},
+	"prune":     subcommand{"remove samples from a tree", pruneMain},
+	"rename":    subcommand{"rename the tips of a tree from a metadata table", renameMain},
+	"reroot":    subcommand{"root a tree at its midpoint or on an outgroup", rerootMain},
+	"rf":        subcommand{"Robinson-Foulds distance between two trees", rfMain},
+}
+
+// set up command line arguments for the reroot subcommand
+var reroot_args struct {
+	Tree     string `arg:"positional,required,help:tree (in Newick format)"`
+	Midpoint bool   `arg:"-m,help:root the tree at its midpoint [default: false]"`
+	Outgroup string `arg:"help:root the tree on these samples - separated by commas"`
+	Output   string `arg:"-o,help:output Newick file [default: STDOUT]"`
+}
+
+// set up command line arguments for the prune subcommand
+var prune_args struct {
+	Tree    string `arg:"positional,required,help:tree (in Newick format)"`
+	Samples string `arg:"required,-s,help:samples to remove - separated by commas or a file with one per line"`
+	Keep    bool   `arg:"-k,help:keep only these samples instead of removing them [default: false]"`
+	Output  string `arg:"-o,help:output Newick file [default: STDOUT]"`
+}
+
+// set up command line arguments for the rename subcommand
+var rename_args struct {
+	Tree     string `arg:"positional,required,help:tree (in Newick format)"`
+	Metadata string `arg:"required,-m,help:metadata table (TSV or CSV with a header line)"`
+	Id       string `arg:"help:column of the metadata with the current tip names [default: the first column]"`
+	Column   string `arg:"required,-c,help:column of the metadata with the new tip names"`
+	Output   string `arg:"-o,help:output Newick file [default: STDOUT]"`
+}
+
+// set up command line arguments for the ladderize subcommand
+var ladderize_args struct {
+	Tree    string `arg:"positional,required,help:tree (in Newick format)"`
+	Reverse bool   `arg:"-r,help:put the largest clades first [default: false]"`
+	Output  string `arg:"-o,help:output Newick file [default: STDOUT]"`
+}
+
+// set up command line arguments for the collapse subcommand
+var collapse_args struct {
+	Tree        string  `arg:"positional,required,help:tree (in Newick format)"`
+	Min_support float64 `arg:"-s,required,help:collapse branches with support below this"`
+	Output      string  `arg:"-o,help:output Newick file [default: STDOUT]"`
+}
+
+// set up command line arguments for the rf subcommand
+var rf_args struct {
+	Tree  string `arg:"positional,required,help:first tree (in Newick format)"`
+	Other string `arg:"positional,required,help:second tree (in Newick format)"`
+}
+
+// set up command line arguments for the draw subcommand
+var draw_args struct {
+	Tree       string `arg:"positional,required,help:tree (in Newick format)"`
+	Output     string `arg:"required,-o,help:output SVG file"`
+	Metadata   string `arg:"-m,help:metadata table to colour the tips by (TSV or CSV with a header line)"`
+	Id         string `arg:"help:column of the metadata with the tip names [default: the first column]"`
+	Columns    string `arg:"-c,help:columns of the metadata to draw - separated by commas [default: every column]"`
+	No_support bool   `arg:"help:don't draw the support values [default: false]"`
+	Units      string `arg:"help:units of the branch lengths for the scale bar (e.g. SNPs)"`
+	Title      string `arg:"help:title of the figure"`
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to print info on our package
+*/
+func printInfo() {
+	var my_writer io.Writer = os.Stdout
+	fmt.Fprintf(my_writer, "\n%s\n\t- gopherSeq -\n\na collection of tools for bacterial WGS data\n%s\n\nabout:\n\ttools for working with Newick trees\n\nusage:\n\tgopherSeq tree <subcommand> [options]\n\nsubcommands:\n", border, border)
+	var names []string
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(my_writer, "\t%s    \t%s\n", name, subcommands[name].help)
+	}
+	fmt.Fprintf(my_writer, "\n\n")
+	os.Exit(0)
+}
+
+/*
+  function to read a tree, exiting if it can't be read
+*/
+func readTree(file_name string) *newick.Node {
+	root, err := newick.Read(file_name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read tree: %v\n", err)
+		os.Exit(1)
+	}
+	return root
+}
+
+/*
+  function to write a tree, exiting if it can't be written
+*/
+func writeTree(file_name string, root *newick.Node) {
+	if err := newick.WriteFile(file_name, root); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write tree: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+/*
+  function to get a list of samples (separated by commas, or in a file with one per line)
+*/
+func sampleList(samples string) ([]string, error) {
+	if _, err := os.Stat(samples); err != nil {
+		return strings.Split(samples, ","), nil
+	}
+	fh, err := os.Open(samples)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var list []string
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		if sample := strings.TrimSpace(scanner.Text()); len(sample) != 0 {
+			list = append(list, sample)
+		}
+	}
+	return list, scanner.Err()
+}
+
+/*
+  function to root a tree
+*/
+func rerootMain() {
+	arg.MustParse(&reroot_args)
+	if reroot_args.Midpoint == (len(reroot_args.Outgroup) != 0) {
+		fmt.Fprintf(os.Stderr, "use one of --midpoint or --outgroup\n")
+		os.Exit(1)
+	}
+	root := readTree(reroot_args.Tree)
+	if reroot_args.Midpoint {
+		root = newick.MidpointRoot(root)
+	} else {
+		var err error
+		if root, err = newick.OutgroupRoot(root, strings.Split(reroot_args.Outgroup, ",")); err != nil {
+			fmt.Fprintf(os.Stderr, "could not root tree: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	writeTree(reroot_args.Output, root)
+}
+
+/*
+  function to remove samples from a tree
+*/
+func pruneMain() {
+	arg.MustParse(&prune_args)
+	root := readTree(prune_args.Tree)
+	samples, err := sampleList(prune_args.Samples)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read samples: %v\n", err)
+		os.Exit(1)
+	}
+
+	// to keep samples, remove every other leaf
+	if prune_args.Keep {
+		if _, err := root.FindLeaves(samples); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		keep := make(map[string]bool)
+		for _, sample := range samples {
+			keep[sample] = true
+		}
+		var remove []string
+		for _, leaf := range root.Leaves() {
+			if !keep[leaf.Name] {
+				remove = append(remove, leaf.Name)
+			}
+		}
+		samples = remove
+	}
+	if root, err = newick.Prune(root, samples); err != nil {
+		fmt.Fprintf(os.Stderr, "could not prune tree: %v\n", err)
+		os.Exit(1)
+	}
+	writeTree(prune_args.Output, root)
+	fmt.Fprintf(os.Stderr, " * removed %d samples (%d left)\n", len(samples), len(root.Leaves()))
+}
+
+/*
+  function to rename the tips of a tree from a metadata table
+*/
+func renameMain() {
+	arg.MustParse(&rename_args)
+	root := readTree(rename_args.Tree)
+	table, err := metadata.Read(rename_args.Metadata)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read metadata: %v\n", err)
+		os.Exit(1)
+	}
+	names, err := table.Lookup(rename_args.Id, rename_args.Column)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	renamed := newick.Rename(root, names)
+	writeTree(rename_args.Output, root)
+	fmt.Fprintf(os.Stderr, " * renamed %d of %d tips\n", renamed, len(root.Leaves()))
+}
+
+/*
+  function to sort the clades of a tree by size
+*/
+func ladderizeMain() {
+	arg.MustParse(&ladderize_args)
+	root := readTree(ladderize_args.Tree)
+	newick.Ladderize(root, ladderize_args.Reverse)
+	writeTree(ladderize_args.Output, root)
+}
+
+/*
+  function to collapse branches with low support
+*/
+func collapseMain() {
+	arg.MustParse(&collapse_args)
+	root := readTree(collapse_args.Tree)
+	collapsed := newick.Collapse(root, collapse_args.Min_support)
+	writeTree(collapse_args.Output, root)
+	fmt.Fprintf(os.Stderr, " * collapsed %d branches with support below %v\n", collapsed, collapse_args.Min_support)
+}
+
+/*
+  function to get the Robinson-Foulds distance between two trees
+*/
+func rfMain() {
+	arg.MustParse(&rf_args)
+	distance, splits, err := newick.RobinsonFoulds(readTree(rf_args.Tree), readTree(rf_args.Other))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not compare trees: %v\n", err)
+		os.Exit(1)
+	}
+	normalised := 0.0
+	if splits > 0 {
+		normalised = float64(distance) / float64(splits)
+	}
+	fmt.Printf("robinson_foulds\tnormalised\n%d\t%.4f\n", distance, normalised)
+}
+
+/*
+  function to draw a tree as an SVG figure
+*/
+func drawMain() {
+	arg.MustParse(&draw_args)
+	root := readTree(draw_args.Tree)
+	options := &render.Options{Id: draw_args.Id, Support: !draw_args.No_support, Units: draw_args.Units, Title: draw_args.Title}
+	if len(draw_args.Metadata) != 0 {
+		table, err := metadata.Read(draw_args.Metadata)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read metadata: %v\n", err)
+			os.Exit(1)
+		}
+		options.Metadata = table
+		if len(draw_args.Columns) != 0 {
+			options.Columns = strings.Split(draw_args.Columns, ",")
+		} else {
+			options.Columns = table.Others(draw_args.Id)
+		}
+	}
+	if err := render.WriteFile(draw_args.Output, root, options); err != nil {
+		fmt.Fprintf(os.Stderr, "could not draw tree: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, " * drew %d tips and %d metadata columns\n", len(root.Leaves()), len(options.Columns))
+}
+
+///////////////
+// MAIN
+//////////////
+func Main() {
+	// print usage if no subcommand was provided
+	if len(os.Args) < 2 {
+		printInfo()
+	}
+
+	// check that the supplied subcommand is recognised
+	selected, ok := subcommands[os.Args[1]]
+	if !ok {
+		fmt.Printf("unrecognised subcommand: %s\n\n", os.Args[1])
+		printInfo()
+	}
+
+	// remove the subcommand name from the program call
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+	selected.main_function()
+}
@@ -0,0 +1,33 @@
+package tree
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestSampleList(t *testing.T) {
+	samples, err := sampleList("s1,s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(samples, []string{"s1", "s2"}) {
+		t.Errorf("unexpected samples: %v", samples)
+	}
+
+	// a file has one sample per line (blank lines are skipped)
+	dir, err := ioutil.TempDir("", "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file_name := path.Join(dir, "samples.txt")
+	if err := ioutil.WriteFile(file_name, []byte("s1\n\n s3 \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if samples, err = sampleList(file_name); err != nil || !reflect.DeepEqual(samples, []string{"s1", "s3"}) {
+		t.Errorf("unexpected samples from a file: %v (%v)", samples, err)
+	}
+}
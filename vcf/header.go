@@ -0,0 +1,202 @@
+package vcf
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+///////////////
+// STRUCTS
+//////////////
+// Header holds the meta-information lines and the sample names of a VCF
+type Header struct {
+	Lines   []string
+	Info    map[string]*Definition
+	Format  map[string]*Definition
+	Filter  map[string]*Definition
+	Contigs []*Contig
+	Samples []string
+}
+
+// Definition describes an INFO, FORMAT or FILTER field
+type Definition struct {
+	ID          string
+	Number      string
+	Type        string
+	Description string
+}
+
+// Contig is a ##contig line
+type Contig struct {
+	ID     string
+	Length int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to create an empty VCF header
+*/
+func NewHeader() *Header {
+	return &Header{Lines: []string{"##fileformat=VCFv4.2"}, Info: make(map[string]*Definition), Format: make(map[string]*Definition), Filter: make(map[string]*Definition)}
+}
+
+/*
+  function to split the key=value pairs of a structured meta-information line (e.g. <ID=DP,Number=1,...>)
+*/
+func parseStructured(value string) map[string]string {
+	fields := make(map[string]string)
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">")
+	for len(value) != 0 {
+		equals := strings.Index(value, "=")
+		if equals == -1 {
+			break
+		}
+		key := value[:equals]
+		value = value[equals+1:]
+		var field string
+		if strings.HasPrefix(value, "\"") {
+			end := 1
+			for end < len(value) && (value[end] != '"' || value[end-1] == '\\') {
+				end++
+			}
+			field = strings.Replace(value[1:end], "\\\"", "\"", -1)
+			if end < len(value) {
+				end++
+			}
+			value = value[end:]
+		} else {
+			end := strings.Index(value, ",")
+			if end == -1 {
+				end = len(value)
+			}
+			field = value[:end]
+			value = value[end:]
+		}
+		fields[key] = field
+		value = strings.TrimPrefix(value, ",")
+	}
+	return fields
+}
+
+/*
+  function to add a meta-information line to the header
+*/
+func (header *Header) addLine(line string) {
+	header.Lines = append(header.Lines, line)
+	equals := strings.Index(line, "=")
+	if equals == -1 {
+		return
+	}
+	key, value := line[2:equals], line[equals+1:]
+	if !strings.HasPrefix(value, "<") {
+		return
+	}
+	fields := parseStructured(value)
+	switch key {
+	case "INFO", "FORMAT", "FILTER":
+		definition := &Definition{ID: fields["ID"], Number: fields["Number"], Type: fields["Type"], Description: fields["Description"]}
+		switch key {
+		case "INFO":
+			header.Info[definition.ID] = definition
+		case "FORMAT":
+			header.Format[definition.ID] = definition
+		default:
+			header.Filter[definition.ID] = definition
+		}
+	case "contig":
+		contig := &Contig{ID: fields["ID"]}
+		contig.Length, _ = strconv.Atoi(fields["length"])
+		header.Contigs = append(header.Contigs, contig)
+	}
+}
+
+/*
+  function to add a definition line to the header (replacing any existing definition with the same ID)
+*/
+func (header *Header) addDefinition(key string, definitions map[string]*Definition, definition *Definition) {
+	var line string
+	if key == "FILTER" {
+		line = fmt.Sprintf("##FILTER=<ID=%s,Description=\"%s\">", definition.ID, strings.Replace(definition.Description, "\"", "\\\"", -1))
+	} else {
+		line = fmt.Sprintf("##%s=<ID=%s,Number=%s,Type=%s,Description=\"%s\">", key, definition.ID, definition.Number, definition.Type, strings.Replace(definition.Description, "\"", "\\\"", -1))
+	}
+	prefix := "##" + key + "=<ID=" + definition.ID + ","
+	for i, existing := range header.Lines {
+		if strings.HasPrefix(existing, prefix) {
+			header.Lines[i] = line
+			definitions[definition.ID] = definition
+			return
+		}
+	}
+	header.Lines = append(header.Lines, line)
+	definitions[definition.ID] = definition
+}
+
+/*
+  function to add an INFO field definition to the header
+*/
+func (header *Header) AddInfo(id string, number string, value_type string, description string) {
+	header.addDefinition("INFO", header.Info, &Definition{id, number, value_type, description})
+}
+
+/*
+  function to add a FORMAT field definition to the header
+*/
+func (header *Header) AddFormat(id string, number string, value_type string, description string) {
+	header.addDefinition("FORMAT", header.Format, &Definition{id, number, value_type, description})
+}
+
+/*
+  function to add a FILTER definition to the header
+*/
+func (header *Header) AddFilter(id string, description string) {
+	header.addDefinition("FILTER", header.Filter, &Definition{ID: id, Description: description})
+}
+
+/*
+  function to get the length of a contig (0 if it isn't in the header)
+*/
+func (header *Header) ContigLength(id string) int {
+	for _, contig := range header.Contigs {
+		if contig.ID == id {
+			return contig.Length
+		}
+	}
+	return 0
+}
+
+/*
+  function to get the index of a sample (-1 if it isn't in the header)
+*/
+func (header *Header) SampleIndex(sample string) int {
+	for i, name := range header.Samples {
+		if name == sample {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+  function to write the header
+*/
+func (header *Header) Write(writer io.Writer) error {
+	for _, line := range header.Lines {
+		if _, err := fmt.Fprintln(writer, line); err != nil {
+			return err
+		}
+	}
+	columns := "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO"
+	if len(header.Samples) != 0 {
+		columns += "\tFORMAT\t" + strings.Join(header.Samples, "\t")
+	}
+	_, err := fmt.Fprintln(writer, columns)
+	return err
+}
@@ -0,0 +1,387 @@
+package vcf
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// MissingQual is the QUAL of a record with a missing (.) quality
+const MissingQual float64 = -1
+
+///////////////
+// STRUCTS
+//////////////
+// Record is a single line of a VCF
+type Record struct {
+	Chrom   string
+	Pos     int
+	ID      string
+	Ref     string
+	Alt     []string
+	Qual    float64
+	Filter  []string
+	Info    []InfoField
+	Format  []string
+	Samples [][]string
+
+	// the QUAL as it was written in the file (kept so that unchanged records are written back as they were read)
+	qual_text string
+}
+
+// InfoField is a key and its (unparsed) value from the INFO column (flags have no value)
+type InfoField struct {
+	Key   string
+	Value string
+	Flag  bool
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to parse a VCF data line
+*/
+func parseRecord(line string, samples int) (*Record, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 8 {
+		return nil, fmt.Errorf("expected at least 8 columns, found %d", len(fields))
+	}
+	if samples != 0 && len(fields) != 9+samples {
+		return nil, fmt.Errorf("expected %d columns, found %d", 9+samples, len(fields))
+	}
+	record := &Record{Chrom: fields[0], ID: fields[2], Ref: fields[3], Qual: MissingQual, qual_text: fields[5]}
+	var err error
+	if record.Pos, err = strconv.Atoi(fields[1]); err != nil {
+		return nil, fmt.Errorf("bad position: %v", fields[1])
+	}
+	if fields[4] != "." {
+		record.Alt = strings.Split(fields[4], ",")
+	}
+	if fields[5] != "." {
+		if record.Qual, err = strconv.ParseFloat(fields[5], 64); err != nil {
+			return nil, fmt.Errorf("bad QUAL: %v", fields[5])
+		}
+	}
+	if fields[6] != "." {
+		record.Filter = strings.Split(fields[6], ";")
+	}
+	if fields[7] != "." {
+		for _, pair := range strings.Split(fields[7], ";") {
+			if equals := strings.Index(pair, "="); equals != -1 {
+				record.Info = append(record.Info, InfoField{Key: pair[:equals], Value: pair[equals+1:]})
+			} else {
+				record.Info = append(record.Info, InfoField{Key: pair, Flag: true})
+			}
+		}
+	}
+	if len(fields) > 8 {
+		record.Format = strings.Split(fields[8], ":")
+		for _, sample := range fields[9:] {
+			record.Samples = append(record.Samples, strings.Split(sample, ":"))
+		}
+	}
+	return record, nil
+}
+
+/*
+  function to format a record as a VCF line (without the newline)
+*/
+func (record *Record) String() string {
+	fields := make([]string, 8, 9+len(record.Samples))
+	fields[0], fields[1], fields[2], fields[3] = record.Chrom, strconv.Itoa(record.Pos), record.ID, record.Ref
+	if len(record.ID) == 0 {
+		fields[2] = "."
+	}
+	fields[4] = "."
+	if len(record.Alt) != 0 {
+		fields[4] = strings.Join(record.Alt, ",")
+	}
+	fields[5] = record.QualString()
+	fields[6] = record.FilterString()
+	fields[7] = "."
+	if len(record.Info) != 0 {
+		pairs := make([]string, len(record.Info))
+		for i, field := range record.Info {
+			if field.Flag {
+				pairs[i] = field.Key
+			} else {
+				pairs[i] = field.Key + "=" + field.Value
+			}
+		}
+		fields[7] = strings.Join(pairs, ";")
+	}
+	if len(record.Format) != 0 {
+		fields = append(fields, strings.Join(record.Format, ":"))
+		for _, sample := range record.Samples {
+			fields = append(fields, strings.Join(sample, ":"))
+		}
+	}
+	return strings.Join(fields, "\t")
+}
+
+/*
+  function to format the QUAL column (. if missing)
+*/
+func (record *Record) QualString() string {
+	if record.Qual < 0 {
+		return "."
+	}
+	if value, err := strconv.ParseFloat(record.qual_text, 64); err == nil && value == record.Qual {
+		return record.qual_text
+	}
+	return strconv.FormatFloat(record.Qual, 'g', -1, 64)
+}
+
+/*
+  function to format the FILTER column (. if missing)
+*/
+func (record *Record) FilterString() string {
+	if len(record.Filter) == 0 {
+		return "."
+	}
+	return strings.Join(record.Filter, ";")
+}
+
+/*
+  function to check if a record passed the filters (FILTER is PASS or missing)
+*/
+func (record *Record) Passed() bool {
+	for _, filter := range record.Filter {
+		if filter != "PASS" && filter != "." {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+  function to check if a site is variant (has an ALT allele other than . or <*>)
+*/
+func (record *Record) IsVariant() bool {
+	for _, alt := range record.Alt {
+		if alt != "." && alt != "<*>" && alt != "<X>" {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+  function to check if a record is an indel (bcftools/samtools mark these with the INDEL flag)
+*/
+func (record *Record) IsIndel() bool {
+	if record.HasFlag("INDEL") {
+		return true
+	}
+	for _, alt := range record.Alt {
+		if !strings.HasPrefix(alt, "<") && alt != "." && len(alt) != len(record.Ref) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+  function to get the value of an INFO field (and whether it was found)
+*/
+func (record *Record) InfoValue(key string) (string, bool) {
+	for _, field := range record.Info {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+/*
+  function to check if an INFO flag is set
+*/
+func (record *Record) HasFlag(key string) bool {
+	for _, field := range record.Info {
+		if field.Key == key && field.Flag {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+  function to get an integer INFO field
+*/
+func (record *Record) InfoInt(key string) (int, bool) {
+	value, ok := record.InfoValue(key)
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.Atoi(value)
+	return number, err == nil
+}
+
+/*
+  function to get a float INFO field
+*/
+func (record *Record) InfoFloat(key string) (float64, bool) {
+	value, ok := record.InfoValue(key)
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	return number, err == nil
+}
+
+/*
+  function to get a list of integers from an INFO field (e.g. DP4)
+*/
+func (record *Record) InfoInts(key string) ([]int, bool) {
+	value, ok := record.InfoValue(key)
+	if !ok {
+		return nil, false
+	}
+	var numbers []int
+	for _, part := range strings.Split(value, ",") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, true
+}
+
+/*
+  function to get a list of floats from an INFO field (e.g. PV4)
+*/
+func (record *Record) InfoFloats(key string) ([]float64, bool) {
+	value, ok := record.InfoValue(key)
+	if !ok {
+		return nil, false
+	}
+	var numbers []float64
+	for _, part := range strings.Split(value, ",") {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, true
+}
+
+/*
+  function to set an INFO field (replacing any existing value)
+*/
+func (record *Record) SetInfo(key string, value string) {
+	for i, field := range record.Info {
+		if field.Key == key {
+			record.Info[i] = InfoField{Key: key, Value: value}
+			return
+		}
+	}
+	record.Info = append(record.Info, InfoField{Key: key, Value: value})
+}
+
+/*
+  function to set an INFO flag
+*/
+func (record *Record) SetFlag(key string) {
+	if !record.HasFlag(key) {
+		record.Info = append(record.Info, InfoField{Key: key, Flag: true})
+	}
+}
+
+/*
+  function to get the read depth (INFO DP)
+*/
+func (record *Record) Depth() (int, bool) {
+	return record.InfoInt("DP")
+}
+
+/*
+  function to get the root-mean-square mapping quality (INFO MQ)
+*/
+func (record *Record) MappingQuality() (int, bool) {
+	if mq, ok := record.InfoInt("MQ"); ok {
+		return mq, true
+	}
+	mq, ok := record.InfoFloat("MQ")
+	return int(mq), ok
+}
+
+/*
+  function to get the high-quality ref-forward, ref-reverse, alt-forward and alt-reverse read counts (INFO DP4)
+*/
+func (record *Record) DP4() ([]int, bool) {
+	dp4, ok := record.InfoInts("DP4")
+	if !ok || len(dp4) != 4 {
+		return nil, false
+	}
+	return dp4, true
+}
+
+/*
+  function to get a FORMAT value for a sample (and whether it was found)
+*/
+func (record *Record) SampleValue(sample int, key string) (string, bool) {
+	if sample < 0 || sample >= len(record.Samples) {
+		return "", false
+	}
+	for i, format := range record.Format {
+		if format == key {
+			if i >= len(record.Samples[sample]) {
+				return "", false
+			}
+			return record.Samples[sample][i], true
+		}
+	}
+	return "", false
+}
+
+/*
+  function to get an integer FORMAT value for a sample (e.g. DP, DV, SP)
+*/
+func (record *Record) SampleInt(sample int, key string) (int, bool) {
+	value, ok := record.SampleValue(sample, key)
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.Atoi(value)
+	return number, err == nil
+}
+
+/*
+  function to get the called alleles of a sample from its GT (-1 for a missing allele)
+*/
+func (record *Record) Genotype(sample int) []int {
+	value, ok := record.SampleValue(sample, "GT")
+	if !ok {
+		return nil
+	}
+	var alleles []int
+	for _, allele := range strings.FieldsFunc(value, func(r rune) bool { return r == '/' || r == '|' }) {
+		index, err := strconv.Atoi(allele)
+		if err != nil {
+			index = -1
+		}
+		alleles = append(alleles, index)
+	}
+	return alleles
+}
+
+/*
+  function to check if a sample carries an ALT allele (index starting at 1)
+*/
+func (record *Record) Carries(sample int, allele int) bool {
+	for _, called := range record.Genotype(sample) {
+		if called == allele {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package vcf
+
+///////////////
+// STRUCTS
+//////////////
+// Stats summarises the records of a VCF
+type Stats struct {
+	Sites      int
+	Variants   int
+	SNPs       int
+	Indels     int
+	Filtered   int
+	Depth_sum  int
+	Depth_seen int
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to add a record to the summary
+*/
+func (stats *Stats) Add(record *Record) {
+	stats.Sites++
+	if depth, ok := record.Depth(); ok {
+		stats.Depth_sum += depth
+		stats.Depth_seen++
+	}
+	if len(record.Filter) != 0 && record.Filter[0] != "PASS" {
+		stats.Filtered++
+	}
+	if !record.IsVariant() {
+		return
+	}
+	stats.Variants++
+	if record.IsIndel() {
+		stats.Indels++
+	} else {
+		stats.SNPs++
+	}
+}
+
+/*
+  function to get the mean depth (INFO DP) over the sites
+*/
+func (stats *Stats) MeanDepth() float64 {
+	if stats.Depth_seen == 0 {
+		return 0
+	}
+	return float64(stats.Depth_sum) / float64(stats.Depth_seen)
+}
+
+/*
+  function to summarise a VCF file
+*/
+func Summarise(file_name string) (*Stats, error) {
+	stats := &Stats{}
+	err := Each(file_name, func(header *Header, record *Record) error {
+		stats.Add(record)
+		return nil
+	})
+	return stats, err
+}
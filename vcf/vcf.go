@@ -0,0 +1,251 @@
+/*
+
+This package reads and writes VCF files.
+
+It supports:
+
+ * header parsing (INFO, FORMAT, FILTER and contig definitions, sample names)
+ * INFO and FORMAT field decoding (e.g. DP, DV, DP4, SP, MQ and QUAL from samtools mpileup + bcftools call)
+ * multi-sample records
+ * streaming reads and writes of plain, gzipped or BGZF-compressed files (BGZF is a series of gzip members)
+
+BCF files need converting to VCF first (bcftools view).
+
+Merging VCFs is out of scope: each sample's calls are applied to its own pseudogenome and the samples are brought together by the snpalign package, so there is no multi-sample VCF to make (bcftools merge can be used if one is needed).
+
+*/
+
+package vcf
+
+///////////////
+// IMPORTS
+//////////////
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+///////////////
+// GLOBALS
+//////////////
+// maximum line length expected in a VCF file
+const max_line = 64 * 1024 * 1024
+
+///////////////
+// STRUCTS
+//////////////
+// Reader streams the records from a VCF
+type Reader struct {
+	Header      *Header
+	scanner     *bufio.Scanner
+	line_number int
+	closers     []io.Closer
+}
+
+// Writer streams records to a VCF
+type Writer struct {
+	Header *Header
+	writer *bufio.Writer
+	closer io.Closer
+}
+
+///////////////
+// FUNCTIONS
+//////////////
+/*
+  function to create a reader for a VCF stream (reads the header straight away)
+*/
+func NewReader(reader io.Reader) (*Reader, error) {
+	vcf_reader := &Reader{Header: &Header{Info: make(map[string]*Definition), Format: make(map[string]*Definition), Filter: make(map[string]*Definition)}}
+	vcf_reader.scanner = bufio.NewScanner(reader)
+	vcf_reader.scanner.Buffer(make([]byte, 0, 64*1024), max_line)
+	for vcf_reader.scanner.Scan() {
+		vcf_reader.line_number++
+		line := strings.TrimRight(vcf_reader.scanner.Text(), "\r")
+		if strings.HasPrefix(line, "##") {
+			vcf_reader.Header.addLine(line)
+			continue
+		}
+		if strings.HasPrefix(line, "#CHROM") {
+			columns := strings.Split(line, "\t")
+			if len(columns) > 9 {
+				vcf_reader.Header.Samples = columns[9:]
+			}
+			return vcf_reader, nil
+		}
+		return nil, fmt.Errorf("line %d: expected a header line", vcf_reader.line_number)
+	}
+	if err := vcf_reader.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no #CHROM header line found")
+}
+
+/*
+  function to open a VCF file (plain, gzip or BGZF - compression is detected from the file rather than the name)
+*/
+func Open(file_name string) (*Reader, error) {
+	fh, err := os.Open(file_name)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReader(fh)
+	closers := []io.Closer{fh}
+	var reader io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			fh.Close()
+			return nil, fmt.Errorf("can't decompress %v: %v", file_name, err)
+		}
+		closers = append([]io.Closer{gz}, closers...)
+		reader = gz
+	}
+	vcf_reader, err := NewReader(reader)
+	if err != nil {
+		for _, closer := range closers {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("%v: %v", file_name, err)
+	}
+	vcf_reader.closers = closers
+	return vcf_reader, nil
+}
+
+/*
+  function to read the next record (returns io.EOF when there are no more records)
+*/
+func (reader *Reader) Read() (*Record, error) {
+	for reader.scanner.Scan() {
+		reader.line_number++
+		line := strings.TrimRight(reader.scanner.Text(), "\r")
+		if len(line) == 0 {
+			continue
+		}
+		record, err := parseRecord(line, len(reader.Header.Samples))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", reader.line_number, err)
+		}
+		return record, nil
+	}
+	if err := reader.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+/*
+  function to close a reader opened with Open
+*/
+func (reader *Reader) Close() error {
+	var first error
+	for _, closer := range reader.closers {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+/*
+  function to create a writer for a VCF stream (writes the header straight away)
+*/
+func NewWriter(writer io.Writer, header *Header) (*Writer, error) {
+	vcf_writer := &Writer{Header: header, writer: bufio.NewWriter(writer)}
+	if err := header.Write(vcf_writer.writer); err != nil {
+		return nil, err
+	}
+	return vcf_writer, nil
+}
+
+/*
+  function to create a VCF file (gzip compressed if the name ends in .gz)
+*/
+func Create(file_name string, header *Header) (*Writer, error) {
+	fh, err := os.Create(file_name)
+	if err != nil {
+		return nil, err
+	}
+	var writer io.Writer = fh
+	var closer io.Closer = fh
+	if strings.HasSuffix(file_name, ".gz") {
+		gz := gzip.NewWriter(fh)
+		writer = gz
+		closer = &gzip_file{gz, fh}
+	}
+	vcf_writer, err := NewWriter(writer, header)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	vcf_writer.closer = closer
+	return vcf_writer, nil
+}
+
+// gzip_file closes the gzip writer and then the file
+type gzip_file struct {
+	gz *gzip.Writer
+	fh *os.File
+}
+
+func (gf *gzip_file) Close() error {
+	if err := gf.gz.Close(); err != nil {
+		gf.fh.Close()
+		return err
+	}
+	return gf.fh.Close()
+}
+
+/*
+  function to write a record
+*/
+func (writer *Writer) Write(record *Record) error {
+	if _, err := writer.writer.WriteString(record.String()); err != nil {
+		return err
+	}
+	return writer.writer.WriteByte('\n')
+}
+
+/*
+  function to flush a writer (and close the file if it was made with Create)
+*/
+func (writer *Writer) Close() error {
+	if err := writer.writer.Flush(); err != nil {
+		if writer.closer != nil {
+			writer.closer.Close()
+		}
+		return err
+	}
+	if writer.closer != nil {
+		return writer.closer.Close()
+	}
+	return nil
+}
+
+/*
+  function to run a function on each record of a VCF file
+*/
+func Each(file_name string, fn func(header *Header, record *Record) error) error {
+	reader, err := Open(file_name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", file_name, err)
+		}
+		if err := fn(reader.Header, record); err != nil {
+			return err
+		}
+	}
+}
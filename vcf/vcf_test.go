@@ -0,0 +1,210 @@
+package vcf
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// a samtools/bcftools style VCF with a reference site, a SNP, a filtered SNP and an indel
+const test_vcf = "##fileformat=VCFv4.2\n" +
+	"##contig=<ID=chr,length=100>\n" +
+	"##INFO=<ID=DP,Number=1,Type=Integer,Description=\"Raw read depth\">\n" +
+	"##INFO=<ID=DP4,Number=4,Type=Integer,Description=\"High-quality ref-forward bases, ref-reverse, alt-forward and alt-reverse bases\">\n" +
+	"##INFO=<ID=MQ,Number=1,Type=Integer,Description=\"Average mapping quality\">\n" +
+	"##INFO=<ID=INDEL,Number=0,Type=Flag,Description=\"Indicates that the variant is an INDEL.\">\n" +
+	"##FILTER=<ID=LowQual,Description=\"Low quality\">\n" +
+	"##FORMAT=<ID=GT,Number=1,Type=String,Description=\"Genotype\">\n" +
+	"##FORMAT=<ID=SP,Number=1,Type=Integer,Description=\"Phred-scaled strand bias P-value\">\n" +
+	"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tsample\n" +
+	"chr\t1\t.\tA\t.\t.\t.\tDP=12\tGT\t0/0\n" +
+	"chr\t10\t.\tC\tT\t222\tPASS\tDP=30;DP4=0,1,14,15;MQ=60\tGT:SP\t1/1:3\n" +
+	"chr\t20\trs1\tG\tA,C\t12.5\tLowQual\tDP=4;DP4=1,1,1,1;MQ=37.4\tGT:SP\t1|2:.\n" +
+	"chr\t30\t.\tAT\tA\t80\tPASS\tINDEL;DP=25\tGT\t./1\n"
+
+/*
+  function to read all the records of the test VCF
+*/
+func readTestVCF(t *testing.T) (*Header, []*Record) {
+	reader, err := NewReader(strings.NewReader(test_vcf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []*Record
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return reader.Header, records
+}
+
+func TestReadHeader(t *testing.T) {
+	header, records := readTestVCF(t)
+	if len(records) != 4 {
+		t.Fatalf("read %d records, expected 4", len(records))
+	}
+	if header.ContigLength("chr") != 100 || header.ContigLength("other") != 0 {
+		t.Errorf("contig chr has length %d", header.ContigLength("chr"))
+	}
+	if header.SampleIndex("sample") != 0 || header.SampleIndex("other") != -1 {
+		t.Errorf("sample has index %d", header.SampleIndex("sample"))
+	}
+	if header.Info["DP4"] == nil || header.Info["DP4"].Number != "4" || header.Format["SP"] == nil || header.Filter["LowQual"] == nil {
+		t.Error("the INFO, FORMAT and FILTER definitions were not read")
+	}
+	if description := header.Info["INDEL"].Description; description != "Indicates that the variant is an INDEL." {
+		t.Errorf("INDEL description is %q", description)
+	}
+}
+
+func TestRecordFields(t *testing.T) {
+	_, records := readTestVCF(t)
+	reference, snp, filtered, indel := records[0], records[1], records[2], records[3]
+
+	if reference.IsVariant() || !reference.Passed() || reference.QualString() != "." {
+		t.Errorf("position 1 should be a passed reference site with no QUAL")
+	}
+	if !snp.IsVariant() || snp.IsIndel() || !snp.Passed() || snp.Qual != 222 {
+		t.Errorf("position 10 should be a passed SNP with QUAL 222")
+	}
+	if filtered.Passed() || filtered.ID != "rs1" || len(filtered.Alt) != 2 {
+		t.Errorf("position 20 should be a LowQual site with two ALT alleles")
+	}
+	if !indel.IsIndel() {
+		t.Errorf("position 30 should be an indel")
+	}
+
+	// INFO values
+	if depth, ok := snp.Depth(); !ok || depth != 30 {
+		t.Errorf("DP is %d (%v)", depth, ok)
+	}
+	if dp4, ok := snp.DP4(); !ok || len(dp4) != 4 || dp4[2] != 14 || dp4[3] != 15 {
+		t.Errorf("DP4 is %v (%v)", dp4, ok)
+	}
+	if mq, ok := filtered.MappingQuality(); !ok || mq != 37 {
+		t.Errorf("a float MQ should be truncated to 37, found %d (%v)", mq, ok)
+	}
+	if _, ok := reference.DP4(); ok {
+		t.Error("found DP4 on a record without it")
+	}
+	if !indel.HasFlag("INDEL") || indel.HasFlag("DP") {
+		t.Error("INDEL should be a flag and DP should not")
+	}
+
+	// FORMAT values and genotypes
+	if sp, ok := snp.SampleInt(0, "SP"); !ok || sp != 3 {
+		t.Errorf("SP is %d (%v)", sp, ok)
+	}
+	if _, ok := filtered.SampleInt(0, "SP"); ok {
+		t.Error("a missing SP value was read as a number")
+	}
+	if _, ok := snp.SampleValue(1, "GT"); ok {
+		t.Error("found a value for a sample that isn't in the VCF")
+	}
+	if gt := filtered.Genotype(0); len(gt) != 2 || gt[0] != 1 || gt[1] != 2 {
+		t.Errorf("phased genotype 1|2 read as %v", gt)
+	}
+	if gt := indel.Genotype(0); len(gt) != 2 || gt[0] != -1 || gt[1] != 1 {
+		t.Errorf("genotype ./1 read as %v", gt)
+	}
+	if !snp.Carries(0, 1) || snp.Carries(0, 2) || reference.Carries(0, 1) || !filtered.Carries(0, 2) {
+		t.Error("Carries does not match the genotypes")
+	}
+}
+
+func TestSetInfo(t *testing.T) {
+	_, records := readTestVCF(t)
+	record := records[1]
+	record.SetInfo("DP", "31")
+	record.SetInfo("AF", "1")
+	record.SetFlag("INDEL")
+	record.SetFlag("INDEL")
+	if record.String() != "chr\t10\t.\tC\tT\t222\tPASS\tDP=31;DP4=0,1,14,15;MQ=60;AF=1;INDEL\tGT:SP\t1/1:3" {
+		t.Errorf("unexpected record after setting INFO fields: %v", record.String())
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	header, records := readTestVCF(t)
+	var buffer bytes.Buffer
+	writer, err := NewWriter(&buffer, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != test_vcf {
+		t.Errorf("the VCF changed when it was written back out:\n%v", buffer.String())
+	}
+}
+
+func TestGzipFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	header, records := readTestVCF(t)
+	file_name := path.Join(dir, "test.vcf.gz")
+	writer, err := Create(file_name, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the compression is detected when the file is opened
+	stats, err := Summarise(file_name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sites != 4 || stats.Variants != 3 || stats.SNPs != 2 || stats.Indels != 1 || stats.Filtered != 1 {
+		t.Errorf("unexpected summary: %+v", stats)
+	}
+	if stats.MeanDepth() != (12+30+4+25)/4.0 {
+		t.Errorf("mean depth is %v", stats.MeanDepth())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	header := "##fileformat=VCFv4.2\n#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tsample\n"
+	bad := map[string]string{
+		"columns":  "chr\t1\t.\tA\tT\t50\tPASS\tDP=1\n",
+		"position": "chr\tone\t.\tA\tT\t50\tPASS\tDP=1\tGT\t1\n",
+		"QUAL":     "chr\t1\t.\tA\tT\thigh\tPASS\tDP=1\tGT\t1\n",
+	}
+	for name, line := range bad {
+		reader, err := NewReader(strings.NewReader(header + line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reader.Read(); err == nil || err == io.EOF {
+			t.Errorf("a record with a bad %v was accepted", name)
+		}
+	}
+	if _, err := NewReader(strings.NewReader("##fileformat=VCFv4.2\n")); err == nil {
+		t.Error("a VCF without a #CHROM line was accepted")
+	}
+}